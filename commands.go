@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	service "github.com/Itish41/LegalEagle/service"
+)
+
+// runCommand runs a maintenance command and prints its result as JSON:
+//
+//	reindex [-drifted-only] [-batch-size N]   rebuild the search index from the documents table
+//	reconcile                                  report documents missing from or stale in the search index
+//	embed [-all]                               embed the documents without chunks from the embedding provider
+func runCommand(ctx context.Context, docService *service.DocumentService, args []string) error {
+	switch args[0] {
+	case "reindex":
+		flags := flag.NewFlagSet("reindex", flag.ContinueOnError)
+		var params service.ReindexParams
+		flags.BoolVar(&params.DriftedOnly, "drifted-only", false, "reindex only documents missing from or stale in the index")
+		flags.IntVar(&params.BatchSize, "batch-size", service.DefaultReindexBatchSize, "documents per bulk request")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		job, err := docService.RunReindex(ctx, params)
+		if err != nil {
+			return err
+		}
+		if job.Error != "" {
+			return fmt.Errorf("job %s: %s", job.ID, job.Error)
+		}
+		return printJSON(job.Result)
+	case "reconcile":
+		report, err := docService.ReconcileIndex(ctx)
+		if err != nil {
+			return err
+		}
+		return printJSON(report)
+	case "embed":
+		flags := flag.NewFlagSet("embed", flag.ContinueOnError)
+		all := flags.Bool("all", false, "embed every document again, not only those without chunks")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		progress, err := docService.EmbedDocuments(ctx, *all)
+		if err != nil {
+			return err
+		}
+		return printJSON(progress)
+	default:
+		return fmt.Errorf("unknown command %q; expected reindex, reconcile or embed", args[0])
+	}
+}
+
+// printJSON writes v to standard output as indented JSON
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetDocumentClauses lists the clauses extracted from a document. Query parameter "type" keeps only
+// clauses of that type, e.g. "termination".
+func (c *DocumentController) GetDocumentClauses(ctx *gin.Context) {
+	docID := ctx.Param("id")
+	clauses, err := c.service.GetDocumentClauses(docID, ctx.Query("type"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+		log.Printf("[GetDocumentClauses] Error fetching clauses for %s: %v", docID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"document_id": docID,
+		"clauses":     clauses,
+	})
+}
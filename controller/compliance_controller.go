@@ -1,11 +1,16 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/Itish41/LegalEagle/models"
 	service "github.com/Itish41/LegalEagle/service"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // DocumentController manages HTTP requests for document uploads
@@ -19,8 +24,8 @@ func (c *DocumentController) AddComplianceRule(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := c.service.AddComplianceRule(&rule); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := c.service.AddComplianceRule(&rule, ruleActor(ctx)); err != nil {
+		respondRuleError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusCreated, rule)
@@ -55,3 +60,182 @@ func (c *DocumentController) GetComplianceRulesByNames(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, rules)
 }
+
+// ruleActor identifies who is changing rules, taken from the X-Actor header
+func ruleActor(ctx *gin.Context) string {
+	if actor := ctx.GetHeader("X-Actor"); actor != "" {
+		return actor
+	}
+	return "anonymous"
+}
+
+// respondRuleError maps rule service errors to HTTP status codes
+func respondRuleError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+	case errors.Is(err, service.ErrInvalidRule), errors.Is(err, service.ErrInvalidRulePack):
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrRuleNameTaken):
+		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// GetComplianceRule retrieves a single compliance rule
+func (c *DocumentController) GetComplianceRule(ctx *gin.Context) {
+	rule, err := c.service.GetComplianceRule(ctx.Param("id"))
+	if err != nil {
+		respondRuleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, rule)
+}
+
+// UpdateComplianceRule replaces a compliance rule
+func (c *DocumentController) UpdateComplianceRule(ctx *gin.Context) {
+	var rule models.ComplianceRule
+	if err := ctx.ShouldBindJSON(&rule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updated, err := c.service.UpdateComplianceRule(ctx.Param("id"), rule, ruleActor(ctx))
+	if err != nil {
+		respondRuleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, updated)
+}
+
+// PatchComplianceRule changes the fields present in the request body
+func (c *DocumentController) PatchComplianceRule(ctx *gin.Context) {
+	var patch service.RulePatch
+	if err := ctx.ShouldBindJSON(&patch); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updated, err := c.service.PatchComplianceRule(ctx.Param("id"), patch, ruleActor(ctx))
+	if err != nil {
+		respondRuleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, updated)
+}
+
+// DeleteComplianceRule soft-deletes a compliance rule
+func (c *DocumentController) DeleteComplianceRule(ctx *gin.Context) {
+	if err := c.service.DeleteComplianceRule(ctx.Param("id"), ruleActor(ctx)); err != nil {
+		respondRuleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Rule deleted"})
+}
+
+// GetRuleAuditLog lists the recorded changes of a compliance rule
+func (c *DocumentController) GetRuleAuditLog(ctx *gin.Context) {
+	entries, err := c.service.GetRuleAuditLog(ctx.Param("id"))
+	if err != nil {
+		respondRuleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
+
+// GetRuleRevisions lists the revisions of a compliance rule
+func (c *DocumentController) GetRuleRevisions(ctx *gin.Context) {
+	revisions, err := c.service.GetRuleRevisions(ctx.Param("id"))
+	if err != nil {
+		respondRuleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, revisions)
+}
+
+// ValidateRule checks the syntax of a rule pattern and expression and reports errors with positions
+func (c *DocumentController) ValidateRule(ctx *gin.Context) {
+	var request struct {
+		Pattern    string `json:"pattern"`
+		Expression string `json:"expression"`
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Pattern == "" && request.Expression == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "pattern or expression is required"})
+		return
+	}
+
+	problems := c.service.ValidateRuleSyntax(request.Pattern, request.Expression)
+	ctx.JSON(http.StatusOK, gin.H{
+		"valid":  len(problems) == 0,
+		"errors": problems,
+	})
+}
+
+// TestComplianceRule evaluates a draft rule against sample text or stored documents without saving anything
+func (c *DocumentController) TestComplianceRule(ctx *gin.Context) {
+	var request service.RuleDryRunRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := c.service.DryRunRule(ctx.Request.Context(), request)
+	if err != nil {
+		respondRuleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// ExportRules downloads the active rules as a rule pack. ?format=json|yaml (default yaml), ?tags=a,b
+// limits the pack to rules carrying any of the tags.
+func (c *DocumentController) ExportRules(ctx *gin.Context) {
+	format := strings.ToLower(ctx.DefaultQuery("format", "yaml"))
+	if format != "yaml" && format != "json" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "format must be yaml or json"})
+		return
+	}
+	pack, err := c.service.ExportRulePack(ctx.Query("name"), splitFormList(ctx.Query("tags")))
+	if err != nil {
+		respondRuleError(ctx, err)
+		return
+	}
+	data, err := service.MarshalRulePack(pack, format)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType := "application/yaml"
+	if format == "json" {
+		contentType = "application/json"
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="rules.%s"`, format))
+	ctx.Data(http.StatusOK, contentType, data)
+}
+
+// ImportRules creates or updates rules from a YAML or JSON rule pack in the request body.
+// ?dry_run=true reports what would change without saving anything.
+func (c *DocumentController) ImportRules(ctx *gin.Context) {
+	data, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pack, err := service.ParseRulePack(data)
+	if err != nil {
+		respondRuleError(ctx, err)
+		return
+	}
+
+	dryRun := ctx.Query("dry_run") == "true"
+	result, err := c.service.ImportRulePack(pack, dryRun, ruleActor(ctx))
+	if err != nil {
+		respondRuleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
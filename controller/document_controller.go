@@ -1,12 +1,18 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	service "github.com/Itish41/LegalEagle/service"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // DocumentController manages HTTP requests for document uploads
@@ -19,7 +25,7 @@ func NewDocumentController(service *service.DocumentService) *DocumentController
 	return &DocumentController{service}
 }
 
-// UploadDocument handles the file upload request
+// UploadDocument queues the uploaded file for processing and returns the job ID
 func (c *DocumentController) UploadDocument(ctx *gin.Context) {
 	file, header, err := ctx.Request.FormFile("file")
 	if err != nil {
@@ -28,27 +34,99 @@ func (c *DocumentController) UploadDocument(ctx *gin.Context) {
 	}
 	defer file.Close()
 
-	ocrText, fileID, fileURL, complianceResults, riskScore, err := c.service.UploadAndProcessDocument(file, header) // Update service to return these
+	result, err := c.service.SubmitDocument(file, header, service.UploadOptions{
+		IdempotencyKey:   ctx.GetHeader("Idempotency-Key"),
+		NewVersion:       ctx.PostForm("new_version") == "true",
+		ParentDocumentID: ctx.PostForm("parent_document_id"),
+		Category:         ctx.PostForm("category"),
+		Jurisdiction:     ctx.PostForm("jurisdiction"),
+		Tags:             splitFormList(ctx.PostForm("tags")),
+	})
 	if err != nil {
+		if errors.Is(err, service.ErrIdempotencyKeyConflict) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrParentDocumentNotFound) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{
-		"message":           "Document uploaded and processed successfully",
-		"ocrText":           ocrText,
-		"fileID":            fileID,
-		"fileURL":           fileURL,
-		"complianceResults": complianceResults, // Optional
-		"riskScore":         riskScore,
+
+	if result.Duplicate != nil {
+		ctx.JSON(http.StatusOK, gin.H{
+			"message":    "An identical document has already been processed",
+			"duplicate":  true,
+			"documentID": result.Duplicate.ID,
+			"fileURL":    result.Duplicate.OriginalURL,
+			"riskScore":  result.Duplicate.RiskScore,
+		})
+		return
+	}
+
+	status := http.StatusAccepted
+	message := "Document accepted for processing"
+	switch {
+	case result.Replayed:
+		status = http.StatusOK
+		message = "Document was already submitted with this idempotency key"
+	case result.InProgress:
+		status = http.StatusOK
+		message = "An identical document is already being processed"
+	}
+	ctx.JSON(status, gin.H{
+		"message":   message,
+		"jobID":     result.Job.ID,
+		"status":    result.Job.Status,
+		"statusURL": "/jobs/" + result.Job.ID,
 	})
 }
 
-// GetAllDocuments retrieves all documents from the database
+// splitFormList splits a comma-separated form value into its entries
+func splitFormList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// GetJob reports the status of a processing job
+func (c *DocumentController) GetJob(ctx *gin.Context) {
+	jobID := ctx.Param("id")
+	if jobID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Job ID required"})
+		return
+	}
+
+	job, err := c.service.GetJob(jobID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
+
+// GetAllDocuments lists a page of documents for the dashboard. See documentListParamsFromQuery for
+// the query parameters; next_cursor fetches the following page.
 func (dc *DocumentController) GetAllDocuments(c *gin.Context) {
-	log.Println("DocumentController: Fetching all documents")
+	params, err := documentListParamsFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	docs, err := dc.service.GetAllDocuments()
+	page, err := dc.service.ListDocuments(params)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidDocumentQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		log.Printf("Error fetching documents: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to retrieve documents",
@@ -57,37 +135,303 @@ func (dc *DocumentController) GetAllDocuments(c *gin.Context) {
 		return
 	}
 
-	// Log first few documents for debugging
-	for i, doc := range docs {
-		if i < 3 {
-			log.Printf("document %d - ID: %v, Title: %s, OCR Text Length: %d, Risk Score: %f",
-				i+1, doc["id"], doc["title"], len(doc["ocr_text"].(string)), doc["risk_score"])
+	c.JSON(http.StatusOK, gin.H{
+		"documents":   page.Documents,
+		"count":       len(page.Documents),
+		"total":       page.Total,
+		"next_cursor": page.NextCursor,
+		"has_more":    page.NextCursor != "",
+	})
+}
+
+// documentListParamsFromQuery reads the dashboard query: limit, cursor, sort (created_at,
+// updated_at, risk_score or title), order (asc or desc), fields (comma-separated, "compliance" for
+// the compliance summary) and the filters category, file_type, min_risk, max_risk, status (pass or
+// fail), failing_rule, created_from, created_to, governing_law, party, effective_from, effective_to,
+// currency and min_amount. Dates are YYYY-MM-DD or RFC 3339; a date-only upper bound includes that day.
+func documentListParamsFromQuery(c *gin.Context) (service.DocumentListParams, error) {
+	params := service.DocumentListParams{
+		Sort:   c.Query("sort"),
+		Order:  c.Query("order"),
+		Cursor: c.Query("cursor"),
+		Fields: splitFormList(c.Query("fields")),
+		Filter: service.DocumentFilter{
+			Category:         c.Query("category"),
+			FileType:         c.Query("file_type"),
+			ComplianceStatus: c.Query("status"),
+			FailingRule:      c.Query("failing_rule"),
+			GoverningLaw:     c.Query("governing_law"),
+			Party:            c.Query("party"),
+			Currency:         c.Query("currency"),
+		},
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return params, fmt.Errorf("limit must be a positive integer")
+		}
+		params.Limit = limit
+	}
+
+	numbers := map[string]**float64{
+		"min_risk":   &params.Filter.MinRisk,
+		"max_risk":   &params.Filter.MaxRisk,
+		"min_amount": &params.Filter.MinAmount,
+	}
+	for param, target := range numbers {
+		if value := c.Query(param); value != "" {
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return params, fmt.Errorf("%s must be a number", param)
+			}
+			*target = &number
 		}
 	}
 
-	// Return documents with additional metadata
-	c.JSON(http.StatusOK, gin.H{
-		"documents": docs,
-		"total":     len(docs),
-	})
+	dates := []struct {
+		param    string
+		target   **time.Time
+		endOfDay bool
+	}{
+		{"created_from", &params.Filter.CreatedFrom, false},
+		{"created_to", &params.Filter.CreatedTo, true},
+		{"effective_from", &params.Filter.EffectiveFrom, false},
+		{"effective_to", &params.Filter.EffectiveTo, false},
+	}
+	for _, date := range dates {
+		if value := c.Query(date.param); value != "" {
+			at, err := parseQueryTime(value, date.endOfDay)
+			if err != nil {
+				return params, fmt.Errorf("%s must be a date in YYYY-MM-DD or RFC 3339 format", date.param)
+			}
+			*date.target = &at
+		}
+	}
+	return params, nil
+}
+
+// parseQueryTime parses a YYYY-MM-DD date or an RFC 3339 time. With endOfDay a date means the last
+// instant of that day.
+func parseQueryTime(value string, endOfDay bool) (time.Time, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			return date.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+		}
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// GetDocument returns a document with its compliance results, action items and extracted key terms
+func (c *DocumentController) GetDocument(ctx *gin.Context) {
+	docID := ctx.Param("id")
+	doc, err := c.service.GetDocument(docID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+		log.Printf("[GetDocument] Error fetching document %s: %v", docID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, doc)
 }
 
-// In controllers
+// PatchDocument changes a document's title, category, jurisdiction or tags
+func (c *DocumentController) PatchDocument(ctx *gin.Context) {
+	var patch service.DocumentPatch
+	if err := ctx.ShouldBindJSON(&patch); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	docID := ctx.Param("id")
+	update, err := c.service.PatchDocument(docID, patch)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		case errors.Is(err, service.ErrInvalidDocument):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Printf("[PatchDocument] Error updating document %s: %v", docID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	response := gin.H{"document": update.Document}
+	if update.ReevaluationJob != nil {
+		response["reevaluationJobID"] = update.ReevaluationJob.ID
+		response["statusURL"] = "/jobs/" + update.ReevaluationJob.ID
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// DeleteDocument removes a document with its results, search index entry and stored file
+func (c *DocumentController) DeleteDocument(ctx *gin.Context) {
+	docID := ctx.Param("id")
+	if err := c.service.DeleteDocument(ctx.Request.Context(), docID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+		log.Printf("[DeleteDocument] Error deleting document %s: %v", docID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Document deleted"})
+}
+
+// SearchDocuments runs a paged full-text search with filters, facet counts and highlighted snippets
 func (c *DocumentController) SearchDocuments(ctx *gin.Context) {
-	query := ctx.Query("q")
-	if query == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'q' is required"})
+	req, err := searchRequestFromQuery(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	results, err := c.service.SearchDocuments(query)
+	results, err := c.service.SearchDocuments(ctx.Request.Context(), req)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidSearch) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("[SearchDocuments] Error searching documents: %v", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": "Search completed successfully",
-		"results": results,
+		"results": results.Hits,
+		"total":   results.Total,
+		"page":    results.Page,
+		"size":    results.Size,
+		"facets":  results.Facets,
+	})
+}
+
+// SemanticSearch finds the document chunks closest in meaning to the query, ranked by a mix of
+// vector similarity and keyword matches, with the documents they belong to
+func (c *DocumentController) SemanticSearch(ctx *gin.Context) {
+	query, err := searchRequestFromQuery(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := service.SemanticSearchRequest{Query: query.Query, Size: query.Size, Filter: query.Filter}
+	if value := ctx.Query("keyword_weight"); value != "" {
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "keyword_weight must be a number"})
+			return
+		}
+		req.KeywordWeight = &weight
+	}
+
+	results, err := c.service.SemanticSearch(ctx.Request.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidSearch):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrSemanticSearchDisabled):
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			log.Printf("[SemanticSearch] Error searching chunks: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "Semantic search completed successfully",
+		"results": results.Hits,
+		"model":   results.Model,
+	})
+}
+
+// searchRequestFromQuery reads the query, paging, sort and filter parameters of a search
+func searchRequestFromQuery(c *gin.Context) (service.SearchRequest, error) {
+	req := service.SearchRequest{
+		Query: c.Query("q"),
+		Sort:  c.Query("sort"),
+		Order: c.Query("order"),
+		Filter: service.SearchFilter{
+			Category:    c.Query("category"),
+			FileType:    c.Query("file_type"),
+			FailingRule: c.Query("failing_rule"),
+		},
+	}
+	if req.Query == "" {
+		return req, fmt.Errorf("query parameter 'q' is required")
+	}
+
+	integers := map[string]*int{"page": &req.Page, "size": &req.Size}
+	for param, target := range integers {
+		if value := c.Query(param); value != "" {
+			number, err := strconv.Atoi(value)
+			if err != nil || number < 1 {
+				return req, fmt.Errorf("%s must be a positive integer", param)
+			}
+			*target = number
+		}
+	}
+
+	numbers := map[string]**float64{"min_risk": &req.Filter.MinRisk, "max_risk": &req.Filter.MaxRisk}
+	for param, target := range numbers {
+		if value := c.Query(param); value != "" {
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return req, fmt.Errorf("%s must be a number", param)
+			}
+			*target = &number
+		}
+	}
+
+	dates := []struct {
+		param    string
+		target   **time.Time
+		endOfDay bool
+	}{
+		{"created_from", &req.Filter.CreatedFrom, false},
+		{"created_to", &req.Filter.CreatedTo, true},
+	}
+	for _, date := range dates {
+		if value := c.Query(date.param); value != "" {
+			at, err := parseQueryTime(value, date.endOfDay)
+			if err != nil {
+				return req, fmt.Errorf("%s must be a date in YYYY-MM-DD or RFC 3339 format", date.param)
+			}
+			*date.target = &at
+		}
+	}
+	return req, nil
+}
+
+// ReevaluateDocuments queues a job that reruns compliance for stored documents
+func (c *DocumentController) ReevaluateDocuments(ctx *gin.Context) {
+	var params service.ReevaluateParams
+	if err := ctx.ShouldBindJSON(&params); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := c.service.SubmitReevaluation(params)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidReevaluation) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("[ReevaluateDocuments] Error submitting re-evaluation: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"message":   "Re-evaluation accepted for processing",
+		"jobID":     job.ID,
+		"status":    job.Status,
+		"statusURL": "/jobs/" + job.ID,
 	})
 }
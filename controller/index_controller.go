@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	service "github.com/Itish41/LegalEagle/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ReindexDocuments queues a job that rebuilds the search index from the stored documents. The body
+// is optional; {"drifted_only": true} reindexes only documents missing from or stale in the index.
+func (c *DocumentController) ReindexDocuments(ctx *gin.Context) {
+	var params service.ReindexParams
+	if err := ctx.ShouldBindJSON(&params); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := c.service.SubmitReindex(params)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidReindex) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("[ReindexDocuments] Error submitting reindex: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"message":   "Reindex accepted for processing",
+		"jobID":     job.ID,
+		"status":    job.Status,
+		"statusURL": "/jobs/" + job.ID,
+	})
+}
+
+// ReconcileIndex reports the documents missing from or stale in the search index
+func (c *DocumentController) ReconcileIndex(ctx *gin.Context) {
+	report, err := c.service.ReconcileIndex(ctx.Request.Context())
+	if err != nil {
+		log.Printf("[ReconcileIndex] Error reconciling search index: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetDocumentObligations lists the dated obligations derived from a document
+func (c *DocumentController) GetDocumentObligations(ctx *gin.Context) {
+	docID := ctx.Param("id")
+	obligations, err := c.service.GetDocumentObligations(docID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+		log.Printf("[GetDocumentObligations] Error fetching obligations for %s: %v", docID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"document_id": docID,
+		"obligations": obligations,
+	})
+}
+
+// GetUpcomingObligations lists open obligations to act on within "days" days (default 90), including
+// overdue ones
+func (c *DocumentController) GetUpcomingObligations(ctx *gin.Context) {
+	days, err := strconv.Atoi(ctx.DefaultQuery("days", "90"))
+	if err != nil || days < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "days must be a non-negative integer"})
+		return
+	}
+
+	obligations, err := c.service.GetUpcomingObligations(days)
+	if err != nil {
+		log.Printf("[GetUpcomingObligations] Error fetching obligations: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"days":        days,
+		"obligations": obligations,
+	})
+}
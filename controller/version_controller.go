@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	service "github.com/Itish41/LegalEagle/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetDocumentVersions lists all versions in the lineage of a document
+func (c *DocumentController) GetDocumentVersions(ctx *gin.Context) {
+	docID := ctx.Param("id")
+	versions, err := c.service.GetDocumentVersions(docID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+		log.Printf("[GetDocumentVersions] Error fetching versions for %s: %v", docID, err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"document_id": docID,
+		"versions":    versions,
+	})
+}
+
+// DiffDocumentVersions compares two versions of a document. Query parameters "from" and "to" are
+// version numbers; both are optional. Without "from", the first version has nothing to be compared
+// with and the request is rejected.
+func (c *DocumentController) DiffDocumentVersions(ctx *gin.Context) {
+	docID := ctx.Param("id")
+
+	var from, to int
+	var err error
+	if value := ctx.Query("from"); value != "" {
+		if from, err = strconv.Atoi(value); err != nil || from < 1 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'from' must be a positive version number"})
+			return
+		}
+	}
+	if value := ctx.Query("to"); value != "" {
+		if to, err = strconv.Atoi(value); err != nil || to < 1 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'to' must be a positive version number"})
+			return
+		}
+	}
+
+	diff, err := c.service.DiffDocumentVersions(docID, from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		case errors.Is(err, service.ErrVersionNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrNoPreviousVersion):
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Printf("[DiffDocumentVersions] Error diffing %s: %v", docID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, diff)
+}
@@ -0,0 +1,22 @@
+-- Create processing_jobs table used by the asynchronous upload pipeline
+CREATE TABLE IF NOT EXISTS processing_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    current_stage VARCHAR(50),
+    stages JSONB,
+    file_name TEXT,
+    content_type TEXT,
+    file_id TEXT,
+    input BYTEA,
+    document_id UUID REFERENCES documents(id) ON DELETE SET NULL,
+    result JSONB,
+    error TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Workers look up unfinished jobs on startup
+CREATE INDEX IF NOT EXISTS idx_processing_jobs_status ON processing_jobs(status);
@@ -0,0 +1,6 @@
+-- Clients may retry an upload with the same Idempotency-Key header; only one job is created per key
+ALTER TABLE processing_jobs ADD COLUMN IF NOT EXISTS idempotency_key TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_processing_jobs_idempotency_key
+    ON processing_jobs(idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
@@ -0,0 +1,7 @@
+-- SHA-256 of the uploaded file, used to detect re-uploads of identical documents
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64);
+CREATE INDEX IF NOT EXISTS idx_documents_content_hash ON documents(content_hash);
+
+ALTER TABLE processing_jobs ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64);
+ALTER TABLE processing_jobs ADD COLUMN IF NOT EXISTS source_document_id UUID REFERENCES documents(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_processing_jobs_content_hash ON processing_jobs(content_hash);
@@ -0,0 +1,15 @@
+-- Create document_versions table linking revised uploads to the document they revise
+CREATE TABLE IF NOT EXISTS document_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    root_document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    document_id UUID NOT NULL UNIQUE REFERENCES documents(id) ON DELETE CASCADE,
+    parent_document_id UUID REFERENCES documents(id) ON DELETE SET NULL,
+    version_number INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (root_document_id, version_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_versions_root_document_id ON document_versions(root_document_id);
+
+-- Uploads may name the document they revise
+ALTER TABLE processing_jobs ADD COLUMN IF NOT EXISTS parent_document_id UUID REFERENCES documents(id) ON DELETE SET NULL;
@@ -0,0 +1,29 @@
+-- Rules are soft-deleted so results and action items keep referencing the rule they were judged against
+ALTER TABLE compliance_rules ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE compliance_rules ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_compliance_rules_deleted_at ON compliance_rules(deleted_at);
+
+-- Deleting a rule row must never cascade away historical results
+ALTER TABLE document_rule_results DROP CONSTRAINT IF EXISTS document_rule_results_rule_id_fkey;
+ALTER TABLE document_rule_results
+    ADD CONSTRAINT document_rule_results_rule_id_fkey
+    FOREIGN KEY (rule_id) REFERENCES compliance_rules(id) ON DELETE RESTRICT;
+
+ALTER TABLE action_items DROP CONSTRAINT IF EXISTS action_items_rule_id_fkey;
+ALTER TABLE action_items
+    ADD CONSTRAINT action_items_rule_id_fkey
+    FOREIGN KEY (rule_id) REFERENCES compliance_rules(id) ON DELETE RESTRICT;
+
+-- Create rule_audit_logs table recording every change made to a rule
+CREATE TABLE IF NOT EXISTS rule_audit_logs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    rule_id UUID NOT NULL REFERENCES compliance_rules(id) ON DELETE RESTRICT,
+    action VARCHAR(20) NOT NULL,
+    actor TEXT,
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_rule_audit_logs_rule_id ON rule_audit_logs(rule_id);
@@ -0,0 +1,35 @@
+-- Create compliance_rule_revisions table holding an immutable copy of every version of a rule
+CREATE TABLE IF NOT EXISTS compliance_rule_revisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    rule_id UUID NOT NULL REFERENCES compliance_rules(id) ON DELETE RESTRICT,
+    revision INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    pattern TEXT,
+    severity VARCHAR(20),
+    created_by TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (rule_id, revision)
+);
+
+CREATE INDEX IF NOT EXISTS idx_compliance_rule_revisions_rule_id ON compliance_rule_revisions(rule_id);
+
+-- Rules point at their current revision
+ALTER TABLE compliance_rules ADD COLUMN IF NOT EXISTS current_revision_id UUID REFERENCES compliance_rule_revisions(id);
+ALTER TABLE compliance_rules ADD COLUMN IF NOT EXISTS revision INTEGER NOT NULL DEFAULT 0;
+
+-- Results record the revision that judged the document
+ALTER TABLE document_rule_results ADD COLUMN IF NOT EXISTS rule_revision_id UUID REFERENCES compliance_rule_revisions(id) ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS idx_document_rule_results_rule_revision_id ON document_rule_results(rule_revision_id);
+
+-- Existing rules become revision 1 of themselves
+INSERT INTO compliance_rule_revisions (rule_id, revision, name, description, pattern, severity, created_by, created_at)
+SELECT id, 1, name, description, pattern, severity, 'migration', created_at
+FROM compliance_rules
+WHERE current_revision_id IS NULL;
+
+UPDATE compliance_rules r
+SET current_revision_id = rv.id, revision = 1
+FROM compliance_rule_revisions rv
+WHERE rv.rule_id = r.id AND rv.revision = 1 AND r.current_revision_id IS NULL;
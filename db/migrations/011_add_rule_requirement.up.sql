@@ -0,0 +1,3 @@
+-- A rule's pattern is either required to match or forbidden from matching
+ALTER TABLE compliance_rules ADD COLUMN IF NOT EXISTS requirement VARCHAR(20) NOT NULL DEFAULT 'required';
+ALTER TABLE compliance_rule_revisions ADD COLUMN IF NOT EXISTS requirement VARCHAR(20) NOT NULL DEFAULT 'required';
@@ -0,0 +1,3 @@
+-- Rules may carry an expression composing patterns with boolean logic and section/page scopes
+ALTER TABLE compliance_rules ADD COLUMN IF NOT EXISTS expression TEXT;
+ALTER TABLE compliance_rule_revisions ADD COLUMN IF NOT EXISTS expression TEXT;
@@ -0,0 +1,9 @@
+-- Processing jobs are no longer only uploads; re-evaluation jobs carry parameters and progress
+ALTER TABLE processing_jobs ADD COLUMN IF NOT EXISTS type VARCHAR(30) NOT NULL DEFAULT 'upload';
+ALTER TABLE processing_jobs ADD COLUMN IF NOT EXISTS params JSONB;
+ALTER TABLE processing_jobs ADD COLUMN IF NOT EXISTS progress JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_processing_jobs_type ON processing_jobs(type);
+
+-- Look up the result of a rule for a document when re-evaluating
+CREATE INDEX IF NOT EXISTS idx_document_rule_results_document_rule ON document_rule_results(document_id, rule_id);
@@ -0,0 +1,5 @@
+-- Rules carry free-form tags used to group them into rule packs
+ALTER TABLE compliance_rules ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE compliance_rule_revisions ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_compliance_rules_tags ON compliance_rules USING GIN (tags);
@@ -0,0 +1,17 @@
+-- Rules can be limited to document categories, jurisdictions, file types and document tags
+ALTER TABLE compliance_rules ADD COLUMN IF NOT EXISTS categories TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE compliance_rules ADD COLUMN IF NOT EXISTS jurisdictions TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE compliance_rules ADD COLUMN IF NOT EXISTS file_types TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE compliance_rules ADD COLUMN IF NOT EXISTS document_tags TEXT[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE compliance_rule_revisions ADD COLUMN IF NOT EXISTS categories TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE compliance_rule_revisions ADD COLUMN IF NOT EXISTS jurisdictions TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE compliance_rule_revisions ADD COLUMN IF NOT EXISTS file_types TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE compliance_rule_revisions ADD COLUMN IF NOT EXISTS document_tags TEXT[] NOT NULL DEFAULT '{}';
+
+-- Documents carry the metadata rules are scoped by
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS category TEXT;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS jurisdiction TEXT;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_documents_category ON documents(category);
@@ -0,0 +1,6 @@
+-- Documents record how their category was determined
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS category_confidence DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS category_source TEXT;
+
+-- Categories supplied before classification existed came from the upload request
+UPDATE documents SET category_confidence = 1, category_source = 'upload' WHERE category IS NOT NULL;
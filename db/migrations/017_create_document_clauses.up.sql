@@ -0,0 +1,18 @@
+-- Sections and clauses extracted from each document's OCR text
+CREATE TABLE IF NOT EXISTS document_clauses (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    number TEXT,
+    level INTEGER NOT NULL DEFAULT 1,
+    heading TEXT,
+    clause_type TEXT NOT NULL DEFAULT 'other',
+    text TEXT NOT NULL,
+    start_offset INTEGER NOT NULL,
+    end_offset INTEGER NOT NULL,
+    page INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (document_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_clauses_clause_type ON document_clauses(clause_type);
@@ -0,0 +1,20 @@
+-- Key terms (parties, dates, durations, amounts, governing law) extracted from each document
+CREATE TABLE IF NOT EXISTS document_terms (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    term_type TEXT NOT NULL,
+    value TEXT NOT NULL,
+    date_value DATE,
+    number_value DOUBLE PRECISION,
+    unit TEXT,
+    source_text TEXT NOT NULL,
+    start_offset INTEGER NOT NULL,
+    end_offset INTEGER NOT NULL,
+    confidence DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (document_id, term_type, start_offset)
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_terms_document_id ON document_terms(document_id);
+CREATE INDEX IF NOT EXISTS idx_document_terms_type_value ON document_terms(term_type, lower(value));
+CREATE INDEX IF NOT EXISTS idx_document_terms_type_date ON document_terms(term_type, date_value);
@@ -0,0 +1,24 @@
+-- Dated obligations (renewals, expiries, payments, deadlines) derived from document text
+CREATE TABLE IF NOT EXISTS obligations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    obligation_type TEXT NOT NULL,
+    description TEXT NOT NULL,
+    due_date DATE NOT NULL,
+    act_by DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    action_item_id UUID REFERENCES action_items(id) ON DELETE SET NULL,
+    source_text TEXT NOT NULL,
+    start_offset INTEGER NOT NULL,
+    end_offset INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (document_id, obligation_type, start_offset)
+);
+
+CREATE INDEX IF NOT EXISTS idx_obligations_document_id ON obligations(document_id);
+CREATE INDEX IF NOT EXISTS idx_obligations_status_act_by ON obligations(status, act_by);
+
+-- Reminder action items belong to an obligation rather than a compliance rule
+ALTER TABLE action_items ADD COLUMN IF NOT EXISTS obligation_id UUID REFERENCES obligations(id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS idx_action_items_obligation_id ON action_items(obligation_id);
@@ -0,0 +1,4 @@
+-- Keyset pagination of the dashboard orders by the sort column and id
+CREATE INDEX IF NOT EXISTS idx_documents_created_at_id ON documents(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_documents_updated_at_id ON documents(updated_at, id);
+CREATE INDEX IF NOT EXISTS idx_documents_lower_file_type ON documents(lower(file_type));
@@ -0,0 +1,18 @@
+-- Full-text search without Elasticsearch: a weighted tsvector of title and text, plus trigram
+-- indexes for typo-tolerant title matches and substring matches in the text.
+--
+-- Only the first 100000 characters of the text are indexed (searchedTextLength in
+-- service/search_postgres.go): a tsvector cannot exceed 1MB, and longer texts would make the
+-- indexes large without improving results much. Adding the stored column rewrites the documents
+-- table and building the indexes reads all of it, both under an exclusive lock, so on a large table
+-- run this migration in a maintenance window.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('english', left(COALESCE(ocr_text, ''), 100000)), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_documents_search_vector ON documents USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_documents_title_trgm ON documents USING GIN (title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_documents_ocr_text_trgm ON documents USING GIN (left(ocr_text, 100000) gin_trgm_ops);
@@ -0,0 +1,41 @@
+-- Chunks of document text with their embeddings, for semantic search. The embedding column needs
+-- the pgvector extension, so the table is only created where the server provides it; elsewhere
+-- semantic search stays unavailable. A server that gets pgvector later can run this file again.
+--
+-- The embedding column has no fixed size so that the embedding model can change. Each model gets a
+-- partial HNSW index over its vectors cast to the model's size, created at startup once the
+-- configured model is known (see chunkVectorIndexSQL).
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'vector') THEN
+        RAISE NOTICE 'pgvector is not available; skipping document_chunks';
+        RETURN;
+    END IF;
+
+    CREATE EXTENSION IF NOT EXISTS vector;
+
+    CREATE TABLE IF NOT EXISTS document_chunks (
+        id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+        document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+        clause_id UUID REFERENCES document_clauses(id) ON DELETE SET NULL,
+        position INTEGER NOT NULL,
+        heading TEXT,
+        clause_type TEXT,
+        content TEXT NOT NULL,
+        start_offset INTEGER NOT NULL,
+        end_offset INTEGER NOT NULL,
+        page INTEGER NOT NULL DEFAULT 1,
+        embedding_model TEXT NOT NULL,
+        embedding vector NOT NULL,
+        search_vector tsvector GENERATED ALWAYS AS (
+            setweight(to_tsvector('english', COALESCE(heading, '')), 'A') ||
+            setweight(to_tsvector('english', content), 'B')
+        ) STORED,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+        UNIQUE (document_id, position)
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_document_chunks_embedding_model ON document_chunks(embedding_model);
+    CREATE INDEX IF NOT EXISTS idx_document_chunks_search_vector ON document_chunks USING GIN (search_vector);
+END
+$$;
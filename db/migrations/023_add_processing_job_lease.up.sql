@@ -0,0 +1,6 @@
+-- A worker holds a lease on the job it runs and renews it while the job makes progress. Running jobs
+-- whose lease expired belong to an instance that stopped and may be claimed by any other worker.
+ALTER TABLE processing_jobs ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMP WITH TIME ZONE;
+
+-- Workers poll for the oldest claimable job
+CREATE INDEX IF NOT EXISTS idx_processing_jobs_status_created_at ON processing_jobs(status, created_at);
@@ -3,8 +3,11 @@ package main
 import (
 	// "yourproject/controllers"
 	// "yourproject/services"
+	"context"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 
 	controller "github.com/Itish41/LegalEagle/controller"
 	"github.com/Itish41/LegalEagle/initializers"
@@ -33,6 +36,23 @@ func main() {
 		log.Fatalf("Failed to initialize document service: %s", err)
 	}
 
+	// Maintenance commands such as "reindex" run instead of the server
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), docService, os.Args[1:]); err != nil {
+			log.Fatalf("%s failed: %s", os.Args[1], err)
+		}
+		return
+	}
+
+	// Start the background workers that run the document processing pipeline
+	workers, err := strconv.Atoi(os.Getenv("JOB_WORKERS"))
+	if err != nil || workers < 1 {
+		workers = 2
+	}
+	if err := docService.StartJobWorkers(context.Background(), workers); err != nil {
+		log.Fatalf("Failed to start job workers: %s", err)
+	}
+
 	docController := controller.NewDocumentController(docService)
 
 	router := gin.Default()
@@ -49,6 +69,29 @@ func main() {
 	router.POST("/upload",
 		middleware.StrictRateLimiter.Limit(),
 		docController.UploadDocument)
+	router.GET("/jobs/:id", docController.GetJob)
+
+	router.POST("/documents/reevaluate",
+		middleware.StrictRateLimiter.Limit(),
+		docController.ReevaluateDocuments)
+
+	// Single document detail, update and delete
+	router.GET("/documents/:id", docController.GetDocument)
+	router.PATCH("/documents/:id",
+		middleware.StrictRateLimiter.Limit(),
+		docController.PatchDocument)
+	router.DELETE("/documents/:id",
+		middleware.StrictRateLimiter.Limit(),
+		docController.DeleteDocument)
+
+	// Document version lineage
+	router.GET("/documents/:id/versions", docController.GetDocumentVersions)
+	router.GET("/documents/:id/diff", docController.DiffDocumentVersions)
+	router.GET("/documents/:id/clauses", docController.GetDocumentClauses)
+
+	// Contract obligations and their reminders
+	router.GET("/documents/:id/obligations", docController.GetDocumentObligations)
+	router.GET("/obligations", docController.GetUpcomingObligations)
 
 	// Compliance rules endpoints with strict rate limiting
 	router.POST("/rules",
@@ -57,6 +100,26 @@ func main() {
 
 	router.GET("/rules", docController.GetAllComplianceRules)
 	router.POST("/rules/by-names", docController.GetComplianceRulesByNames)
+	router.POST("/rules/validate", docController.ValidateRule)
+	router.POST("/rules/test",
+		middleware.StrictRateLimiter.Limit(),
+		docController.TestComplianceRule)
+	router.GET("/rules/export", docController.ExportRules)
+	router.POST("/rules/import",
+		middleware.StrictRateLimiter.Limit(),
+		docController.ImportRules)
+	router.GET("/rules/:id", docController.GetComplianceRule)
+	router.PUT("/rules/:id",
+		middleware.StrictRateLimiter.Limit(),
+		docController.UpdateComplianceRule)
+	router.PATCH("/rules/:id",
+		middleware.StrictRateLimiter.Limit(),
+		docController.PatchComplianceRule)
+	router.DELETE("/rules/:id",
+		middleware.StrictRateLimiter.Limit(),
+		docController.DeleteComplianceRule)
+	router.GET("/rules/:id/audit", docController.GetRuleAuditLog)
+	router.GET("/rules/:id/revisions", docController.GetRuleRevisions)
 
 	// Healthcheck endpoint
 	router.GET("/health", func(c *gin.Context) {
@@ -66,6 +129,13 @@ func main() {
 	router.POST("/action-update/:id", docController.AssignActionItem)
 	// Other endpoints
 	router.GET("/search", docController.SearchDocuments)
+	router.GET("/search/semantic", docController.SemanticSearch)
+
+	// Search index maintenance
+	router.POST("/admin/reindex",
+		middleware.StrictRateLimiter.Limit(),
+		docController.ReindexDocuments)
+	router.GET("/admin/index/report", docController.ReconcileIndex)
 	router.GET("/dashboard", docController.GetAllDocuments)
 	router.GET("/action-items", docController.GetPendingActionItemsWithTitles)
 	router.PUT("/action-items/:id/complete",
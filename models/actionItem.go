@@ -5,7 +5,7 @@ import "time"
 type ActionItem struct {
 	ID          string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
 	DocumentID  string `gorm:"type:uuid"`
-	RuleID      string `gorm:"type:uuid"`
+	RuleID      string `gorm:"type:uuid;default:null"`
 	Description string `gorm:"not null"`
 	AssignedTo  string `gorm:"type:string"`
 	Status      string
@@ -13,4 +13,7 @@ type ActionItem struct {
 	DueDate     time.Time
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+
+	// ObligationID is set instead of RuleID on reminders for contract obligations
+	ObligationID *string `gorm:"type:uuid"`
 }
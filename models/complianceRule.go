@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"github.com/lib/pq"
 	"gorm.io/gorm"
 )
 
@@ -19,14 +20,47 @@ type ComplianceRule struct {
 	Description string `elastic:"type:text,analyzer:standard"`
 
 	// Pattern is the regex or keyword pattern for the rule, indexed as a keyword.
+	// See services.CompileRulePattern for the supported forms.
 	Pattern string `elastic:"type:keyword"`
 
+	// Expression is an optional rule expression combining patterns with AND/OR/NOT, counts and
+	// section or page scopes. When set it is evaluated instead of Pattern.
+	// See services.ParseRuleExpression for the syntax.
+	Expression string `elastic:"type:text"`
+
+	// Tags group rules, e.g. by jurisdiction or contract type, and select them for export.
+	Tags pq.StringArray `gorm:"type:text[]" elastic:"type:keyword"`
+
+	// Categories, Jurisdictions, FileTypes and DocumentTags limit the documents the rule is evaluated
+	// against. An empty list places no restriction. See services.DocumentScope.
+	Categories    pq.StringArray `gorm:"type:text[]" elastic:"type:keyword"`
+	Jurisdictions pq.StringArray `gorm:"type:text[]" elastic:"type:keyword"`
+	FileTypes     pq.StringArray `gorm:"type:text[]" elastic:"type:keyword"`
+	DocumentTags  pq.StringArray `gorm:"type:text[]" elastic:"type:keyword"`
+
+	// Requirement is "required" when the pattern must match for the document to pass and
+	// "forbidden" when it must not, indexed as a keyword.
+	Requirement string `gorm:"default:required" elastic:"type:keyword"`
+
 	// Severity indicates the rule's importance (e.g., 'low', 'medium', 'high'), indexed as a keyword.
 	Severity string `elastic:"type:keyword"`
 
+	// CurrentRevisionID references the revision holding the rule's current fields.
+	CurrentRevisionID *string `gorm:"type:uuid" elastic:"type:keyword"`
+
+	// Revision is the number of the current revision.
+	Revision int `elastic:"type:integer"`
+
 	// CreatedAt tracks when the rule was created, indexed as a date.
 	CreatedAt time.Time `elastic:"type:date"`
 
+	// UpdatedAt tracks when the rule was last changed, indexed as a date.
+	UpdatedAt time.Time `elastic:"type:date"`
+
+	// DeletedAt marks the rule as deleted. Deleted rules are no longer evaluated but remain
+	// referenced by the results and action items recorded against them.
+	DeletedAt gorm.DeletedAt `gorm:"index" elastic:"type:date"`
+
 	// SearchContent is a computed field for full-text search, combining Name and Description.
 	// It's not stored in the database but is indexed in Elasticsearch.
 	SearchContent string `gorm:"-" elastic:"type:text,analyzer:standard"`
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ComplianceRuleRevision is an immutable snapshot of a compliance rule. A new revision is written
+// every time the rule changes, so results can be traced back to the exact rule text that produced them.
+type ComplianceRuleRevision struct {
+	// ID is a unique identifier for the revision, stored as a UUID in the database.
+	ID string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id" elastic:"type:keyword"`
+
+	// RuleID references the rule this is a revision of, indexed as a keyword.
+	RuleID string `gorm:"type:uuid" json:"rule_id" elastic:"type:keyword"`
+
+	// Revision starts at 1 and increases with each change to the rule.
+	Revision int `json:"revision" elastic:"type:integer"`
+
+	// Name, Description, Pattern, Severity, Requirement, Expression, Tags and the scope lists are the
+	// rule fields as of this revision.
+	Name        string         `json:"name" elastic:"type:text,analyzer:standard"`
+	Description string         `json:"description" elastic:"type:text,analyzer:standard"`
+	Pattern     string         `json:"pattern" elastic:"type:keyword"`
+	Severity    string         `json:"severity" elastic:"type:keyword"`
+	Requirement string         `json:"requirement" elastic:"type:keyword"`
+	Expression  string         `json:"expression,omitempty" elastic:"type:text"`
+	Tags        pq.StringArray `gorm:"type:text[]" json:"tags" elastic:"type:keyword"`
+
+	Categories    pq.StringArray `gorm:"type:text[]" json:"categories" elastic:"type:keyword"`
+	Jurisdictions pq.StringArray `gorm:"type:text[]" json:"jurisdictions" elastic:"type:keyword"`
+	FileTypes     pq.StringArray `gorm:"type:text[]" json:"file_types" elastic:"type:keyword"`
+	DocumentTags  pq.StringArray `gorm:"type:text[]" json:"document_tags" elastic:"type:keyword"`
+
+	// CreatedBy identifies who made the change, indexed as a keyword.
+	CreatedBy string `json:"created_by" elastic:"type:keyword"`
+
+	// CreatedAt tracks when the revision was written, indexed as a date.
+	CreatedAt time.Time `json:"created_at" elastic:"type:date"`
+}
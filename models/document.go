@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"github.com/lib/pq"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
@@ -19,14 +20,35 @@ type Document struct {
 	// FileType indicates the type of the file (e.g., "pdf", "docx"), indexed as a keyword.
 	FileType string `elastic:"type:keyword"`
 
+	// Category is the kind of document (e.g., "nda", "invoice"), indexed as a keyword. Rules can be
+	// limited to categories; an empty category is unknown.
+	Category string `gorm:"default:null" elastic:"type:keyword"`
+
+	// CategoryConfidence is how sure the classifier is about Category, from 0 to 1, indexed as a float.
+	CategoryConfidence float64 `elastic:"type:float"`
+
+	// CategorySource records where Category came from ("upload", "classifier" or "llm"), indexed as a keyword.
+	CategorySource string `gorm:"default:null" elastic:"type:keyword"`
+
+	// Jurisdiction is the governing jurisdiction of the document (e.g., "india"), indexed as a keyword.
+	Jurisdiction string `gorm:"default:null" elastic:"type:keyword"`
+
+	// Tags are free-form labels supplied at upload, indexed as keywords.
+	Tags pq.StringArray `gorm:"type:text[];default:'{}'" elastic:"type:keyword"`
+
 	// OriginalURL is the S3 URL where the original file is stored, indexed as a keyword.
 	OriginalURL string `elastic:"type:keyword"`
 
+	// ContentHash is the hex-encoded SHA-256 digest of the uploaded file, used to detect re-uploads.
+	ContentHash string `gorm:"default:null" elastic:"type:keyword"`
+
 	// OcrText contains the text extracted via OCR, indexed as text for full-text search.
 	OcrText string `elastic:"type:text,analyzer:standard"`
 
-	// ParsedData is a JSONB field for structured data (e.g., clauses), indexed as an object.
-	ParsedData datatypes.JSON `elastic:"type:object"`
+	// ParsedData is a JSONB list of compliance rule results, indexed as a flattened object so results
+	// of any shape are searchable without mapping conflicts. Clauses are stored separately as
+	// DocumentClause rows.
+	ParsedData datatypes.JSON `elastic:"type:flattened"`
 
 	// RiskScore is a calculated score for compliance risk, indexed as a float.
 	RiskScore float64 `elastic:"type:float"`
@@ -42,7 +64,11 @@ type Document struct {
 
 // BeforeSave is a GORM hook to populate SearchContent before saving to Elasticsearch.
 func (d *Document) BeforeSave(tx *gorm.DB) error {
-	// Combine Title and OcrText for full-text search.
-	d.SearchContent = d.Title + " " + d.OcrText
+	d.PopulateSearchContent()
 	return nil
 }
+
+// PopulateSearchContent combines Title and OcrText for full-text search.
+func (d *Document) PopulateSearchContent() {
+	d.SearchContent = d.Title + " " + d.OcrText
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DocumentChunk is a passage of a document's OCR text with its embedding, used for semantic search.
+// Chunks follow the document's clauses; long clauses are split into several chunks.
+type DocumentChunk struct {
+	// ID is a unique identifier for the chunk, stored as a UUID in the database.
+	ID string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id" elastic:"type:keyword"`
+
+	// DocumentID references the document the chunk was taken from.
+	DocumentID string `gorm:"type:uuid" json:"document_id" elastic:"type:keyword"`
+
+	// ClauseID references the clause the chunk belongs to, if the document's clauses were stored.
+	ClauseID *string `gorm:"type:uuid" json:"clause_id,omitempty" elastic:"type:keyword"`
+
+	// Position is the zero-based order of the chunk within the document.
+	Position int `json:"position" elastic:"type:integer"`
+
+	// Heading is the number and heading of the chunk's clause, indexed as text.
+	Heading string `json:"heading,omitempty" elastic:"type:text,analyzer:standard"`
+
+	// ClauseType is the kind of clause the chunk belongs to, e.g. "confidentiality".
+	ClauseType string `json:"clause_type,omitempty" elastic:"type:keyword"`
+
+	// Content is the text of the chunk, indexed as text.
+	Content string `json:"content" elastic:"type:text,analyzer:standard"`
+
+	// StartOffset and EndOffset are the byte offsets of the chunk within the OCR text.
+	StartOffset int `json:"start_offset" elastic:"type:integer"`
+	EndOffset   int `json:"end_offset" elastic:"type:integer"`
+
+	// Page is the one-based page the chunk starts on.
+	Page int `json:"page" elastic:"type:integer"`
+
+	// EmbeddingModel identifies the provider and model that computed Embedding. Only embeddings of
+	// the same model are compared.
+	EmbeddingModel string `json:"embedding_model" elastic:"type:keyword"`
+
+	// Embedding is the vector of the chunk, stored with pgvector.
+	Embedding Vector `gorm:"type:vector" json:"-" elastic:"type:dense_vector,similarity:cosine"`
+
+	// CreatedAt tracks when the chunk was embedded, indexed as a date.
+	CreatedAt time.Time `json:"created_at" elastic:"type:date"`
+}
+
+// Vector is an embedding stored in a pgvector column, written in its text form "[1,2,3]".
+type Vector []float32
+
+// Value formats the vector for pgvector
+func (v Vector) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, x := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(x), 'f', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String(), nil
+}
+
+// Scan parses the text form of a pgvector value
+func (v *Vector) Scan(src interface{}) error {
+	var text string
+	switch src := src.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case []byte:
+		text = string(src)
+	case string:
+		text = src
+	default:
+		return fmt.Errorf("cannot scan %T into Vector", src)
+	}
+
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "[") || !strings.HasSuffix(text, "]") {
+		return fmt.Errorf("invalid vector %q", text)
+	}
+	text = strings.TrimSpace(text[1 : len(text)-1])
+	if text == "" {
+		*v = Vector{}
+		return nil
+	}
+	parts := strings.Split(text, ",")
+	vector := make(Vector, len(parts))
+	for i, part := range parts {
+		x, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return fmt.Errorf("invalid vector component %q: %w", part, err)
+		}
+		vector[i] = float32(x)
+	}
+	*v = vector
+	return nil
+}
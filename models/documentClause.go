@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// DocumentClause is a numbered section or clause of a document's OCR text.
+type DocumentClause struct {
+	// ID is a unique identifier for the clause, stored as a UUID in the database.
+	ID string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id" elastic:"type:keyword"`
+
+	// DocumentID references the document the clause was extracted from.
+	DocumentID string `gorm:"type:uuid" json:"document_id" elastic:"type:keyword"`
+
+	// Position is the zero-based order of the clause within the document.
+	Position int `json:"position" elastic:"type:integer"`
+
+	// Number is the clause number as written in the document (e.g., "12", "3.1", "IV"), if any.
+	Number string `json:"number,omitempty" elastic:"type:keyword"`
+
+	// Level is the nesting depth of the clause; "3.1" is level 2.
+	Level int `json:"level" elastic:"type:integer"`
+
+	// Heading is the clause heading without its number, indexed as text.
+	Heading string `json:"heading,omitempty" elastic:"type:text,analyzer:standard"`
+
+	// ClauseType is the identified kind of clause (e.g., "termination", "indemnity"), or "other".
+	ClauseType string `json:"clause_type" elastic:"type:keyword"`
+
+	// Text is the full clause text including its heading, indexed as text.
+	Text string `json:"text" elastic:"type:text,analyzer:standard"`
+
+	// StartOffset and EndOffset are the byte offsets of the clause within the OCR text.
+	StartOffset int `json:"start_offset" elastic:"type:integer"`
+	EndOffset   int `json:"end_offset" elastic:"type:integer"`
+
+	// Page is the one-based page the clause starts on.
+	Page int `json:"page" elastic:"type:integer"`
+
+	// CreatedAt tracks when the clause was extracted, indexed as a date.
+	CreatedAt time.Time `json:"created_at" elastic:"type:date"`
+}
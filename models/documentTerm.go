@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Key term types extracted from documents.
+const (
+	TermParty         = "party"
+	TermEffectiveDate = "effective_date"
+	TermDuration      = "term"
+	TermRenewal       = "renewal"
+	TermNoticePeriod  = "notice_period"
+	TermAmount        = "amount"
+	TermGoverningLaw  = "governing_law"
+)
+
+// DocumentTerm is a key term such as a party, date or amount extracted from a document's OCR text.
+type DocumentTerm struct {
+	// ID is a unique identifier for the term, stored as a UUID in the database.
+	ID string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id" elastic:"type:keyword"`
+
+	// DocumentID references the document the term was extracted from.
+	DocumentID string `gorm:"type:uuid" json:"document_id" elastic:"type:keyword"`
+
+	// TermType is the kind of term (see the Term constants), indexed as a keyword.
+	TermType string `json:"term_type" elastic:"type:keyword"`
+
+	// Value is the normalised value, e.g. "Acme Ltd", "2024-01-15", "12 months" or "India".
+	Value string `json:"value" elastic:"type:keyword"`
+
+	// DateValue is set for dates.
+	DateValue *time.Time `gorm:"type:date" json:"date_value,omitempty" elastic:"type:date"`
+
+	// NumberValue is set for amounts and durations, with Unit holding the currency code or time unit.
+	NumberValue *float64 `json:"number_value,omitempty" elastic:"type:double"`
+	Unit        string   `gorm:"default:null" json:"unit,omitempty" elastic:"type:keyword"`
+
+	// SourceText is the text the term was read from, at StartOffset to EndOffset of the OCR text.
+	SourceText  string `json:"source_text" elastic:"type:text"`
+	StartOffset int    `json:"start_offset" elastic:"type:integer"`
+	EndOffset   int    `json:"end_offset" elastic:"type:integer"`
+
+	// Confidence is how reliable the extraction is, from 0 to 1.
+	Confidence float64 `json:"confidence" elastic:"type:float"`
+
+	// CreatedAt tracks when the term was extracted, indexed as a date.
+	CreatedAt time.Time `json:"created_at" elastic:"type:date"`
+}
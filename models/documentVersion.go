@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// DocumentVersion places a document in the version lineage of an agreement.
+type DocumentVersion struct {
+	// ID is a unique identifier for the version entry, stored as a UUID in the database.
+	ID string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" elastic:"type:keyword"`
+
+	// RootDocumentID references the first document of the lineage and groups all its versions.
+	RootDocumentID string `gorm:"type:uuid" elastic:"type:keyword"`
+
+	// DocumentID references the document that is this version.
+	DocumentID string `gorm:"type:uuid" elastic:"type:keyword"`
+
+	// ParentDocumentID references the document this version was uploaded as a revision of.
+	ParentDocumentID *string `gorm:"type:uuid" elastic:"type:keyword"`
+
+	// VersionNumber starts at 1 for the root document and increases with each upload.
+	VersionNumber int `elastic:"type:integer"`
+
+	// CreatedAt tracks when the version was recorded, indexed as a date.
+	CreatedAt time.Time `elastic:"type:date"`
+}
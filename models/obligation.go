@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Obligation types derived from contract text.
+const (
+	ObligationRenewal  = "renewal"
+	ObligationExpiry   = "expiry"
+	ObligationPayment  = "payment"
+	ObligationDeadline = "deadline"
+)
+
+// Obligation statuses.
+const (
+	ObligationOpen      = "open"
+	ObligationCompleted = "completed"
+	ObligationPast      = "past"
+)
+
+// Obligation is a dated commitment found in a document, such as an auto-renewal, the end of the term
+// or a payment date. Open obligations have a reminder action item.
+type Obligation struct {
+	// ID is a unique identifier for the obligation, stored as a UUID in the database.
+	ID string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+
+	// DocumentID references the document the obligation was derived from.
+	DocumentID string `gorm:"type:uuid" json:"document_id"`
+
+	// ObligationType is the kind of obligation (see the Obligation constants).
+	ObligationType string `json:"obligation_type"`
+
+	// Description says what has to happen, e.g. "Contract renews automatically for 1 years".
+	Description string `json:"description"`
+
+	// DueDate is the date in the contract; ActBy is the last day to act on it, such as the last day to
+	// give notice of non-renewal. The reminder action item is due on ActBy.
+	DueDate time.Time `gorm:"type:date" json:"due_date"`
+	ActBy   time.Time `gorm:"type:date" json:"act_by"`
+
+	// Status is open, completed or past (see the Obligation constants).
+	Status string `json:"status"`
+
+	// ActionItemID references the reminder action item of an open obligation.
+	ActionItemID *string `gorm:"type:uuid" json:"action_item_id,omitempty"`
+
+	// SourceText is the text the obligation was derived from, at StartOffset to EndOffset of the OCR text.
+	SourceText  string `json:"source_text"`
+	StartOffset int    `json:"start_offset"`
+	EndOffset   int    `json:"end_offset"`
+
+	// CreatedAt and UpdatedAt track when the obligation was created and last updated.
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
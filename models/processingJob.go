@@ -0,0 +1,116 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Job statuses shared by jobs and their stages.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+	JobStatusPending   = "pending"
+	JobStatusSkipped   = "skipped"
+)
+
+// Job types.
+const (
+	// JobTypeUpload runs an uploaded file through the processing pipeline.
+	JobTypeUpload = "upload"
+	// JobTypeReevaluate reruns compliance for stored documents.
+	JobTypeReevaluate = "reevaluate"
+	// JobTypeReindex rebuilds the search index from stored documents.
+	JobTypeReindex = "reindex"
+)
+
+// ProcessingJob tracks background work: an uploaded document through the asynchronous processing
+// pipeline, a re-evaluation of stored documents, or a rebuild of the search index.
+type ProcessingJob struct {
+	// ID is a unique identifier for the job, stored as a UUID in the database.
+	ID string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+
+	// Type is the kind of job ('upload', 'reevaluate', 'reindex').
+	Type string `gorm:"default:upload" json:"type"`
+
+	// Params is a JSONB object of job-type specific parameters.
+	Params datatypes.JSON `json:"params,omitempty"`
+
+	// Progress is a JSONB JobProgress for jobs that process many items.
+	Progress datatypes.JSON `json:"progress,omitempty"`
+
+	// Status is the overall job status ('queued', 'running', 'completed', 'failed').
+	Status string `json:"status"`
+
+	// CurrentStage is the name of the stage being executed, or the stage that failed.
+	CurrentStage string `json:"current_stage"`
+
+	// Stages is a JSONB list of JobStage entries in pipeline order.
+	Stages datatypes.JSON `json:"stages"`
+
+	// FileName and ContentType describe the uploaded file.
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+
+	// FileID is the storage key of the uploaded file; it is fixed at submission so retries reuse it.
+	FileID string `json:"file_id"`
+
+	// ContentHash is the hex-encoded SHA-256 digest of the uploaded file.
+	ContentHash string `json:"content_hash"`
+
+	// SourceDocumentID references an identical document whose file and OCR text are reused when a
+	// new version of it was requested.
+	SourceDocumentID *string `gorm:"type:uuid" json:"source_document_id,omitempty"`
+
+	// ParentDocumentID references the document this upload is a revision of.
+	ParentDocumentID *string `gorm:"type:uuid" json:"parent_document_id,omitempty"`
+
+	// IdempotencyKey is the client-supplied Idempotency-Key header of the upload request, if any.
+	IdempotencyKey *string `json:"idempotency_key,omitempty"`
+
+	// Input holds the raw uploaded bytes until the job completes so that jobs survive restarts.
+	Input []byte `json:"-"`
+
+	// DocumentID references the document created by the job once it has been persisted.
+	DocumentID *string `gorm:"type:uuid" json:"document_id"`
+
+	// Result is a JSONB summary of the pipeline output (file URL, compliance results, risk score).
+	Result datatypes.JSON `json:"result"`
+
+	// Error holds the error message of the failed stage.
+	Error string `json:"error,omitempty"`
+
+	// Attempts counts how many times a worker has started the job.
+	Attempts int `json:"attempts"`
+
+	// LeaseExpiresAt is when a running job may be claimed by another worker unless the worker running
+	// it renews the lease first.
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// JobProgress reports how far a job that processes many items has come.
+type JobProgress struct {
+	Total     int `json:"total"`
+	Processed int `json:"processed"`
+	Changed   int `json:"changed"`
+	Failed    int `json:"failed"`
+	// Errors maps item IDs to the error that made them fail, capped to keep the job row small.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// JobStage records the status and timing of a single pipeline stage.
+type JobStage struct {
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	DurationMs int64      `json:"duration_ms"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Rule audit actions.
+const (
+	RuleAuditCreate = "create"
+	RuleAuditUpdate = "update"
+	RuleAuditDelete = "delete"
+)
+
+// RuleAuditLog records a single change made to a compliance rule.
+type RuleAuditLog struct {
+	// ID is a unique identifier for the audit entry, stored as a UUID in the database.
+	ID string `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id" elastic:"type:keyword"`
+
+	// RuleID references the rule that was changed, indexed as a keyword.
+	RuleID string `gorm:"type:uuid" json:"rule_id" elastic:"type:keyword"`
+
+	// Action is one of create, update or delete, indexed as a keyword.
+	Action string `json:"action" elastic:"type:keyword"`
+
+	// Actor identifies who made the change, taken from the X-Actor request header.
+	Actor string `json:"actor" elastic:"type:keyword"`
+
+	// Before and After hold the rule as it was before and after the change.
+	Before datatypes.JSON `json:"before,omitempty" elastic:"type:object"`
+	After  datatypes.JSON `json:"after,omitempty" elastic:"type:object"`
+
+	// CreatedAt tracks when the change was made, indexed as a date.
+	CreatedAt time.Time `json:"created_at" elastic:"type:date"`
+}
@@ -12,10 +12,16 @@ import (
 
 	model "github.com/Itish41/LegalEagle/models"
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 // CreateActionItems generates action items for failed compliance rules
 func (s *DocumentService) CreateActionItems(doc model.Document) error {
+	return s.createActionItems(s.db, doc)
+}
+
+// createActionItems generates action items and rule results using db, which may be a transaction
+func (s *DocumentService) createActionItems(db *gorm.DB, doc model.Document) error {
 	var results []map[string]interface{}
 	if err := json.Unmarshal([]byte(doc.ParsedData), &results); err != nil {
 		log.Printf("Error unmarshaling parsed_data: %v", err)
@@ -35,8 +41,13 @@ func (s *DocumentService) CreateActionItems(doc model.Document) error {
 		}
 		log.Printf("Processing failed rule: %s", ruleName)
 
+		// Prefer the rule ID recorded with the result; the rule may have been renamed since.
 		var rule model.ComplianceRule
-		if err := s.db.Where("name = ?", ruleName).First(&rule).Error; err != nil {
+		lookup := db.Where("name = ?", ruleName)
+		if ruleID, ok := result["rule_id"].(string); ok && ruleID != "" {
+			lookup = db.Unscoped().Where("id = ?", ruleID)
+		}
+		if err := lookup.First(&rule).Error; err != nil {
 			log.Printf("Rule %s not found in compliance_rules: %v", ruleName, err)
 			continue
 		}
@@ -46,35 +57,24 @@ func (s *DocumentService) CreateActionItems(doc model.Document) error {
 			continue
 		}
 
-		explanation, _ := result["explanation"].(string)
-		severity, _ := result["severity"].(string)
-		action := model.ActionItem{
-			DocumentID:  doc.ID,
-			RuleID:      rule.ID,
-			Description: fmt.Sprintf("Address %s non-compliance: %s", ruleName, explanation),
-			Priority:    strings.Title(strings.ToLower(severity)), // Use severity from parsed_data
-			Status:      "pending",
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
-			// AssignedTo is intentionally left empty
-			DueDate: time.Now().AddDate(0, 1, 0), // Default due date: 1 month from now
-		}
+		action := newActionItem(doc.ID, rule.ID, ruleName, result)
 
 		// Use Omit to skip the AssignedTo field
-		if err := s.db.Omit("AssignedTo").Create(&action).Error; err != nil {
+		if err := db.Omit("AssignedTo").Create(&action).Error; err != nil {
 			log.Printf("Error creating action item: %v", err)
 			return err
 		}
 		log.Printf("Action item created: %s for document %s", action.Description, doc.ID)
 
 		docResult := model.DocumentRuleResult{
-			DocumentID: doc.ID,
-			RuleID:     rule.ID,
-			Status:     "fail",
-			Details:    datatypes.JSON(marshalResult(result)),
-			CreatedAt:  time.Now(),
+			DocumentID:     doc.ID,
+			RuleID:         rule.ID,
+			Status:         "fail",
+			RuleRevisionID: resultRevisionID(result),
+			Details:        datatypes.JSON(marshalResult(result)),
+			CreatedAt:      time.Now(),
 		}
-		if err := s.db.Create(&docResult).Error; err != nil {
+		if err := db.Create(&docResult).Error; err != nil {
 			log.Printf("Error creating document rule result: %v", err)
 			return err
 		}
@@ -83,6 +83,31 @@ func (s *DocumentService) CreateActionItems(doc model.Document) error {
 	return nil
 }
 
+// newActionItem builds the pending action item for a failed compliance result
+func newActionItem(docID, ruleID, ruleName string, result map[string]interface{}) model.ActionItem {
+	explanation, _ := result["explanation"].(string)
+	severity, _ := result["severity"].(string)
+	return model.ActionItem{
+		DocumentID:  docID,
+		RuleID:      ruleID,
+		Description: fmt.Sprintf("Address %s non-compliance: %s", ruleName, explanation),
+		Priority:    strings.Title(strings.ToLower(severity)), // Use severity from parsed_data
+		Status:      "pending",
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+		// AssignedTo is intentionally left empty
+		DueDate: time.Now().AddDate(0, 1, 0), // Default due date: 1 month from now
+	}
+}
+
+// resultRevisionID returns the rule revision recorded in a compliance result, if any
+func resultRevisionID(result map[string]interface{}) *string {
+	if id, ok := result["rule_revision_id"].(string); ok && id != "" {
+		return &id
+	}
+	return nil
+}
+
 // Helper to marshal result into JSON bytes
 func marshalResult(result map[string]interface{}) []byte {
 	bytes, err := json.Marshal(result)
@@ -174,15 +199,16 @@ func (s *DocumentService) GetPendingActionItemsWithTitles() ([]map[string]interf
 			continue
 		}
 		result = append(result, map[string]interface{}{
-			"id":          item.ID,
-			"document_id": item.DocumentID,
-			"title":       doc.Title,
-			"rule_id":     item.RuleID,
-			"description": item.Description,
-			"priority":    item.Priority,
-			"assigned_to": item.AssignedTo,
-			"due_date":    item.DueDate,
-			"status":      item.Status,
+			"id":            item.ID,
+			"document_id":   item.DocumentID,
+			"title":         doc.Title,
+			"rule_id":       item.RuleID,
+			"obligation_id": item.ObligationID,
+			"description":   item.Description,
+			"priority":      item.Priority,
+			"assigned_to":   item.AssignedTo,
+			"due_date":      item.DueDate,
+			"status":        item.Status,
 		})
 	}
 	return result, nil
@@ -208,6 +234,16 @@ func (s *DocumentService) UpdateActionItem(actionID string) error {
 		return err
 	}
 
+	// Obligation reminders have no rule result to resolve.
+	if action.ObligationID != nil {
+		if err := s.completeObligation(*action.ObligationID); err != nil {
+			log.Printf("[UpdateActionItem] Error completing obligation %s: %v", *action.ObligationID, err)
+			return err
+		}
+		log.Printf("[UpdateActionItem] Completed obligation %s for action %s", *action.ObligationID, actionID)
+		return nil
+	}
+
 	var docResult model.DocumentRuleResult
 	if err := s.db.Where("document_id = ? AND rule_id = ?", action.DocumentID, action.RuleID).First(&docResult).Error; err != nil {
 		log.Printf("[UpdateActionItem] Error fetching document rule result for action %s: %v", actionID, err)
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Document categories assigned by the classifier.
+const (
+	CategoryNDA        = "nda"
+	CategoryMSA        = "msa"
+	CategoryInvoice    = "invoice"
+	CategoryLease      = "lease"
+	CategoryEmployment = "employment"
+	CategoryOther      = "other"
+)
+
+// Sources of a document's category.
+const (
+	CategorySourceUpload     = "upload"
+	CategorySourceClassifier = "classifier"
+	CategorySourceLLM        = "llm"
+)
+
+// DefaultClassifierMinConfidence is the confidence below which a classified category is not used to
+// select rules and, if enabled, the LLM is asked instead.
+const DefaultClassifierMinConfidence = 0.5
+
+// classifierHeadLength is the length of the opening of a document, usually its title and recitals,
+// where keyword hits count double.
+const classifierHeadLength = 600
+
+// classifierStrongEvidence is the keyword score at which the classifier trusts its best category fully.
+const classifierStrongEvidence = 6.0
+
+// categoryKeyword is a phrase that indicates a category, with its weight.
+type categoryKeyword struct {
+	phrase string
+	weight float64
+}
+
+// categoryProfiles lists the keywords of each category. Order decides ties.
+var categoryProfiles = []struct {
+	category string
+	keywords []categoryKeyword
+}{
+	{CategoryNDA, []categoryKeyword{
+		{"non-disclosure agreement", 4}, {"nondisclosure agreement", 4}, {"confidentiality agreement", 4},
+		{"confidential information", 2}, {"disclosing party", 2}, {"receiving party", 2},
+		{"non-disclosure", 1}, {"trade secrets", 1},
+	}},
+	{CategoryMSA, []categoryKeyword{
+		{"master services agreement", 4}, {"master service agreement", 4}, {"statement of work", 2},
+		{"service levels", 2}, {"service provider", 1}, {"deliverables", 1}, {"professional services", 1},
+		{"limitation of liability", 1},
+	}},
+	{CategoryInvoice, []categoryKeyword{
+		{"invoice", 3}, {"invoice number", 3}, {"amount due", 2}, {"bill to", 2}, {"subtotal", 2},
+		{"payment due", 1}, {"gst", 1}, {"tax invoice", 3}, {"unit price", 1},
+	}},
+	{CategoryLease, []categoryKeyword{
+		{"lease agreement", 4}, {"rental agreement", 4}, {"landlord", 2}, {"tenant", 2}, {"lessor", 2},
+		{"lessee", 2}, {"premises", 1}, {"security deposit", 1}, {"monthly rent", 2},
+	}},
+	{CategoryEmployment, []categoryKeyword{
+		{"employment agreement", 4}, {"employment contract", 4}, {"offer letter", 3}, {"employee", 1},
+		{"employer", 1}, {"salary", 2}, {"probation", 2}, {"job title", 1}, {"notice period", 1},
+	}},
+}
+
+// knownCategories lists every category the classifier can return, including "other"
+func knownCategories() []string {
+	categories := make([]string, 0, len(categoryProfiles)+1)
+	for _, profile := range categoryProfiles {
+		categories = append(categories, profile.category)
+	}
+	return append(categories, CategoryOther)
+}
+
+// Classification is the category assigned to a document and how sure the assignment is.
+type Classification struct {
+	Category   string             `json:"category"`
+	Confidence float64            `json:"confidence"`
+	Source     string             `json:"source"`
+	Scores     map[string]float64 `json:"scores,omitempty"`
+}
+
+// ClassifyText assigns a category to text using weighted keywords. Confidence is the best category's
+// share of all keyword evidence, scaled down while the evidence is weak. Text without any keyword is
+// classified as "other" with zero confidence.
+func ClassifyText(text string) Classification {
+	scores := make(map[string]float64, len(categoryProfiles))
+	best, total := "", 0.0
+	for _, profile := range categoryProfiles {
+		score := 0.0
+		for _, keyword := range profile.keywords {
+			for i, match := range findKeyword(text, keyword.phrase) {
+				// Repeated phrases add little once the point is made.
+				if i == 3 {
+					break
+				}
+				weight := keyword.weight
+				if match.Start < classifierHeadLength {
+					weight *= 2
+				}
+				score += weight
+			}
+		}
+		if score == 0 {
+			continue
+		}
+		scores[profile.category] = score
+		total += score
+		if best == "" || score > scores[best] {
+			best = profile.category
+		}
+	}
+
+	if best == "" {
+		return Classification{Category: CategoryOther, Confidence: 0, Source: CategorySourceClassifier, Scores: scores}
+	}
+	confidence := scores[best] / total * math.Min(1, scores[best]/classifierStrongEvidence)
+	return Classification{
+		Category:   best,
+		Confidence: math.Round(confidence*100) / 100,
+		Source:     CategorySourceClassifier,
+		Scores:     scores,
+	}
+}
+
+// ClassifierConfig configures document classification.
+type ClassifierConfig struct {
+	// MinConfidence is the confidence a classified category needs to be used for rule selection; nil
+	// means DefaultClassifierMinConfidence. Zero accepts every classified category.
+	MinConfidence *float64
+	// LLMFallback asks the LLM to classify documents the keyword classifier is unsure about.
+	LLMFallback bool
+}
+
+// ClassifierConfigFromEnv reads CLASSIFIER_MIN_CONFIDENCE and CLASSIFIER_LLM_FALLBACK
+func ClassifierConfigFromEnv() ClassifierConfig {
+	var cfg ClassifierConfig
+	if value, err := strconv.ParseFloat(os.Getenv("CLASSIFIER_MIN_CONFIDENCE"), 64); err == nil && value >= 0 && value <= 1 {
+		cfg.MinConfidence = &value
+	}
+	cfg.LLMFallback = strings.EqualFold(os.Getenv("CLASSIFIER_LLM_FALLBACK"), "true")
+	return cfg
+}
+
+// SetClassifierConfig replaces the document classification settings
+func (s *DocumentService) SetClassifierConfig(cfg ClassifierConfig) {
+	s.classifier = cfg
+}
+
+// minConfidence returns the configured minimum confidence, defaulting to DefaultClassifierMinConfidence
+func (c ClassifierConfig) minConfidence() float64 {
+	if c.MinConfidence == nil {
+		return DefaultClassifierMinConfidence
+	}
+	return *c.MinConfidence
+}
+
+// classifierMinConfidence returns the minimum confidence of the service's classifier settings
+func (s *DocumentService) classifierMinConfidence() float64 {
+	return s.classifier.minConfidence()
+}
+
+// classifyDocument classifies text locally and, when the result is unsure and the fallback is
+// enabled, asks the LLM. LLM failures keep the local result.
+func (s *DocumentService) classifyDocument(ctx context.Context, text string) Classification {
+	local := ClassifyText(text)
+	log.Printf("Classified document as %s (confidence %.2f, scores %v)", local.Category, local.Confidence, local.Scores)
+	if local.Confidence >= s.classifierMinConfidence() || !s.classifier.LLMFallback || s.llm == nil {
+		return local
+	}
+
+	classification, err := s.classifyWithLLM(ctx, text)
+	if err != nil {
+		log.Printf("ERROR classifying document with LLM: %v", err)
+		return local
+	}
+	log.Printf("LLM classified document as %s (confidence %.2f)", classification.Category, classification.Confidence)
+	return classification
+}
+
+// classifyWithLLM asks the LLM for the category of text
+func (s *DocumentService) classifyWithLLM(ctx context.Context, text string) (Classification, error) {
+	if !groqRateLimiter.Allow("groq_api_call") {
+		return Classification{}, ErrLLMRateLimited
+	}
+
+	// The opening of a document is enough to tell its type.
+	excerpt := text
+	if len(excerpt) > 4000 {
+		end := 4000
+		for end > 0 && !utf8.RuneStart(excerpt[end]) {
+			end--
+		}
+		excerpt = excerpt[:end]
+	}
+	categories := knownCategories()
+	prompt := fmt.Sprintf(`
+    Classify the following legal document into exactly one of these categories: %s.
+
+    Document Text:
+    %s
+
+    Respond with a JSON object of the form {"category": "<category>", "confidence": <number between 0 and 1>}.
+    `, strings.Join(categories, ", "), excerpt)
+
+	content, err := s.llm.Complete(ctx, ChatRequest{
+		Task:         LLMTaskClassification,
+		Messages:     []ChatMessage{{Role: "user", Content: prompt}},
+		Temperature:  0,
+		MaxTokens:    50,
+		JSONResponse: true,
+	})
+	if err != nil {
+		return Classification{}, err
+	}
+
+	var response struct {
+		Category   string  `json:"category"`
+		Confidence float64 `json:"confidence"`
+	}
+	if err := json.Unmarshal([]byte(content), &response); err != nil {
+		return Classification{}, fmt.Errorf("failed to parse classification: %w", err)
+	}
+	category := strings.ToLower(strings.TrimSpace(response.Category))
+	if !contains(categories, category) {
+		return Classification{}, fmt.Errorf("unknown category %q", response.Category)
+	}
+	return Classification{
+		Category:   category,
+		Confidence: math.Max(0, math.Min(1, response.Confidence)),
+		Source:     CategorySourceLLM,
+	}, nil
+}
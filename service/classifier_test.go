@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyText(t *testing.T) {
+	nda := ClassifyText("MUTUAL NON-DISCLOSURE AGREEMENT\nThe Disclosing Party shall share Confidential Information with the Receiving Party.")
+	assert.Equal(t, CategoryNDA, nda.Category)
+	assert.Equal(t, CategorySourceClassifier, nda.Source)
+	assert.Equal(t, 1.0, nda.Confidence)
+
+	invoice := ClassifyText("TAX INVOICE\nInvoice Number: 1042\nBill To: Acme Ltd\nSubtotal: 1,000\nAmount Due: 1,180")
+	assert.Equal(t, CategoryInvoice, invoice.Category)
+	assert.Greater(t, invoice.Confidence, DefaultClassifierMinConfidence)
+
+	// A single weak hit is a guess, not a classification.
+	weak := ClassifyText("The employee list is attached.")
+	assert.Equal(t, CategoryEmployment, weak.Category)
+	assert.Less(t, weak.Confidence, DefaultClassifierMinConfidence)
+
+	none := ClassifyText("Minutes of the quarterly board meeting.")
+	assert.Equal(t, CategoryOther, none.Category)
+	assert.Equal(t, 0.0, none.Confidence)
+}
+
+func TestClassifyDocument_LLMFallback(t *testing.T) {
+	stub := NewStubLLMClient(map[LLMTask]string{
+		LLMTaskClassification: `{"category": "Lease", "confidence": 0.8}`,
+	})
+	s := &DocumentService{llm: stub, classifier: ClassifierConfig{LLMFallback: true}}
+
+	classification := s.classifyDocument(context.Background(), "The premises at 12 Park Street.")
+	assert.Equal(t, Classification{Category: CategoryLease, Confidence: 0.8, Source: CategorySourceLLM}, classification)
+	require.Len(t, stub.Requests, 1)
+
+	// Confident local results and a disabled fallback never reach the LLM.
+	s.classifyDocument(context.Background(), "NON-DISCLOSURE AGREEMENT between the Disclosing Party and the Receiving Party")
+	s.classifier.LLMFallback = false
+	s.classifyDocument(context.Background(), "The premises at 12 Park Street.")
+	assert.Len(t, stub.Requests, 1)
+
+	// Unknown categories from the LLM are ignored.
+	stub.Responses[LLMTaskClassification] = `{"category": "poem", "confidence": 0.9}`
+	s.classifier.LLMFallback = true
+	classification = s.classifyDocument(context.Background(), "The premises at 12 Park Street.")
+	assert.Equal(t, CategorySourceClassifier, classification.Source)
+}
+
+func TestClassifierConfigFromEnv(t *testing.T) {
+	t.Setenv("CLASSIFIER_MIN_CONFIDENCE", "")
+	assert.Equal(t, DefaultClassifierMinConfidence, ClassifierConfigFromEnv().minConfidence())
+
+	t.Setenv("CLASSIFIER_MIN_CONFIDENCE", "0")
+	cfg := ClassifierConfigFromEnv()
+	assert.Equal(t, 0.0, cfg.minConfidence(), "zero accepts every classified category")
+	s := &DocumentService{classifier: cfg}
+	assert.Equal(t, 0.0, s.classifierMinConfidence())
+
+	t.Setenv("CLASSIFIER_MIN_CONFIDENCE", "1.5")
+	assert.Equal(t, DefaultClassifierMinConfidence, ClassifierConfigFromEnv().minConfidence())
+}
+
+func TestClassifyWithLLM_TruncatesAtRuneBoundary(t *testing.T) {
+	stub := NewStubLLMClient(map[LLMTask]string{
+		LLMTaskClassification: `{"category": "nda", "confidence": 0.9}`,
+	})
+	s := &DocumentService{llm: stub}
+
+	text := strings.Repeat("a", 3999) + strings.Repeat("ü", 10)
+	_, err := s.classifyWithLLM(context.Background(), text)
+	require.NoError(t, err)
+	require.Len(t, stub.Requests, 1)
+	prompt := stub.Requests[0].Messages[0].Content
+	assert.True(t, utf8.ValidString(prompt), "the excerpt must not end inside a character")
+	assert.Contains(t, prompt, strings.Repeat("a", 3999)+"\n")
+}
+
+func TestStageClassify(t *testing.T) {
+	s := &DocumentService{}
+
+	supplied := &pipelineState{ocrText: "TAX INVOICE", scope: DocumentScope{Category: "NDA"}}
+	assert.ErrorIs(t, s.stageClassify(context.Background(), supplied), errStageSkipped)
+	assert.Equal(t, Classification{Category: CategoryNDA, Confidence: 1, Source: CategorySourceUpload}, supplied.classification)
+
+	unsure := &pipelineState{ocrText: "The employee list is attached."}
+	require.NoError(t, s.stageClassify(context.Background(), unsure))
+	assert.Equal(t, CategoryEmployment, unsure.classification.Category)
+	assert.Empty(t, unsure.scope.Category, "unsure categories do not select rules")
+}
+
+func TestDocumentScope_IgnoresUnsureCategories(t *testing.T) {
+	s := &DocumentService{}
+	assert.Equal(t, "nda", s.documentScope(models.Document{Category: "nda", CategoryConfidence: 0.9, CategorySource: CategorySourceClassifier}).Category)
+	assert.Equal(t, "", s.documentScope(models.Document{Category: "nda", CategoryConfidence: 0.2, CategorySource: CategorySourceClassifier}).Category)
+	assert.Equal(t, "nda", s.documentScope(models.Document{Category: "nda", CategoryConfidence: 0.2, CategorySource: CategorySourceUpload}).Category)
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Clause types identified by the clause extractor.
+const (
+	ClauseTypeTermination     = "termination"
+	ClauseTypeIndemnity       = "indemnity"
+	ClauseTypeGoverningLaw    = "governing_law"
+	ClauseTypeConfidentiality = "confidentiality"
+	ClauseTypePayment         = "payment"
+	ClauseTypeOther           = "other"
+)
+
+// clauseTypeProfiles lists the phrases that identify each clause type. Order decides ties.
+var clauseTypeProfiles = []struct {
+	clauseType string
+	phrases    []string
+}{
+	{ClauseTypeTermination, []string{"termination", "terminate", "terminated", "expiry"}},
+	{ClauseTypeIndemnity, []string{"indemnity", "indemnification", "indemnify", "indemnified", "hold harmless"}},
+	{ClauseTypeGoverningLaw, []string{"governing law", "applicable law", "jurisdiction", "governed by", "laws of"}},
+	{ClauseTypeConfidentiality, []string{"confidentiality", "confidential information", "confidential", "non-disclosure"}},
+	{ClauseTypePayment, []string{"payment", "payments", "fees", "invoice", "invoices", "compensation", "price"}},
+}
+
+// clauseNumberPrefix splits a heading such as "12.1 Fees", "Article IV - Term" or "3) Payment" into
+// its number and title.
+var clauseNumberPrefix = regexp.MustCompile(`(?i)^(?:(?:section|article|clause|schedule)\s+([\dIVXLC]+)|(\d+(?:\.\d+)*))[.:)]?\s*(?:[-–—:]\s*)?`)
+
+// paragraphBreak separates paragraphs of documents without headings.
+var paragraphBreak = regexp.MustCompile(`\n[ \t\f]*\n`)
+
+// minClauseTypeScore is the evidence a clause type needs; a heading hit alone is enough.
+const minClauseTypeScore = 2
+
+// ExtractClauses segments text into clauses at the headings found by the section detector. Text
+// before the first heading becomes an untitled clause. Documents without headings are split into
+// paragraphs. Offsets refer to text; DocumentID is left for the caller to set.
+func ExtractClauses(text string) []model.DocumentClause {
+	var spans []textSpan
+	var headings []string
+	sections := detectSections(text)
+	if len(sections) > 0 {
+		if sections[0].span.start > 0 {
+			spans = append(spans, textSpan{0, sections[0].span.start})
+			headings = append(headings, "")
+		}
+		for _, section := range sections {
+			spans = append(spans, section.span)
+			headings = append(headings, section.Heading)
+		}
+	} else {
+		start := 0
+		for _, loc := range paragraphBreak.FindAllStringIndex(text, -1) {
+			spans = append(spans, textSpan{start, loc[0]})
+			headings = append(headings, "")
+			start = loc[1]
+		}
+		spans = append(spans, textSpan{start, len(text)})
+		headings = append(headings, "")
+	}
+
+	pages := splitPages(text)
+	clauses := make([]model.DocumentClause, 0, len(spans))
+	for i, span := range spans {
+		span = trimSpan(text, span)
+		if span.start >= span.end {
+			continue
+		}
+		number, title, level := splitClauseHeading(headings[i])
+		body := strings.ReplaceAll(text[span.start:span.end], PageSeparator, "\n")
+		clauses = append(clauses, model.DocumentClause{
+			Position:    len(clauses),
+			Number:      number,
+			Level:       level,
+			Heading:     title,
+			ClauseType:  classifyClause(title, body),
+			Text:        body,
+			StartOffset: span.start,
+			EndOffset:   span.end,
+			Page:        pageOf(pages, span.start),
+		})
+	}
+	return clauses
+}
+
+// trimSpan shrinks span to exclude surrounding whitespace and page separators
+func trimSpan(text string, span textSpan) textSpan {
+	const space = " \t\r\n" + PageSeparator
+	for span.start < span.end && strings.ContainsRune(space, rune(text[span.start])) {
+		span.start++
+	}
+	for span.end > span.start && strings.ContainsRune(space, rune(text[span.end-1])) {
+		span.end--
+	}
+	return span
+}
+
+// splitClauseHeading separates the number from a heading and derives the nesting level
+func splitClauseHeading(heading string) (number, title string, level int) {
+	loc := clauseNumberPrefix.FindStringSubmatchIndex(heading)
+	if loc == nil {
+		return "", heading, 1
+	}
+	switch {
+	case loc[2] >= 0:
+		number = heading[loc[2]:loc[3]]
+		level = 1
+	case loc[4] >= 0:
+		number = heading[loc[4]:loc[5]]
+		level = strings.Count(number, ".") + 1
+	}
+	return number, strings.TrimSpace(heading[loc[1]:]), level
+}
+
+// classifyClause identifies the clause type from its heading and, more weakly, its text
+func classifyClause(heading, body string) string {
+	best, bestScore := ClauseTypeOther, 0
+	for _, profile := range clauseTypeProfiles {
+		score := 0
+		for _, phrase := range profile.phrases {
+			if heading != "" && len(findKeyword(heading, phrase)) > 0 {
+				score += minClauseTypeScore
+			}
+			if hits := len(findKeyword(body, phrase)); hits > 3 {
+				score += 3
+			} else {
+				score += hits
+			}
+		}
+		if score >= minClauseTypeScore && score > bestScore {
+			best, bestScore = profile.clauseType, score
+		}
+	}
+	return best
+}
+
+// pageOf returns the one-based page containing offset
+func pageOf(pages []textSpan, offset int) int {
+	for i, page := range pages {
+		if offset < page.end+len(PageSeparator) {
+			return i + 1
+		}
+	}
+	return len(pages)
+}
+
+// stageClauses splits the OCR text into clauses; they are saved with the document
+func (s *DocumentService) stageClauses(ctx context.Context, state *pipelineState) error {
+	state.clauses = ExtractClauses(state.ocrText)
+	log.Printf("Extracted %d clauses", len(state.clauses))
+	return nil
+}
+
+// saveClauses stores clauses for docID using tx. Clauses already stored at the same positions are kept.
+func saveClauses(tx *gorm.DB, docID string, clauses []model.DocumentClause) error {
+	if len(clauses) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for i := range clauses {
+		clauses[i].DocumentID = docID
+		clauses[i].CreatedAt = now
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&clauses).Error
+}
+
+// GetDocumentClauses lists the clauses of a document in order, optionally only those of clauseType.
+// Documents processed before clause extraction existed have their clauses extracted and stored on
+// first request.
+func (s *DocumentService) GetDocumentClauses(docID, clauseType string) ([]model.DocumentClause, error) {
+	if !uuidPattern.MatchString(docID) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var doc model.Document
+	if err := s.db.Select("id", "ocr_text").First(&doc, "id = ?", docID).Error; err != nil {
+		log.Printf("[GetDocumentClauses] Error fetching document %s: %v", docID, err)
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&model.DocumentClause{}).Where("document_id = ?", docID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 && doc.OcrText != "" {
+		if err := saveClauses(s.db, docID, ExtractClauses(doc.OcrText)); err != nil {
+			log.Printf("[GetDocumentClauses] Error saving clauses of %s: %v", docID, err)
+			return nil, err
+		}
+	}
+
+	query := s.db.Where("document_id = ?", docID).Order("position")
+	if clauseType = strings.ToLower(strings.TrimSpace(clauseType)); clauseType != "" {
+		query = query.Where("clause_type = ?", clauseType)
+	}
+	clauses := []model.DocumentClause{}
+	if err := query.Find(&clauses).Error; err != nil {
+		log.Printf("[GetDocumentClauses] Error fetching clauses of %s: %v", docID, err)
+		return nil, err
+	}
+	return clauses, nil
+}
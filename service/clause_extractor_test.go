@@ -0,0 +1,66 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestExtractClauses(t *testing.T) {
+	text := "SERVICES AGREEMENT\nThis Agreement is made between Acme and Beta.\n\n" +
+		"1. Payment\nFees are payable within 30 days of invoice.\n" +
+		"1.1 Late Payment\nInterest accrues on overdue amounts.\n" +
+		"\f2. Termination\nEither party may terminate on 60 days notice.\n" +
+		"Article III - Miscellaneous\nThis Agreement is governed by the laws of India and the courts of Delhi have jurisdiction.\n"
+
+	clauses := ExtractClauses(text)
+	require.Len(t, clauses, 5)
+
+	assert.Equal(t, "SERVICES AGREEMENT", clauses[0].Heading)
+	assert.Equal(t, "", clauses[0].Number)
+
+	assert.Equal(t, "1", clauses[1].Number)
+	assert.Equal(t, "Payment", clauses[1].Heading)
+	assert.Equal(t, 1, clauses[1].Level)
+	assert.Equal(t, ClauseTypePayment, clauses[1].ClauseType)
+	assert.Equal(t, "1. Payment\nFees are payable within 30 days of invoice.", clauses[1].Text)
+	assert.Equal(t, clauses[1].Text, text[clauses[1].StartOffset:clauses[1].EndOffset])
+	assert.Equal(t, 1, clauses[1].Page)
+
+	assert.Equal(t, "1.1", clauses[2].Number)
+	assert.Equal(t, 2, clauses[2].Level)
+	assert.Equal(t, ClauseTypePayment, clauses[2].ClauseType)
+
+	assert.Equal(t, "Termination", clauses[3].Heading)
+	assert.Equal(t, ClauseTypeTermination, clauses[3].ClauseType)
+	assert.Equal(t, 2, clauses[3].Page)
+
+	// Body text decides when the heading says nothing.
+	assert.Equal(t, "III", clauses[4].Number)
+	assert.Equal(t, "Miscellaneous", clauses[4].Heading)
+	assert.Equal(t, ClauseTypeGoverningLaw, clauses[4].ClauseType)
+
+	for i, clause := range clauses {
+		assert.Equal(t, i, clause.Position)
+	}
+}
+
+func TestExtractClauses_ParagraphsWithoutHeadings(t *testing.T) {
+	clauses := ExtractClauses("The parties agree as follows.\n\nAll information shared is confidential information and must not be disclosed.\n")
+	require.Len(t, clauses, 2)
+	assert.Equal(t, ClauseTypeOther, clauses[0].ClauseType)
+	assert.Equal(t, ClauseTypeConfidentiality, clauses[1].ClauseType)
+	assert.Empty(t, clauses[1].Heading)
+	assert.Empty(t, ExtractClauses(" \n\n "))
+}
+
+func TestGetDocumentClauses_NonUUIDIsNotFound(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	s := &DocumentService{db: db}
+
+	_, err := s.GetDocumentClauses("not-a-uuid", "")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Empty(t, fake.executed(`.`))
+}
@@ -1,14 +1,10 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"os"
 	"regexp"
 	"strings"
 	"sync"
@@ -16,6 +12,7 @@ import (
 
 	// "github.com/Itish41/LegalEagle/models
 	model "github.com/Itish41/LegalEagle/models"
+	"gorm.io/gorm"
 )
 
 // RateLimiter struct to manage API call rate limiting
@@ -53,19 +50,69 @@ func (rl *RateLimiter) Allow(key string) bool {
 	return rl.requestCount[key] <= rl.limit
 }
 
+// Wait blocks until a request for key is allowed or ctx is done
+func (rl *RateLimiter) Wait(ctx context.Context, key string) error {
+	for !rl.Allow(key) {
+		rl.mu.Lock()
+		wait := rl.window - time.Since(rl.lastReset)
+		rl.mu.Unlock()
+		if wait <= 0 {
+			wait = time.Millisecond
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return nil
+}
+
+// llmFallback selects what happens when the LLM cannot judge a document: the keyword fallback
+// guesses the violated rules, or the evaluation fails. Guesses are fine for a first look at an
+// upload but must never replace stored results.
+type llmFallback bool
+
+const (
+	withLLMFallback    llmFallback = true
+	withoutLLMFallback llmFallback = false
+)
+
 // Global rate limiters for different operations
 var (
 	groqRateLimiter = NewRateLimiter(50, 1*time.Minute)  // 50 Groq API calls per minute
 	ruleRateLimiter = NewRateLimiter(100, 1*time.Minute) // 100 rule-related operations per minute
 )
 
-func (s *DocumentService) AddComplianceRule(rule *model.ComplianceRule) error {
+// AddComplianceRule validates and stores a new rule, recording actor in the rule's audit log
+func (s *DocumentService) AddComplianceRule(rule *model.ComplianceRule, actor string) error {
 	// Rate limit rule additions
 	if !ruleRateLimiter.Allow("rule_addition") {
 		return fmt.Errorf("rate limit exceeded for rule additions")
 	}
 
-	if err := s.db.Create(rule).Error; err != nil {
+	// Only the editable fields come from the caller; the ID, revision and deletion are set here.
+	*rule = model.ComplianceRule{
+		Name:          rule.Name,
+		Description:   rule.Description,
+		Pattern:       rule.Pattern,
+		Severity:      rule.Severity,
+		Requirement:   rule.Requirement,
+		Expression:    rule.Expression,
+		Tags:          rule.Tags,
+		Categories:    rule.Categories,
+		Jurisdictions: rule.Jurisdictions,
+		FileTypes:     rule.FileTypes,
+		DocumentTags:  rule.DocumentTags,
+	}
+	if err := validateComplianceRule(rule); err != nil {
+		return err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return createRule(tx, rule, actor)
+	})
+	if err != nil {
 		log.Printf("Error saving compliance rule: %v", err)
 		return err
 	}
@@ -73,14 +120,8 @@ func (s *DocumentService) AddComplianceRule(rule *model.ComplianceRule) error {
 	return nil
 }
 
-// DetermineApplicableRules uses Groq to suggest relevant rules
-func (s *DocumentService) DetermineApplicableRules(ocrText string) ([]string, error) {
-	// Rate limit Groq API calls
-	if !groqRateLimiter.Allow("groq_api_call") {
-		log.Println("Rate limit exceeded for Groq API calls locally")
-		return s.fallbackRuleExtraction(ocrText, nil), nil
-	}
-
+// DetermineApplicableRules uses Groq to suggest relevant rules among those in the document's scope
+func (s *DocumentService) DetermineApplicableRules(ocrText string, scope DocumentScope) ([]string, error) {
 	// Fetch all rules from the database
 	allRules, err := s.GetAllComplianceRules()
 	if err != nil {
@@ -89,6 +130,27 @@ func (s *DocumentService) DetermineApplicableRules(ocrText string) ([]string, er
 	}
 	log.Printf("Retrieved %d compliance rules from database", len(allRules))
 
+	applicable, _ := scopeRules(allRules, scope)
+	if len(applicable) == 0 {
+		return []string{}, nil
+	}
+	return s.determineViolatedRules(context.Background(), ocrText, applicable, withLLMFallback)
+}
+
+// determineViolatedRules asks the LLM which of allRules the document violates. With the fallback,
+// a local rate limit or a failed LLM call is answered by fallbackRuleExtraction; without it, the
+// call waits for the rate limiter and LLM failures are returned.
+func (s *DocumentService) determineViolatedRules(ctx context.Context, ocrText string, allRules []model.ComplianceRule, fallback llmFallback) ([]string, error) {
+	// Rate limit Groq API calls
+	if fallback == withoutLLMFallback {
+		if err := groqRateLimiter.Wait(ctx, "groq_api_call"); err != nil {
+			return nil, err
+		}
+	} else if !groqRateLimiter.Allow("groq_api_call") {
+		log.Println("Rate limit exceeded for Groq API calls locally")
+		return s.fallbackRuleExtraction(ocrText, nil), nil
+	}
+
 	// Build rule details and names
 	var ruleDetails []string
 	ruleNames := make([]string, len(allRules))
@@ -98,11 +160,9 @@ func (s *DocumentService) DetermineApplicableRules(ocrText string) ([]string, er
 	}
 	log.Println("Rule details for Groq: ", ruleDetails)
 
-	// Validate Groq API Key
-	groqAPIKey := os.Getenv("VITE_GROQ_API_KEY")
-	if groqAPIKey == "" {
-		log.Println("ERROR: VITE_GROQ_API_KEY environment variable is not set")
-		return nil, fmt.Errorf("VITE_GROQ_API_KEY environment variable is not set")
+	if s.llm == nil {
+		log.Println("ERROR: LLM client is not configured")
+		return nil, fmt.Errorf("LLM client is not configured")
 	}
 
 	// Construct prompt
@@ -127,89 +187,31 @@ func (s *DocumentService) DetermineApplicableRules(ocrText string) ([]string, er
     `, strings.Join(ruleDetails, "\n"), ocrText)
 	log.Printf("Groq API Prompt: %s", prompt)
 
-	// Prepare request body
-	reqBody, err := json.Marshal(map[string]interface{}{
-		"messages": []map[string]string{
-			{"role": "user", "content": prompt},
-		},
-		"model":       "llama-3.3-70b-versatile",
-		"temperature": 0.7,
-		"max_tokens":  250,
-		"response_format": map[string]string{
-			"type": "json_object",
-		},
+	content, err := s.llm.Complete(ctx, ChatRequest{
+		Task:         LLMTaskRuleDetection,
+		Messages:     []ChatMessage{{Role: "user", Content: prompt}},
+		Temperature:  0.7,
+		MaxTokens:    250,
+		JSONResponse: true,
 	})
 	if err != nil {
-		log.Printf("ERROR creating request body: %v", err)
-		return s.fallbackRuleExtraction(ocrText, ruleNames), nil
-	}
-
-	// Retry logic for rate limiting
-	const maxRetries = 3
-	var resp *http.Response
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		req, err := http.NewRequest("POST", "https://api.groq.com/openai/v1/chat/completions", bytes.NewBuffer(reqBody))
-		if err != nil {
-			log.Printf("ERROR creating Groq request: %v", err)
-			return nil, fmt.Errorf("failed to create Groq request: %w", err)
-		}
-		req.Header.Set("Authorization", "Bearer "+groqAPIKey)
-		req.Header.Set("Content-Type", "application/json")
-
-		client := &http.Client{Timeout: 30 * time.Second}
-		resp, err = client.Do(req)
-		if err == nil && resp.StatusCode != 429 { // 429 is Too Many Requests
-			break
-		}
-		if err != nil {
-			log.Printf("ERROR sending Groq request (attempt %d): %v", attempt+1, err)
-		} else if resp.StatusCode == 429 {
-			log.Printf("Rate limit hit (attempt %d), status: %s", attempt+1, resp.Status)
-			resp.Body.Close()
-		}
-		if attempt < maxRetries-1 {
-			waitTime := time.Duration(10*(attempt+1)) * time.Second // Exponential backoff: 10s, 20s, 30s
-			log.Printf("Retrying in %v...", waitTime)
-			time.Sleep(waitTime)
+		log.Printf("ERROR calling LLM for rule detection: %v", err)
+		if fallback == withoutLLMFallback {
+			return nil, fmt.Errorf("rule detection failed: %w", err)
 		}
-	}
-
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Non-200 status code: %d, status: %s", resp.StatusCode, resp.Status)
-		return s.fallbackRuleExtraction(ocrText, ruleNames), nil
-	}
-
-	defer resp.Body.Close()
-
-	// Read and parse response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("ERROR reading Groq response: %v", err)
-		return s.fallbackRuleExtraction(ocrText, ruleNames), nil
-	}
-	log.Printf("Groq API Raw Response: %s", string(body))
-
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("ERROR parsing Groq response structure: %v", err)
 		return s.fallbackRuleExtraction(ocrText, ruleNames), nil
 	}
+	log.Printf("LLM Raw Response: %s", content)
 
 	var ruleResponse struct {
 		ViolatedRules []string `json:"violated_rules"`
 	}
-	if len(result.Choices) > 0 {
-		if err := json.Unmarshal([]byte(result.Choices[0].Message.Content), &ruleResponse); err != nil {
-			log.Printf("ERROR parsing violated rules from content: %v", err)
-			return s.fallbackRuleExtraction(ocrText, ruleNames), nil
+	if err := json.Unmarshal([]byte(content), &ruleResponse); err != nil {
+		log.Printf("ERROR parsing violated rules from content: %v", err)
+		if fallback == withoutLLMFallback {
+			return nil, fmt.Errorf("failed to parse rule detection response: %w", err)
 		}
+		return s.fallbackRuleExtraction(ocrText, ruleNames), nil
 	}
 
 	violatedRules := ruleResponse.ViolatedRules
@@ -356,10 +358,8 @@ func (s *DocumentService) DetermineApplicableRulesBatch(documents []string, batc
 		ruleNames = append(ruleNames, rule.Name)
 	}
 
-	// Validate Groq API Key
-	groqAPIKey := os.Getenv("VITE_GROQ_API_KEY")
-	if groqAPIKey == "" {
-		return nil, fmt.Errorf("VITE_GROQ_API_KEY environment variable is not set")
+	if s.llm == nil {
+		return nil, fmt.Errorf("LLM client is not configured")
 	}
 
 	// Process documents in batches
@@ -377,8 +377,8 @@ func (s *DocumentService) DetermineApplicableRulesBatch(documents []string, batc
 		// Prepare batch request
 		batchRequest := prepareBatchComplianceRequest(batchDocuments, ruleNames)
 
-		// Send batch request to Groq
-		batchResponse, err := sendBatchComplianceRequest(batchRequest, groqAPIKey)
+		// Send batch request to the LLM
+		batchResponse, err := s.sendBatchComplianceRequest(batchRequest)
 		if err != nil {
 			log.Printf("Error in batch compliance request: %v", err)
 			continue
@@ -419,13 +419,16 @@ func prepareBatchComplianceRequest(documents []string, ruleNames []string) Batch
 	}
 }
 
-// sendBatchComplianceRequest sends a batch request to Groq and processes the response
-func sendBatchComplianceRequest(batchRequest BatchComplianceRequest, apiKey string) (*BatchComplianceResponse, error) {
+// sendBatchComplianceRequest sends a batch request to the LLM and processes the response
+func (s *DocumentService) sendBatchComplianceRequest(batchRequest BatchComplianceRequest) (*BatchComplianceResponse, error) {
 	// Construct the detailed, structured prompt
 	promptTemplate := `
 	For each document, analyze the text and suggest the most relevant legal compliance rules from this list:
 	%s
 
+	Documents:
+	%s
+
 	Instructions:
 	1. Carefully review each document text.
 	2. Match the content to rules based on their names.
@@ -443,90 +446,32 @@ func sendBatchComplianceRequest(batchRequest BatchComplianceRequest, apiKey stri
 	}
 	`
 
-	// Prepare request body
-	reqBody, err := json.Marshal(map[string]interface{}{
-		"messages": []map[string]string{
+	var documents []string
+	for _, doc := range batchRequest.Documents {
+		documents = append(documents, fmt.Sprintf("[%s]\n%s", doc.ID, doc.OCRText))
+	}
+
+	content, err := s.llm.Complete(context.Background(), ChatRequest{
+		Task: LLMTaskBatchRuleDetection,
+		Messages: []ChatMessage{
 			{
-				"role":    "user",
-				"content": fmt.Sprintf(promptTemplate, strings.Join(batchRequest.RuleNames, "\n")), // Use batchRequest.RuleNames
+				Role:    "user",
+				Content: fmt.Sprintf(promptTemplate, strings.Join(batchRequest.RuleNames, "\n"), strings.Join(documents, "\n\n")),
 			},
 		},
-		"model":       "llama-3.3-70b-versatile",
-		"temperature": 0.7,
-		"max_tokens":  500,
-		"response_format": map[string]string{
-			"type": "json_object",
-		},
+		Temperature:  0.7,
+		MaxTokens:    500,
+		JSONResponse: true,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request body: %w", err)
-	}
-
-	// Send request to Groq
-	req, err := http.NewRequest("POST", "https://api.groq.com/openai/v1/chat/completions", bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Groq request: %w", err)
-	}
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{
-		Timeout: 60 * time.Second, // Increased timeout for batch processing
-		Transport: &http.Transport{
-			MaxIdleConns:        10,
-			IdleConnTimeout:     60 * time.Second,
-			DisableCompression:  true,
-			TLSHandshakeTimeout: 15 * time.Second,
-		},
-	}
-
-	// Execute request with retries
-	var resp *http.Response
-	for attempt := 0; attempt < 3; attempt++ {
-		resp, err = client.Do(req)
-		if err == nil {
-			break
-		}
-		log.Printf("Groq API request attempt %d failed: %v", attempt+1, err)
-		time.Sleep(time.Duration(attempt+1) * time.Second) // Exponential backoff
-	}
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to send Groq API request after 3 attempts: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("non-200 status code: %d, response: %s", resp.StatusCode, string(body))
-	}
-
-	// Read and parse response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read Groq response: %w", err)
-	}
-	log.Printf("Groq API Batch Response: %s", string(body))
-
-	// Parse the response
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse Groq response structure: %w", err)
+		return nil, fmt.Errorf("batch compliance request failed: %w", err)
 	}
+	log.Printf("Groq API Batch Response: %s", content)
 
 	// Parse batch results
 	var batchResponse BatchComplianceResponse
-	if len(result.Choices) > 0 {
-		if err := json.Unmarshal([]byte(result.Choices[0].Message.Content), &batchResponse); err != nil {
-			return nil, fmt.Errorf("failed to parse batch results: %w", err)
-		}
+	if err := json.Unmarshal([]byte(content), &batchResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse batch results: %w", err)
 	}
 
 	return &batchResponse, nil
@@ -567,66 +512,24 @@ func (s *DocumentService) CheckRuleCompliance(ocrText, ruleName, rulePattern str
 		return nil, fmt.Errorf("empty rule name provided")
 	}
 
-	// Custom pattern matching for specific rules
-	var complianceCheck bool
+	// Local pattern evaluation gives the LLM an initial verdict to confirm or refute
 	log.Printf("COMPLIANCE DEBUG - Processing Rule: '%s', Rule Pattern: '%s'", ruleName, rulePattern)
-
-	switch ruleName {
-	case "Confidentiality Marking":
-		// Detailed logging and multiple check methods
-		log.Printf("COMPLIANCE DEBUG - Initial OCR Text: '%s'", ocrText)
-
-		// Check exact match
-		exactMatch := strings.Contains(ocrText, "Confidential")
-
-		// Case-insensitive match
-		caseInsensitiveMatch := strings.Contains(strings.ToLower(ocrText), strings.ToLower("Confidential"))
-
-		// Case-insensitive regex match
-		regexCaseInsensitiveMatch, _ := regexp.MatchString(`(?i)confidential`, ocrText)
-
-		// Regex match with original pattern
-		regexMatch, _ := regexp.MatchString(`Confidential`, ocrText)
-
-		// Determine compliance check
-		complianceCheck = exactMatch || caseInsensitiveMatch || regexCaseInsensitiveMatch || regexMatch
-
-		log.Printf("COMPLIANCE DEBUG - Confidentiality Marking Rule Checks: "+
-			"Exact Match='%v', "+
-			"Case-Insensitive String Match='%v', "+
-			"Case-Insensitive Regex Match='%v', "+
-			"Regex Match='%v', "+
-			"Final Compliance Check=%v",
-			exactMatch,
-			caseInsensitiveMatch,
-			regexCaseInsensitiveMatch,
-			regexMatch,
-			complianceCheck)
-	case "Signature Requirement":
-		log.Printf("COMPLIANCE DEBUG - Processing Signature Requirement Rule")
-		complianceCheck, _ = regexp.MatchString(`(?i)(signature|signed).*date`, ocrText)
-		log.Printf("COMPLIANCE DEBUG - Signature Requirement Check: %v", complianceCheck)
-	default:
-		log.Printf("COMPLIANCE DEBUG - Using Default Rule Pattern Matching")
-		complianceCheck, _ = regexp.MatchString(rulePattern, ocrText)
-		log.Printf("COMPLIANCE DEBUG - Default Rule Check: %v", complianceCheck)
+	var complianceCheck bool
+	if pattern, err := CompileRulePattern(rulePattern); err != nil {
+		log.Printf("COMPLIANCE DEBUG - Could not compile pattern for rule '%s': %v", ruleName, err)
+	} else {
+		complianceCheck = pattern.Match(ocrText).Matched
 	}
 
 	log.Printf("COMPLIANCE DEBUG - Final Compliance Check for Rule '%s': %v", ruleName, complianceCheck)
 
-	// Prepare Groq API request payload
-	requestPayload := struct {
-		Messages []struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		} `json:"messages"`
-		Model       string  `json:"model"`
-		Temperature float64 `json:"temperature"`
-	}{
-		Messages: []struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		}{
+	if s.llm == nil {
+		return nil, fmt.Errorf("LLM client is not configured")
+	}
+
+	content, err := s.llm.Complete(context.Background(), ChatRequest{
+		Task: LLMTaskRuleCompliance,
+		Messages: []ChatMessage{
 			{
 				Role:    "system",
 				Content: "You are an advanced compliance rule analyzer with expertise in legal document validation.",
@@ -643,87 +546,21 @@ Document Text:
 %s`, ruleName, ruleName, rulePattern, complianceCheck, ocrText),
 			},
 		},
-		Model:       "mixtral-8x7b-32768",
-		Temperature: 0.8,
-	}
-
-	// Serialize payload
-	payloadBytes, err := json.Marshal(requestPayload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to serialize request payload: %w", err)
-	}
-
-	// Create HTTP request with context and timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.groq.com/openai/v1/chat/completions", bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Groq API request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", os.Getenv("GROQ_API_KEY")))
-	req.Header.Set("Content-Type", "application/json")
-
-	// Use a custom HTTP client with timeout
-	client := &http.Client{
-		Timeout: 45 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        10,
-			IdleConnTimeout:     45 * time.Second,
-			DisableCompression:  true,
-			TLSHandshakeTimeout: 15 * time.Second,
-		},
-	}
-
-	// Execute request with retries
-	var resp *http.Response
-	for attempt := 0; attempt < 3; attempt++ {
-		resp, err = client.Do(req)
-		if err == nil {
-			break
-		}
-		log.Printf("Groq API request attempt %d failed: %v", attempt+1, err)
-		time.Sleep(time.Duration(attempt+1) * time.Second) // Exponential backoff
-	}
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to send Groq API request after 3 attempts: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("non-200 status code: %d, response: %s", resp.StatusCode, string(body))
-	}
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+		Temperature:  0.8,
+		JSONResponse: true,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read Groq response: %w", err)
-	}
-
-	// Parse Groq API response
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse Groq response structure: %w", err)
+		return nil, fmt.Errorf("compliance request failed: %w", err)
 	}
 
 	// Validate response content
-	if len(result.Choices) == 0 || result.Choices[0].Message.Content == "" {
+	if content == "" {
 		return nil, fmt.Errorf("no compliance analysis returned from Groq")
 	}
 
 	// Parse compliance response
 	var complianceResponse map[string]interface{}
-	if err := json.Unmarshal([]byte(result.Choices[0].Message.Content), &complianceResponse); err != nil {
+	if err := json.Unmarshal([]byte(content), &complianceResponse); err != nil {
 		return nil, fmt.Errorf("failed to parse compliance response JSON: %w", err)
 	}
 
@@ -800,7 +637,19 @@ func (s *DocumentService) CalculateRiskScore(results []map[string]interface{}, r
 	if !ruleRateLimiter.Allow("risk_score_calculation") {
 		return 0.0
 	}
+	return calculateRiskScore(results, rules)
+}
+
+// failingStatus reports whether a rule result counts against a document. Rules that could not be
+// evaluated ("error") count as failing, since the document is not known to comply with them.
+// failingResultSQL is the same test on stored results.
+func failingStatus(status string) bool {
+	return status != "pass" && status != "skipped"
+}
 
+// calculateRiskScore sums the severity weights of failing rules. It is not rate limited so that
+// background re-evaluation can score any number of documents.
+func calculateRiskScore(results []map[string]interface{}, rules []model.ComplianceRule) float64 {
 	log.Printf("Calculating Risk Score. Number of results: %d", len(results))
 
 	severityWeights := map[string]float64{
@@ -837,7 +686,7 @@ func (s *DocumentService) CalculateRiskScore(results []map[string]interface{}, r
 			}
 		}
 
-		if status == "fail" {
+		if failingStatus(status) {
 			rule, exists := ruleMap[ruleName]
 			if exists {
 				ruleSeverity := rule.Severity
@@ -0,0 +1,364 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidDocumentQuery is returned when a dashboard listing has an invalid sort, cursor, field or
+// filter.
+var ErrInvalidDocumentQuery = errors.New("invalid document query")
+
+// Dashboard page sizes.
+const (
+	DefaultDocumentPageSize = 20
+	MaxDocumentPageSize     = 100
+)
+
+// DocumentFilter narrows the documents listed on the dashboard. Zero values match everything.
+type DocumentFilter struct {
+	// Category keeps only documents of this category.
+	Category string
+	// FileType keeps only documents of this file type, e.g. "pdf".
+	FileType string
+	// MinRisk and MaxRisk bound the risk score, inclusive.
+	MinRisk *float64
+	MaxRisk *float64
+	// ComplianceStatus keeps only documents whose stored results pass ("pass") or have a failing or
+	// errored rule ("fail").
+	ComplianceStatus string
+	// FailingRule keeps only documents failing the rule with this ID or name.
+	FailingRule string
+	// CreatedFrom and CreatedTo bound the upload time, inclusive.
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// GoverningLaw keeps only documents governed by this law, matched case-insensitively.
+	GoverningLaw string
+	// Party keeps only documents with a party whose name contains this text.
+	Party string
+	// EffectiveFrom and EffectiveTo bound the effective date, inclusive.
+	EffectiveFrom *time.Time
+	EffectiveTo   *time.Time
+	// Currency and MinAmount keep only documents stating an amount in this currency and at least
+	// this large. Either may be used alone.
+	Currency  string
+	MinAmount *float64
+}
+
+// DocumentListParams selects, orders and pages the documents listed on the dashboard.
+type DocumentListParams struct {
+	Filter DocumentFilter
+	// Sort is the column to order by: created_at (default), updated_at, risk_score or title.
+	Sort string
+	// Order is "asc" or "desc" (default).
+	Order string
+	// Cursor continues a listing from the NextCursor of its previous page.
+	Cursor string
+	// Limit is the page size, DefaultDocumentPageSize when zero and at most MaxDocumentPageSize.
+	Limit int
+	// Fields lists the fields to return; empty means defaultDocumentFields. "compliance" adds the
+	// compliance summary of the stored rule results.
+	Fields []string
+}
+
+// DocumentPage is one page of the dashboard listing. NextCursor is empty on the last page.
+type DocumentPage struct {
+	Documents  []map[string]interface{}
+	Total      int64
+	NextCursor string
+}
+
+// documentColumns maps the fields a listing can return to their columns.
+var documentColumns = map[string]string{
+	"id": "id", "title": "title", "file_type": "file_type", "category": "category",
+	"category_confidence": "category_confidence", "category_source": "category_source",
+	"jurisdiction": "jurisdiction", "tags": "tags", "original_url": "original_url", "risk_score": "risk_score",
+	"parsed_data": "parsed_data", "ocr_text": "ocr_text", "created_at": "created_at", "updated_at": "updated_at",
+}
+
+// complianceField is the listing field holding the compliance summary; it needs parsed_data.
+const complianceField = "compliance"
+
+// defaultDocumentFields is what the dashboard returns without a field list: everything except the
+// OCR text and raw results, which the compliance summary covers.
+var defaultDocumentFields = []string{"id", "title", "file_type", "category", "category_confidence", "category_source",
+	"jurisdiction", "tags", "original_url", "risk_score", "created_at", "updated_at", complianceField}
+
+// documentSortColumns are the columns a listing can be ordered by.
+var documentSortColumns = []string{"created_at", "updated_at", "risk_score", "title"}
+
+// documentSortExpressions orders nullable columns as if NULL were zero or empty, so that keyset
+// comparisons never meet a NULL.
+var documentSortExpressions = map[string]string{
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"risk_score": "COALESCE(risk_score, 0)",
+	"title":      "COALESCE(title, '')",
+}
+
+// parsedResultsSQL expands the stored rule results of a document into rows, treating a parsed_data
+// that is not a list as empty.
+const parsedResultsSQL = "jsonb_array_elements(CASE WHEN jsonb_typeof(documents.parsed_data) = 'array' THEN documents.parsed_data ELSE '[]'::jsonb END) r"
+
+// failingResultSQL matches a stored rule result that fails, including errored rules, like failingStatus.
+const failingResultSQL = "COALESCE(r->>'status', '') NOT IN ('pass', 'skipped')"
+
+// documentCursor is the position after the last document of a page.
+type documentCursor struct {
+	Sort  string      `json:"s"`
+	Order string      `json:"o"`
+	Value interface{} `json:"v"`
+	ID    string      `json:"id"`
+}
+
+// escapeLike escapes the LIKE wildcards in value so it matches literally
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// termFilter restricts query to documents with a key term of termType matching condition
+func termFilter(query *gorm.DB, termType, condition string, args ...interface{}) *gorm.DB {
+	args = append([]interface{}{termType}, args...)
+	return query.Where("EXISTS (SELECT 1 FROM document_terms t WHERE t.document_id = documents.id AND t.term_type = ? AND "+condition+")", args...)
+}
+
+// filterDocuments applies filter to a query over documents
+func filterDocuments(query *gorm.DB, filter DocumentFilter) (*gorm.DB, error) {
+	if category := strings.ToLower(strings.TrimSpace(filter.Category)); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if fileType := normalizeFileType(filter.FileType); fileType != "" {
+		query = query.Where("lower(file_type) = ?", fileType)
+	}
+	if filter.MinRisk != nil {
+		query = query.Where("risk_score >= ?", *filter.MinRisk)
+	}
+	if filter.MaxRisk != nil {
+		query = query.Where("risk_score <= ?", *filter.MaxRisk)
+	}
+	switch status := strings.ToLower(strings.TrimSpace(filter.ComplianceStatus)); status {
+	case "":
+	case "pass":
+		query = query.Where("jsonb_typeof(documents.parsed_data) = 'array' AND NOT EXISTS (SELECT 1 FROM " + parsedResultsSQL + " WHERE " + failingResultSQL + ")")
+	case "fail":
+		query = query.Where("EXISTS (SELECT 1 FROM " + parsedResultsSQL + " WHERE " + failingResultSQL + ")")
+	default:
+		return nil, fmt.Errorf("%w: compliance status must be 'pass' or 'fail', got %q", ErrInvalidDocumentQuery, status)
+	}
+	if rule := strings.TrimSpace(filter.FailingRule); rule != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM "+parsedResultsSQL+" WHERE "+failingResultSQL+
+			" AND (lower(r->>'rule_id') = lower(?) OR lower(COALESCE(r->>'rule_name', r->>'rule')) = lower(?)))", rule, rule)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at <= ?", *filter.CreatedTo)
+	}
+	if law := strings.TrimSpace(filter.GoverningLaw); law != "" {
+		query = termFilter(query, model.TermGoverningLaw, "lower(t.value) = lower(?)", law)
+	}
+	if party := strings.TrimSpace(filter.Party); party != "" {
+		query = termFilter(query, model.TermParty, "t.value ILIKE ?", "%"+escapeLike(party)+"%")
+	}
+	if filter.EffectiveFrom != nil {
+		query = termFilter(query, model.TermEffectiveDate, "t.date_value >= ?", *filter.EffectiveFrom)
+	}
+	if filter.EffectiveTo != nil {
+		query = termFilter(query, model.TermEffectiveDate, "t.date_value <= ?", *filter.EffectiveTo)
+	}
+	if currency := strings.ToUpper(strings.TrimSpace(filter.Currency)); currency != "" || filter.MinAmount != nil {
+		condition, args := "TRUE", []interface{}{}
+		if currency != "" {
+			condition, args = "t.unit = ?", append(args, currency)
+		}
+		if filter.MinAmount != nil {
+			condition, args = condition+" AND t.number_value >= ?", append(args, *filter.MinAmount)
+		}
+		query = termFilter(query, model.TermAmount, condition, args...)
+	}
+	return query, nil
+}
+
+// normalizeListParams validates params and fills in the defaults
+func normalizeListParams(params *DocumentListParams) error {
+	params.Sort = strings.ToLower(strings.TrimSpace(params.Sort))
+	if params.Sort == "" {
+		params.Sort = "created_at"
+	}
+	if !contains(documentSortColumns, params.Sort) {
+		return fmt.Errorf("%w: cannot sort by %q; expected one of %s", ErrInvalidDocumentQuery, params.Sort, strings.Join(documentSortColumns, ", "))
+	}
+	params.Order = strings.ToLower(strings.TrimSpace(params.Order))
+	if params.Order == "" {
+		params.Order = "desc"
+	}
+	if params.Order != "asc" && params.Order != "desc" {
+		return fmt.Errorf("%w: order must be 'asc' or 'desc'", ErrInvalidDocumentQuery)
+	}
+	if params.Limit <= 0 {
+		params.Limit = DefaultDocumentPageSize
+	}
+	if params.Limit > MaxDocumentPageSize {
+		params.Limit = MaxDocumentPageSize
+	}
+
+	if len(params.Fields) == 0 {
+		params.Fields = defaultDocumentFields
+	}
+	fields := normalizeTags(params.Fields)
+	for _, field := range fields {
+		if _, ok := documentColumns[field]; !ok && field != complianceField {
+			return fmt.Errorf("%w: unknown field %q", ErrInvalidDocumentQuery, field)
+		}
+	}
+	params.Fields = fields
+	return nil
+}
+
+// listColumns returns the columns to load for fields: the requested ones plus the ID, the sort
+// column and, for the compliance summary, the stored results
+func listColumns(fields []string, sort string) []string {
+	columns := []string{"id", sort}
+	for _, field := range fields {
+		if field == complianceField {
+			columns = append(columns, "parsed_data")
+		} else {
+			columns = append(columns, documentColumns[field])
+		}
+	}
+	return removeDuplicates(columns)
+}
+
+// encodeDocumentCursor returns the cursor positioned after doc
+func encodeDocumentCursor(doc model.Document, sort, order string) string {
+	cursor := documentCursor{Sort: sort, Order: order, ID: doc.ID}
+	switch sort {
+	case "created_at":
+		cursor.Value = doc.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		cursor.Value = doc.UpdatedAt.Format(time.RFC3339Nano)
+	case "risk_score":
+		cursor.Value = doc.RiskScore
+	case "title":
+		cursor.Value = doc.Title
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeDocumentCursor reads a cursor made by encodeDocumentCursor for the same sort and order and
+// returns the sort value and document ID it points after
+func decodeDocumentCursor(encoded, sort, order string) (interface{}, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: malformed cursor", ErrInvalidDocumentQuery)
+	}
+	var cursor documentCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, "", fmt.Errorf("%w: malformed cursor", ErrInvalidDocumentQuery)
+	}
+	if cursor.Sort != sort || cursor.Order != order {
+		return nil, "", fmt.Errorf("%w: cursor was made for sort %s %s", ErrInvalidDocumentQuery, cursor.Sort, cursor.Order)
+	}
+
+	switch value := cursor.Value.(type) {
+	case string:
+		if sort == "created_at" || sort == "updated_at" {
+			at, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return nil, "", fmt.Errorf("%w: malformed cursor", ErrInvalidDocumentQuery)
+			}
+			return at, cursor.ID, nil
+		}
+		if sort == "title" {
+			return value, cursor.ID, nil
+		}
+	case float64:
+		if sort == "risk_score" {
+			return value, cursor.ID, nil
+		}
+	}
+	return nil, "", fmt.Errorf("%w: malformed cursor", ErrInvalidDocumentQuery)
+}
+
+// documentListMap returns the requested fields of doc, with the compliance summary when asked for
+func documentListMap(doc model.Document, fields []string) map[string]interface{} {
+	values := map[string]interface{}{
+		"id": doc.ID, "title": doc.Title, "file_type": doc.FileType, "category": doc.Category,
+		"category_confidence": doc.CategoryConfidence, "category_source": doc.CategorySource,
+		"jurisdiction": doc.Jurisdiction, "tags": doc.Tags, "original_url": doc.OriginalURL,
+		"risk_score": doc.RiskScore, "parsed_data": doc.ParsedData, "ocr_text": doc.OcrText,
+		"created_at": doc.CreatedAt, "updated_at": doc.UpdatedAt,
+	}
+	docMap := map[string]interface{}{"id": doc.ID}
+	for _, field := range fields {
+		if field == complianceField {
+			addComplianceSummary(docMap, doc.ParsedData)
+			continue
+		}
+		docMap[field] = values[field]
+	}
+	return docMap
+}
+
+// ListDocuments returns a page of the documents matching params.Filter in the requested order, using
+// keyset pagination on the sort column and ID. Compliance comes from the stored rule results; no
+// rules are evaluated.
+func (s *DocumentService) ListDocuments(params DocumentListParams) (*DocumentPage, error) {
+	if err := normalizeListParams(&params); err != nil {
+		return nil, err
+	}
+
+	query, err := filterDocuments(s.db.Model(&model.Document{}), params.Filter)
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		log.Printf("[ListDocuments] Error counting documents: %v", err)
+		return nil, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	query, _ = filterDocuments(s.db.Model(&model.Document{}), params.Filter)
+	comparison := "<"
+	if params.Order == "asc" {
+		comparison = ">"
+	}
+	if params.Cursor != "" {
+		value, id, err := decodeDocumentCursor(params.Cursor, params.Sort, params.Order)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", documentSortExpressions[params.Sort], comparison), value, id)
+	}
+
+	var documents []model.Document
+	err = query.Select(listColumns(params.Fields, params.Sort)).
+		Order(fmt.Sprintf("%s %s, id %s", documentSortExpressions[params.Sort], params.Order, params.Order)).
+		Limit(params.Limit + 1).
+		Find(&documents).Error
+	if err != nil {
+		log.Printf("[ListDocuments] Error fetching documents: %v", err)
+		return nil, fmt.Errorf("failed to fetch documents: %w", err)
+	}
+
+	page := &DocumentPage{Documents: make([]map[string]interface{}, 0, len(documents)), Total: total}
+	if len(documents) > params.Limit {
+		documents = documents[:params.Limit]
+		page.NextCursor = encodeDocumentCursor(documents[len(documents)-1], params.Sort, params.Order)
+	}
+	for _, doc := range documents {
+		page.Documents = append(page.Documents, documentListMap(doc, params.Fields))
+	}
+	log.Printf("[ListDocuments] Returning %d of %d documents", len(page.Documents), total)
+	return page, nil
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentCursor(t *testing.T) {
+	createdAt := time.Date(2025, 2, 3, 4, 5, 6, 789000, time.UTC)
+	doc := model.Document{ID: "d1", Title: "Lease", RiskScore: 0.25, CreatedAt: createdAt}
+
+	value, id, err := decodeDocumentCursor(encodeDocumentCursor(doc, "created_at", "desc"), "created_at", "desc")
+	require.NoError(t, err)
+	assert.Equal(t, "d1", id)
+	assert.True(t, createdAt.Equal(value.(time.Time)))
+
+	value, _, err = decodeDocumentCursor(encodeDocumentCursor(doc, "risk_score", "asc"), "risk_score", "asc")
+	require.NoError(t, err)
+	assert.Equal(t, 0.25, value)
+
+	value, _, err = decodeDocumentCursor(encodeDocumentCursor(doc, "title", "asc"), "title", "asc")
+	require.NoError(t, err)
+	assert.Equal(t, "Lease", value)
+
+	// A cursor only continues the listing it came from.
+	_, _, err = decodeDocumentCursor(encodeDocumentCursor(doc, "title", "asc"), "title", "desc")
+	assert.ErrorIs(t, err, ErrInvalidDocumentQuery)
+	_, _, err = decodeDocumentCursor("not a cursor!", "created_at", "desc")
+	assert.ErrorIs(t, err, ErrInvalidDocumentQuery)
+}
+
+func TestNormalizeListParams(t *testing.T) {
+	params := DocumentListParams{Limit: 500}
+	require.NoError(t, normalizeListParams(&params))
+	assert.Equal(t, "created_at", params.Sort)
+	assert.Equal(t, "desc", params.Order)
+	assert.Equal(t, MaxDocumentPageSize, params.Limit)
+	assert.NotContains(t, params.Fields, "ocr_text")
+	assert.Contains(t, params.Fields, complianceField)
+
+	params = DocumentListParams{Fields: []string{" Title", "risk_score", "title"}}
+	require.NoError(t, normalizeListParams(&params))
+	assert.Equal(t, []string{"title", "risk_score"}, params.Fields)
+	assert.Equal(t, DefaultDocumentPageSize, params.Limit)
+	assert.ElementsMatch(t, []string{"id", "created_at", "title", "risk_score"}, listColumns(params.Fields, params.Sort))
+
+	for _, invalid := range []DocumentListParams{
+		{Sort: "ocr_text"},
+		{Order: "sideways"},
+		{Fields: []string{"password"}},
+	} {
+		assert.ErrorIs(t, normalizeListParams(&invalid), ErrInvalidDocumentQuery)
+	}
+}
+
+func TestDocumentListMap(t *testing.T) {
+	doc := model.Document{
+		ID:         "d1",
+		Title:      "Contract",
+		OcrText:    "long text",
+		ParsedData: []byte(`[{"rule_name":"Signature","status":"pass"},{"rule":"Governing Law","status":"fail"},{"rule_name":"NDA","status":"skipped"}]`),
+	}
+
+	docMap := documentListMap(doc, []string{"title", complianceField})
+	assert.Equal(t, "d1", docMap["id"])
+	assert.Equal(t, "Contract", docMap["title"])
+	assert.NotContains(t, docMap, "ocr_text")
+	assert.Equal(t, "fail", docMap["compliance_status"])
+	assert.Equal(t, []string{"Signature", "Governing Law"}, docMap["applicable_rules"])
+	assert.Equal(t, []string{"Governing Law"}, docMap["failing_rules"])
+
+	// Results that are not a list are reported instead of failing the listing.
+	doc.ParsedData = []byte(`{"status":true}`)
+	docMap = documentListMap(doc, []string{complianceField})
+	assert.Contains(t, docMap, "compliance_parsing_error")
+	assert.NotContains(t, docMap, "compliance_status")
+}
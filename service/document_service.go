@@ -1,18 +1,13 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
-	"net/http"
 	"os"
-	"path/filepath"
-	"strings"
-	"time"
 
 	model "github.com/Itish41/LegalEagle/models"
 
@@ -20,19 +15,27 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/elastic/go-elasticsearch/v8"
-	"gorm.io/datatypes"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"gorm.io/gorm"
 )
 
 // DocumentService handles document processing logic
 type DocumentService struct {
-	s3Client *s3.S3
-	esClient *elasticsearch.Client
+	s3Client s3iface.S3API
+	search   SearchIndex
+	ocr      OCRProvider
+	llm      LLMClient
+	// embedder computes the chunk embeddings of semantic search; nil disables it.
+	embedder EmbeddingProvider
 	db       *gorm.DB
+	jobQueue chan string
+	// evaluationMode selects how compliance rules are judged; see RuleEvaluationModeFromEnv.
+	evaluationMode string
+	// classifier configures the classification stage; see ClassifierConfigFromEnv.
+	classifier ClassifierConfig
 }
 
-// NewDocumentService initializes the service with an S3 client and Elasticsearch client
+// NewDocumentService initializes the service with an S3 client, search backend and OCR provider
 func NewDocumentService(db *gorm.DB) (*DocumentService, error) {
 	region := os.Getenv("SUPABASE_REGION")
 	endpoint := os.Getenv("SUPABASE_S3_ENDPOINT")
@@ -54,547 +57,245 @@ func NewDocumentService(db *gorm.DB) (*DocumentService, error) {
 		return nil, fmt.Errorf("failed to create AWS session: %w", err)
 	}
 
-	// Initialize Elasticsearch client with Elastic Cloud configuration
-	esURL := "https://9599cea5e64e4db2b21dbee49e7ca79e.asia-south1.gcp.elastic-cloud.com:443"
-	esAPIKey := os.Getenv("ELASTICSEARCH_API_KEY") // Set this to your encoded API key
-	var esClient *elasticsearch.Client
-
-	if esURL != "" && esAPIKey != "" {
-		esConfig := elasticsearch.Config{
-			Addresses: []string{esURL},
-			APIKey:    esAPIKey,
-		}
-		esClient, err = elasticsearch.NewClient(esConfig)
-		if err != nil {
-			log.Printf("Warning: Failed to create Elasticsearch client: %v", err)
-		}
-	} else {
-		log.Println("Warning: ELASTICSEARCH_API_KEY is not set. Elasticsearch client will not be initialized.")
-	}
-
-	return &DocumentService{s3Client: s3.New(sess), esClient: esClient, db: db}, nil
-}
-
-// UploadAndProcessDocument uploads the file to Supabase S3 and processes it with OCR.space
-func (s *DocumentService) UploadAndProcessDocument(file multipart.File, header *multipart.FileHeader) (string, string, string, string, float64, error) {
-	log.Println("Starting UploadAndProcessDocument")
-	log.Printf("File details: Name=%s, Size=%d", header.Filename, header.Size)
-
-	// Step 1: Upload file to Supabase S3
-	fileBytes, err := io.ReadAll(file)
+	search, err := NewSearchIndexFromEnv(db)
 	if err != nil {
-		log.Printf("ERROR reading file: %v", err)
-		return "", "", "", "", 0.0, fmt.Errorf("failed to read file: %w", err)
+		return nil, fmt.Errorf("failed to configure search backend: %w", err)
 	}
-
-	fileID := fmt.Sprintf("%d-%s", time.Now().Unix(), header.Filename)
-	bucket := os.Getenv("SUPABASE_BUCKET")
-	if bucket == "" {
-		log.Println("SUPABASE_BUCKET environment variable is not set")
-		return "", "", "", "", 0.0, fmt.Errorf("bucket name not configured")
-	}
-
-	uploadInput := &s3.PutObjectInput{
-		Bucket:      aws.String(bucket),
-		Key:         aws.String(fileID),
-		Body:        bytes.NewReader(fileBytes),
-		ACL:         aws.String("public-read"),
-		ContentType: aws.String(header.Header.Get("Content-Type")),
+	log.Printf("Using search backend: %s", search.Name())
+	setupCtx, cancel := context.WithTimeout(context.Background(), searchSetupTimeout)
+	if err := search.Setup(setupCtx); err != nil {
+		log.Printf("Warning: Failed to set up %s search index: %v", search.Name(), err)
 	}
+	cancel()
 
-	_, err = s.s3Client.PutObject(uploadInput)
+	ocr, err := NewOCRProviderFromEnv()
 	if err != nil {
-		log.Printf("S3 upload error: %v", err)
-		return "", "", "", "", 0.0, fmt.Errorf("failed to upload file to S3: %w", err)
+		return nil, fmt.Errorf("failed to configure OCR provider: %w", err)
 	}
+	log.Printf("Using OCR provider: %s", ocr.Name())
 
-	fileURL := fmt.Sprintf("%s/object/public/%s/%s", os.Getenv("SUPABASE_S3_URL"), bucket, fileID)
-	log.Printf("File stored at: %s", fileURL)
-
-	// Step 2: Process with OCR.space
-	apiKey := os.Getenv("OCR_SPACE_API_KEY")
-	if apiKey == "" {
-		log.Println("OCR_SPACE_API_KEY environment variable is not set")
-		return "", "", "", "", 0.0, fmt.Errorf("OCR API key not configured")
-	}
-
-	ocrText, err := processWithOCRSpace(fileBytes, header.Filename)
-	if err != nil {
-		log.Printf("ERROR in OCR processing: %v", err)
-		return "", "", "", "", 0.0, fmt.Errorf("failed to process OCR with OCR.space: %w", err)
-	}
-	log.Printf("OCR Text extracted: %s", ocrText)
-
-	// Step 3: Index in Elasticsearch
-	err = s.indexDocument(fileID, fileURL, ocrText)
-	if err != nil {
-		log.Printf("Elasticsearch indexing error: %v", err)
-		return "", "", "", "", 0.0, fmt.Errorf("failed Merkel to index document in Elasticsearch: %w", err)
-	}
-	log.Printf("Document indexed successfully with ID: %s", fileID)
-
-	// Step 4: Compliance Analysis
-	// Determine violated rules using Groq
-	violatedRuleNames, err := s.DetermineApplicableRules(ocrText)
+	embedder, err := NewEmbeddingProviderFromEnv()
 	if err != nil {
-		log.Printf("ERROR determining violated rules: %v", err)
-		return "", "", "", "", 0.0, err
+		return nil, fmt.Errorf("failed to configure embedding provider: %w", err)
 	}
-	log.Printf("Violated Rules: %v", violatedRuleNames)
-
-	// Fetch all rules to build complete parsed_data
-	allRules, err := s.GetAllComplianceRules()
-	if err != nil {
-		log.Printf("ERROR fetching all rules from database: %v", err)
-		return "", "", "", "", 0.0, fmt.Errorf("failed to fetch rules from database: %w", err)
-	}
-	log.Printf("Fetched %d rules from database", len(allRules))
-
-	// Generate parsed_data for all rules
-	var complianceResults []map[string]interface{}
-	ruleMap := make(map[string]model.ComplianceRule)
-	for _, rule := range allRules {
-		ruleMap[rule.Name] = rule
-		result := map[string]interface{}{
-			"rule_name":   rule.Name,
-			"severity":    rule.Severity,
-			"status":      "pass",
-			"explanation": fmt.Sprintf("The document complies with the '%s' rule.", rule.Name),
-		}
-		if contains(violatedRuleNames, rule.Name) {
-			result["status"] = "fail"
-			result["explanation"] = fmt.Sprintf("The document violates the '%s' rule: does not meet the required pattern '%s'.", rule.Name, rule.Pattern)
+	if embedder == nil {
+		log.Println("Semantic search is disabled: EMBEDDING_PROVIDER is not set")
+	} else {
+		log.Printf("Using embedding provider: %s", embedder.Name())
+		setupCtx, cancel := context.WithTimeout(context.Background(), searchSetupTimeout)
+		if err := setupChunkStorage(setupCtx, db, embedder); err != nil {
+			log.Printf("Warning: Failed to set up chunk storage, semantic search is disabled: %v", err)
+			embedder = nil
 		}
-		complianceResults = append(complianceResults, result)
-		log.Printf("Compliance result for %s: %+v", rule.Name, result)
+		cancel()
 	}
 
-	// Calculate risk score
-	riskScore := s.CalculateRiskScore(complianceResults, allRules)
-	log.Printf("Calculated Risk Score: %f", riskScore)
-
-	// Marshal compliance results
-	parsedDataJSON, err := json.Marshal(complianceResults)
-	if err != nil {
-		log.Printf("ERROR marshaling compliance results: %v", err)
-		return "", "", "", "", 0.0, fmt.Errorf("failed to marshal compliance results: %w", err)
+	llmConfig := LLMConfigFromEnv()
+	if llmConfig.APIKey == "" {
+		log.Println("Warning: LLM_API_KEY is not set. LLM requests will be sent without authentication.")
 	}
-	log.Printf("Compliance Results JSON: %s", string(parsedDataJSON))
+	log.Printf("Using LLM endpoint: %s", llmConfig.BaseURL)
 
-	// Step 5: Save to database with compliance results
-	fileName := filepath.Base(fileURL)
-	fileType := filepath.Ext(fileName)
-	if fileType != "" {
-		fileType = fileType[1:] // Remove the leading dot
-	}
-	title := strings.TrimSuffix(fileName, fileType)
-
-	doc := model.Document{
-		Title:       title,
-		FileType:    fileType,
-		OriginalURL: fileURL,
-		OcrText:     ocrText,
-		ParsedData:  datatypes.JSON(parsedDataJSON),
-		RiskScore:   riskScore,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}
-	if err := s.db.Create(&doc).Error; err != nil {
-		log.Printf("ERROR saving document to database: %v", err)
-		return "", "", "", "", 0.0, fmt.Errorf("failed to save to database: %w", err)
-	}
-	log.Printf("Document saved to database successfully with ID: %s", doc.ID)
+	evaluationMode := RuleEvaluationModeFromEnv()
+	log.Printf("Using rule evaluation mode: %s", evaluationMode)
 
-	// Step 6: Create Action Items and Document Rule Results
-	err = s.CreateActionItems(doc)
-	if err != nil {
-		log.Printf("Error creating action items: %v", err)
-		return "", "", "", "", 0.0, fmt.Errorf("failed to create action items: %w", err)
-	}
-	log.Printf("Action items processed for document %s", doc.ID)
+	classifier := ClassifierConfigFromEnv()
+	log.Printf("Using classifier minimum confidence %.2f, LLM fallback %v", classifier.minConfidence(), classifier.LLMFallback)
 
-	return ocrText, fileID, fileURL, string(parsedDataJSON), riskScore, nil
-}
+	return &DocumentService{
+		s3Client: s3.New(sess),
+		search:   search,
+		ocr:      ocr,
+		llm:      NewOpenAIClient(llmConfig),
+		embedder: embedder,
+		db:       db,
+		jobQueue: make(chan string, jobQueueSize),
 
-// Helper function to check if a slice contains a string
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
-		}
-	}
-	return false
+		evaluationMode: evaluationMode,
+		classifier:     classifier,
+	}, nil
 }
 
-// SearchDocuments searches for documents in Elasticsearch
-func (s *DocumentService) SearchDocuments(query string) ([]map[string]interface{}, error) {
-	// Validate Elasticsearch client
-	if s.esClient == nil {
-		return nil, fmt.Errorf("elasticsearch client is not initialized")
-	}
-
-	// Prepare the Elasticsearch query
-	searchQuery := map[string]interface{}{
-		"query": map[string]interface{}{
-			"multi_match": map[string]interface{}{
-				"query":  query,
-				"fields": []string{"ocr_text", "file_id"}, // Search these fields
-			},
-		},
-	}
-	body, err := json.Marshal(searchQuery)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal search query: %w", err)
-	}
-
-	// Execute the search
-	res, err := s.esClient.Search(
-		s.esClient.Search.WithContext(context.Background()),
-		s.esClient.Search.WithIndex("documents"),
-		s.esClient.Search.WithBody(bytes.NewReader(body)),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("search request failed: %w", err)
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return nil, fmt.Errorf("elasticsearch search failed: %s", res.String())
-	}
-
-	// Parse the response
-	var result map[string]interface{}
-	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode search response: %w", err)
-	}
-
-	// Safely extract hits
-	hitsMap, ok := result["hits"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid hits structure in search response")
-	}
-
-	hitsArray, ok := hitsMap["hits"].([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid hits array in search response")
-	}
-
-	var documents []map[string]interface{}
-	for _, hit := range hitsArray {
-		hitMap, ok := hit.(map[string]interface{})
-		if !ok {
-			continue // Skip invalid hits
-		}
-
-		source, ok := hitMap["_source"].(map[string]interface{})
-		if !ok {
-			continue // Skip hits without a valid source
-		}
-
-		documents = append(documents, source)
-	}
-
-	return documents, nil
+// SetSearchIndex replaces the backend that indexes and searches documents
+func (s *DocumentService) SetSearchIndex(search SearchIndex) {
+	s.search = search
 }
 
-// processWithOCRSpace sends the file to OCR.space and returns the extracted text
-func processWithOCRSpace(fileBytes []byte, filename string) (string, error) {
-	// Trim whitespace and validate API key
-	apiKey := strings.TrimSpace(os.Getenv("OCR_SPACE_API_KEY"))
-	if apiKey == "" {
-		return "", fmt.Errorf("OCR.space API key is not set")
-	}
-
-	// Additional validation for API key
-	if len(apiKey) < 10 {
-		return "", fmt.Errorf("invalid OCR.space API key format")
-	}
-
-	// Log API key (be careful in production!)
-	log.Printf("Using OCR.space API Key (first 4 chars): %s", apiKey[:4])
-	log.Printf("Full API Key Length: %d", len(apiKey))
-
-	// Determine file type based on filename extension
-	fileExt := strings.ToLower(filepath.Ext(filename))
-	var fileType string
-	switch fileExt {
-	case ".pdf":
-		fileType = "PDF"
-	case ".png":
-		fileType = "PNG"
-	case ".jpg", ".jpeg":
-		fileType = "JPG"
-	case ".gif":
-		fileType = "GIF"
-	case ".tiff", ".tif":
-		fileType = "TIFF"
-	default:
-		fileType = "PDF" // Default to PDF if unknown
-		log.Printf("Unknown file type for %s, defaulting to PDF", filename)
-	}
-
-	// Construct endpoint URL with API key
-	endpoint := "https://api.ocr.space/parse/image"
-
-	// Prepare multipart form
-	var b bytes.Buffer
-	w := multipart.NewWriter(&b)
-
-	// Add form fields
-	if err := w.WriteField("apikey", apiKey); err != nil {
-		return "", fmt.Errorf("failed to write apikey field: %w", err)
-	}
-	if err := w.WriteField("language", "eng"); err != nil {
-		return "", fmt.Errorf("failed to write language field: %w", err)
-	}
-	if err := w.WriteField("isOverlayRequired", "false"); err != nil {
-		return "", fmt.Errorf("failed to write isOverlayRequired field: %w", err)
-	}
-	if err := w.WriteField("filetype", fileType); err != nil {
-		return "", fmt.Errorf("failed to write filetype field: %w", err)
-	}
-
-	// Add file
-	fw, err := w.CreateFormFile("file", filename)
-	if err != nil {
-		return "", fmt.Errorf("failed to create form file: %w", err)
-	}
-	_, err = fw.Write(fileBytes)
-	if err != nil {
-		return "", fmt.Errorf("failed to write file bytes: %w", err)
-	}
-	w.Close()
-
-	// Create request
-	req, err := http.NewRequest("POST", endpoint, &b)
-	if err != nil {
-		return "", fmt.Errorf("failed to create OCR request: %w", err)
-	}
-	req.Header.Set("Content-Type", w.FormDataContentType())
+// SetOCRProvider replaces the OCR provider used by the upload pipeline
+func (s *DocumentService) SetOCRProvider(provider OCRProvider) {
+	s.ocr = provider
+}
 
-	// Log request details for debugging
-	log.Printf("OCR Request Content-Type: %s", req.Header.Get("Content-Type"))
-	log.Printf("OCR Request Endpoint: %s", endpoint)
-	log.Printf("OCR File Type: %s", fileType)
+// SetEmbeddingProvider replaces the provider that embeds document chunks; nil disables semantic search.
+// The chunk storage of the provider's model must already be set up.
+func (s *DocumentService) SetEmbeddingProvider(provider EmbeddingProvider) {
+	s.embedder = provider
+}
 
-	// Send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("OCR request failed: %w", err)
-	}
-	defer resp.Body.Close()
+// SetLLMClient replaces the client used for all LLM calls
+func (s *DocumentService) SetLLMClient(client LLMClient) {
+	s.llm = client
+}
 
-	// Read the raw response body for debugging
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
-	}
+// SetS3Client replaces the object storage client used for uploads
+func (s *DocumentService) SetS3Client(client s3iface.S3API) {
+	s.s3Client = client
+}
 
-	// Log the raw response for debugging
-	log.Printf("OCR Response Status: %s", resp.Status)
-	log.Printf("OCR Response Body: %s", string(bodyBytes))
+// UploadAndProcessDocument uploads the file to Supabase S3 and runs the full processing pipeline synchronously
+func (s *DocumentService) UploadAndProcessDocument(file multipart.File, header *multipart.FileHeader) (string, string, string, string, float64, error) {
+	log.Println("Starting UploadAndProcessDocument")
+	log.Printf("File details: Name=%s, Size=%d", header.Filename, header.Size)
 
-	// Try to parse the response
-	var result map[string]interface{}
-	err = json.Unmarshal(bodyBytes, &result)
+	fileBytes, err := io.ReadAll(file)
 	if err != nil {
-		// If it's a plain text error message, return it as an error
-		errorMsg := string(bodyBytes)
-		log.Printf("OCR API Error (JSON Unmarshal): %s", errorMsg)
-		return "", fmt.Errorf("OCR API error: %s", errorMsg)
-	}
-
-	// Check for error in OCR.space response
-	if errorMessage, ok := result["ErrorMessage"].(string); ok && errorMessage != "" {
-		log.Printf("OCR.space Error Message: %s", errorMessage)
-		return "", fmt.Errorf("OCR.space error: %s", errorMessage)
-	}
-
-	// Extract parsed results
-	parsedResults, ok := result["ParsedResults"].([]interface{})
-	if !ok || len(parsedResults) == 0 {
-		log.Println("No OCR results found in response")
-		return "", fmt.Errorf("no OCR results found in response")
+		log.Printf("ERROR reading file: %v", err)
+		return "", "", "", "", 0.0, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	// Extract parsed text
-	firstResult, ok := parsedResults[0].(map[string]interface{})
-	if !ok {
-		log.Println("Invalid parsed results format")
-		return "", fmt.Errorf("invalid parsed results format")
+	state := &pipelineState{
+		fileBytes:   fileBytes,
+		filename:    header.Filename,
+		contentType: header.Header.Get("Content-Type"),
+		fileID:      newFileID(header.Filename),
+		contentHash: contentHash(fileBytes),
 	}
-
-	parsedText, ok := firstResult["ParsedText"].(string)
-	if !ok {
-		log.Println("Failed to extract ParsedText")
-		return "", fmt.Errorf("failed to extract ParsedText from OCR response")
+	if err := s.runPipeline(context.Background(), state, nil); err != nil {
+		return "", "", "", "", 0.0, err
 	}
 
-	log.Printf("OCR Text extracted successfully: %d characters", len(parsedText))
-	return parsedText, nil
+	return state.ocrText, state.fileID, state.fileURL, string(state.parsedDataJSON), state.riskScore, nil
 }
 
-// indexDocument indexes the document in Elasticsearch
-func (s *DocumentService) indexDocument(fileID, fileURL, ocrText string) error {
-	// Skip indexing if Elasticsearch client is not initialized
-	if s.esClient == nil {
-		log.Println("Elasticsearch client not initialized. Skipping indexing.")
-		return nil
-	}
-
-	doc := map[string]interface{}{
-		"file_id":   fileID,
-		"file_url":  fileURL,
-		"ocr_text":  ocrText,
-		"timestamp": time.Now().UTC(),
-	}
-
-	body, err := json.Marshal(doc)
-	if err != nil {
-		return fmt.Errorf("failed to marshal document for indexing: %w", err)
-	}
-
-	res, err := s.esClient.Index(
-		"documents",
-		bytes.NewReader(body),
-		s.esClient.Index.WithDocumentID(fileID),
-		s.esClient.Index.WithContext(context.Background()),
-	)
-	if err != nil {
-		log.Printf("Elasticsearch indexing error: %v", err)
-		return nil // Don't break the upload process
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		log.Printf("Elasticsearch indexing failed: %s", res.String())
-		return nil // Don't break the upload process
+// Helper function to check if a slice contains a string
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
 	}
-
-	log.Println("Document successfully indexed in Elasticsearch")
-	return nil
+	return false
 }
 
-// processDocumentCompliance processes compliance for a single document
-func (s *DocumentService) processDocumentCompliance(doc model.Document) (map[string]interface{}, error) {
-	// Create a map representation of the document
+// processDocumentCompliance builds the map representation of a document with the compliance summary
+// of its stored rule results
+func (s *DocumentService) processDocumentCompliance(doc model.Document) map[string]interface{} {
 	docMap := map[string]interface{}{
-		"id":           doc.ID,
-		"title":        doc.Title,
-		"file_type":    doc.FileType,
-		"original_url": doc.OriginalURL,
-		"ocr_text":     doc.OcrText,
-		"risk_score":   doc.RiskScore,
-		"parsed_data":  doc.ParsedData,
-	}
-
-	// If no OCR text, return the document map without compliance processing
-	if doc.OcrText == "" {
-		return docMap, nil
-	}
-
-	// Determine applicable rules (use context to cache or optimize)
-	applicableRuleNames, err := s.DetermineApplicableRules(doc.OcrText)
-	if err != nil || len(applicableRuleNames) == 0 {
-		return docMap, err
-	}
+		"id":                  doc.ID,
+		"title":               doc.Title,
+		"file_type":           doc.FileType,
+		"category":            doc.Category,
+		"category_confidence": doc.CategoryConfidence,
+		"jurisdiction":        doc.Jurisdiction,
+		"tags":                doc.Tags,
+		"original_url":        doc.OriginalURL,
+		"ocr_text":            doc.OcrText,
+		"risk_score":          doc.RiskScore,
+		"parsed_data":         doc.ParsedData,
+	}
+	addComplianceSummary(docMap, doc.ParsedData)
+	return docMap
+}
 
-	// If no parsed data, return with rule information
-	if len(doc.ParsedData) == 0 {
-		docMap["applicable_rules"] = applicableRuleNames
-		return docMap, nil
+// addComplianceSummary adds the overall compliance status, the rule results with their rule names,
+// the evaluated rules and the failing rules of parsedData to docMap. It reads only the stored
+// results; skipped rules count as passing.
+func addComplianceSummary(docMap map[string]interface{}, parsedData []byte) {
+	if len(parsedData) == 0 {
+		return
 	}
-
-	// Parse compliance results efficiently
-	var complianceResults []map[string]interface{}
-	if err := json.Unmarshal([]byte(doc.ParsedData), &complianceResults); err != nil {
+	var results []map[string]interface{}
+	if err := json.Unmarshal(parsedData, &results); err != nil {
 		docMap["compliance_parsing_error"] = err.Error()
-		return docMap, err
+		return
 	}
 
-	// Quick compliance status determination
 	overallStatus := "pass"
-	processedComplianceDetails := make([]map[string]interface{}, 0, len(complianceResults))
-
-	for _, result := range complianceResults {
-		// Determine the most relevant rule name
-		ruleName := ""
-
-		// First, check if the result already has a rule name
-		if name, ok := result["rule_name"].(string); ok && name != "" {
-			ruleName = name
-		} else if name, ok := result["rule"].(string); ok && name != "" {
-			ruleName = name
-		}
-
-		// If no rule name found, try to match from applicable rules
-		if ruleName == "" && len(applicableRuleNames) > 0 {
-			for _, name := range applicableRuleNames {
-				if strings.Contains(strings.ToLower(result["explanation"].(string)), strings.ToLower(name)) {
-					ruleName = name
-					break
-				}
-			}
-
-			// If still no match, use the first applicable rule
-			if ruleName == "" {
-				ruleName = applicableRuleNames[0]
-			}
+	evaluated := []string{}
+	failing := []string{}
+	for _, result := range results {
+		ruleName, _ := result["rule_name"].(string)
+		if ruleName == "" {
+			ruleName, _ = result["rule"].(string)
 		}
-
-		// Add rule name to the result
 		result["rule_name"] = ruleName
-		processedComplianceDetails = append(processedComplianceDetails, result)
 
-		// Determine status efficiently
-		if status, ok := result["status"].(string); !ok || status != "pass" {
+		status, _ := result["status"].(string)
+		if status != "skipped" {
+			evaluated = append(evaluated, ruleName)
+		}
+		if failingStatus(status) {
 			overallStatus = "fail"
+			failing = append(failing, ruleName)
 		}
 	}
 
-	// Add compliance information
 	docMap["compliance_status"] = overallStatus
-	docMap["compliance_details"] = processedComplianceDetails
-	docMap["applicable_rules"] = applicableRuleNames
-
-	return docMap, nil
+	docMap["compliance_details"] = results
+	docMap["applicable_rules"] = evaluated
+	docMap["failing_rules"] = failing
 }
 
-// GetAllDocuments retrieves all documents from the database
-func (s *DocumentService) GetAllDocuments() ([]map[string]interface{}, error) {
-	log.Println("GetAllDocuments: Starting database query")
-
-	var documents []model.Document
-	// Use Find with error checking
-	result := s.db.Select("*").Find(&documents)
-
-	if result.Error != nil {
-		log.Printf("GetAllDocuments: Database query error: %v", result.Error)
-		return nil, fmt.Errorf("failed to fetch documents: %w", result.Error)
+// GetDocument returns a single document with its compliance information, stored rule results,
+// action items and key terms
+func (s *DocumentService) GetDocument(id string) (map[string]interface{}, error) {
+	if !uuidPattern.MatchString(id) {
+		return nil, gorm.ErrRecordNotFound
 	}
-
-	// Check if no documents found
-	if result.RowsAffected == 0 {
-		log.Println("GetAllDocuments: No documents found")
-		return []map[string]interface{}{}, nil
+	var doc model.Document
+	if err := s.db.First(&doc, "id = ?", id).Error; err != nil {
+		log.Printf("[GetDocument] Error fetching document %s: %v", id, err)
+		return nil, err
 	}
 
-	log.Printf("GetAllDocuments: Retrieved %d documents", len(documents))
+	docMap := s.processDocumentCompliance(doc)
+	docMap["category_source"] = doc.CategorySource
+	docMap["created_at"] = doc.CreatedAt
+	docMap["updated_at"] = doc.UpdatedAt
 
-	// Process documents and add compliance information
-	processedDocuments := make([]map[string]interface{}, 0, len(documents))
-	for _, doc := range documents {
-		processedDoc, err := s.processDocumentCompliance(doc)
-		if err != nil {
-			log.Printf("Error processing document %s: %v", doc.ID, err)
-			continue
-		}
-		processedDocuments = append(processedDocuments, processedDoc)
-	}
-
-	// Log processed documents summary
-	log.Printf("Total Processed Documents: %d", len(processedDocuments))
-
-	return processedDocuments, nil
+	terms, err := s.getDocumentTerms(doc)
+	if err != nil {
+		log.Printf("[GetDocument] Error fetching key terms of %s: %v", id, err)
+		return nil, err
+	}
+	docMap["terms"] = terms
+
+	var ruleResults []model.DocumentRuleResult
+	if err := s.db.Where("document_id = ?", id).Order("created_at").Find(&ruleResults).Error; err != nil {
+		log.Printf("[GetDocument] Error fetching rule results of %s: %v", id, err)
+		return nil, err
+	}
+	results := make([]map[string]interface{}, 0, len(ruleResults))
+	for _, result := range ruleResults {
+		results = append(results, map[string]interface{}{
+			"id":               result.ID,
+			"rule_id":          result.RuleID,
+			"rule_revision_id": result.RuleRevisionID,
+			"status":           result.Status,
+			"details":          result.Details,
+			"created_at":       result.CreatedAt,
+		})
+	}
+	docMap["rule_results"] = results
+
+	var actionItems []model.ActionItem
+	if err := s.db.Where("document_id = ?", id).Order("due_date").Find(&actionItems).Error; err != nil {
+		log.Printf("[GetDocument] Error fetching action items of %s: %v", id, err)
+		return nil, err
+	}
+	actions := make([]map[string]interface{}, 0, len(actionItems))
+	for _, item := range actionItems {
+		actions = append(actions, map[string]interface{}{
+			"id":            item.ID,
+			"rule_id":       item.RuleID,
+			"obligation_id": item.ObligationID,
+			"description":   item.Description,
+			"priority":      item.Priority,
+			"assigned_to":   item.AssignedTo,
+			"due_date":      item.DueDate,
+			"status":        item.Status,
+		})
+	}
+	docMap["action_items"] = actions
+	return docMap, nil
 }
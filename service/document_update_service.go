@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidDocument is returned when a document update fails validation.
+var ErrInvalidDocument = errors.New("invalid document update")
+
+// DocumentPatch holds the document fields to change; nil fields are left unchanged. An empty
+// category or jurisdiction clears it.
+type DocumentPatch struct {
+	Title        *string   `json:"title"`
+	Category     *string   `json:"category"`
+	Jurisdiction *string   `json:"jurisdiction"`
+	Tags         *[]string `json:"tags"`
+}
+
+// DocumentUpdate is the result of PatchDocument. ReevaluationJob is set when the change of category,
+// jurisdiction or tags queued a re-evaluation of the document's compliance.
+type DocumentUpdate struct {
+	Document        map[string]interface{}
+	ReevaluationJob *model.ProcessingJob
+}
+
+// PatchDocument changes a document's title, category, jurisdiction or tags. A category set here
+// counts as supplied at upload. The search index is updated, and since the category, jurisdiction
+// and tags decide which rules apply, changing them queues the document for re-evaluation.
+func (s *DocumentService) PatchDocument(id string, patch DocumentPatch) (*DocumentUpdate, error) {
+	if !uuidPattern.MatchString(id) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var doc model.Document
+	if err := s.db.First(&doc, "id = ?", id).Error; err != nil {
+		log.Printf("[PatchDocument] Error fetching document %s: %v", id, err)
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	scopeChanged := false
+	if patch.Title != nil {
+		title := strings.TrimSpace(*patch.Title)
+		if title == "" {
+			return nil, fmt.Errorf("%w: title must not be empty", ErrInvalidDocument)
+		}
+		updates["title"] = title
+	}
+	if patch.Category != nil {
+		category := strings.ToLower(strings.TrimSpace(*patch.Category))
+		if category != "" && !contains(knownCategories(), category) {
+			return nil, fmt.Errorf("%w: unknown category %q; expected one of %s", ErrInvalidDocument, category, strings.Join(knownCategories(), ", "))
+		}
+		if category == "" {
+			updates["category"], updates["category_confidence"], updates["category_source"] = nil, 0, nil
+		} else {
+			updates["category"], updates["category_confidence"], updates["category_source"] = category, 1, CategorySourceUpload
+		}
+		scopeChanged = scopeChanged || category != doc.Category
+	}
+	if patch.Jurisdiction != nil {
+		jurisdiction := strings.ToLower(strings.TrimSpace(*patch.Jurisdiction))
+		if jurisdiction == "" {
+			updates["jurisdiction"] = nil
+		} else {
+			updates["jurisdiction"] = jurisdiction
+		}
+		scopeChanged = scopeChanged || jurisdiction != doc.Jurisdiction
+	}
+	if patch.Tags != nil {
+		tags := normalizeTags(*patch.Tags)
+		updates["tags"] = pq.StringArray(tags)
+		scopeChanged = scopeChanged || strings.Join(tags, ",") != strings.Join(doc.Tags, ",")
+	}
+	if len(updates) == 0 {
+		return nil, fmt.Errorf("%w: no fields to update", ErrInvalidDocument)
+	}
+	updates["updated_at"] = time.Now()
+
+	if err := s.db.Model(&doc).Updates(updates).Error; err != nil {
+		log.Printf("[PatchDocument] Error updating document %s: %v", id, err)
+		return nil, err
+	}
+	log.Printf("[PatchDocument] Updated document %s: %v", id, updates)
+
+	if err := s.db.First(&doc, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	s.updateIndexedDocument(context.Background(), doc)
+
+	update := &DocumentUpdate{}
+	if scopeChanged && doc.OcrText != "" {
+		job, err := s.SubmitReevaluation(ReevaluateParams{DocumentIDs: []string{id}})
+		if err != nil {
+			log.Printf("[PatchDocument] Error queueing re-evaluation of %s: %v", id, err)
+			return nil, err
+		}
+		update.ReevaluationJob = job
+	}
+
+	updated, err := s.GetDocument(id)
+	if err != nil {
+		return nil, err
+	}
+	update.Document = updated
+	return update, nil
+}
+
+// DeleteDocument removes a document with its rule results, action items, clauses, terms, obligations
+// and version records, its search index entry and its stored file. The database rows are deleted in a
+// transaction that commits only after the index entry and file are gone; if the file cannot be deleted
+// the index entry is restored. The stored file is kept when another document with the same content
+// still uses it. Deleting the first version of a document re-roots its later versions on the oldest
+// remaining one.
+func (s *DocumentService) DeleteDocument(ctx context.Context, id string) error {
+	if !uuidPattern.MatchString(id) {
+		return gorm.ErrRecordNotFound
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var doc model.Document
+		if err := tx.First(&doc, "id = ?", id).Error; err != nil {
+			log.Printf("[DeleteDocument] Error fetching document %s: %v", id, err)
+			return err
+		}
+
+		var sharing int64
+		if err := tx.Model(&model.Document{}).Where("original_url = ? AND id <> ?", doc.OriginalURL, id).Count(&sharing).Error; err != nil {
+			return err
+		}
+
+		if err := rerootVersions(tx, id); err != nil {
+			log.Printf("[DeleteDocument] Error re-rooting the versions of %s: %v", id, err)
+			return err
+		}
+		if err := tx.Delete(&model.Document{}, "id = ?", id).Error; err != nil {
+			log.Printf("[DeleteDocument] Error deleting document %s: %v", id, err)
+			return err
+		}
+
+		if err := s.deleteIndexedDocument(ctx, id); err != nil {
+			log.Printf("[DeleteDocument] Error deleting index entry of %s: %v", id, err)
+			return err
+		}
+		key := storageKeyFromURL(doc.OriginalURL)
+		if key == "" || sharing > 0 {
+			log.Printf("[DeleteDocument] Deleted document %s; keeping its file %s (%d other documents use it)", id, doc.OriginalURL, sharing)
+			return nil
+		}
+		if s.s3Client != nil {
+			if err := s.deleteStoredFile(ctx, key); err != nil {
+				log.Printf("[DeleteDocument] Error deleting stored file of %s: %v", id, err)
+				if indexErr := s.indexDocument(ctx, doc); indexErr != nil {
+					log.Printf("[DeleteDocument] Error restoring index entry of %s: %v", id, indexErr)
+				}
+				return err
+			}
+		}
+		log.Printf("[DeleteDocument] Deleted document %s", id)
+		return nil
+	})
+}
+
+// rerootVersions makes the oldest remaining version the root of a lineage whose root document is
+// about to be deleted. Version rows cascade with their root document, so without this deleting the
+// first version would drop the version records of all later ones.
+func rerootVersions(tx *gorm.DB, rootID string) error {
+	if err := lockLineage(tx, rootID); err != nil {
+		return err
+	}
+	var next model.DocumentVersion
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("root_document_id = ? AND document_id <> ?", rootID, rootID).
+		Order("version_number").
+		First(&next).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Model(&model.DocumentVersion{}).
+		Where("root_document_id = ? AND document_id <> ?", rootID, rootID).
+		Update("root_document_id", next.DocumentID).Error
+}
+
+// storageKeyFromURL returns the S3 object key of a file URL built by the upload stage, or "" if the
+// URL does not point into the configured bucket
+func storageKeyFromURL(fileURL string) string {
+	bucket := os.Getenv("SUPABASE_BUCKET")
+	if bucket == "" {
+		return ""
+	}
+	marker := "/object/public/" + bucket + "/"
+	i := strings.Index(fileURL, marker)
+	if i < 0 {
+		return ""
+	}
+	return fileURL[i+len(marker):]
+}
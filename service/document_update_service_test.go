@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// recordingIndex is a SearchIndex that records indexed and deleted documents.
+type recordingIndex struct {
+	mu      sync.Mutex
+	indexed []string
+	deleted []string
+}
+
+func (r *recordingIndex) Name() string                    { return "recording" }
+func (r *recordingIndex) Setup(ctx context.Context) error { return nil }
+func (r *recordingIndex) Index(ctx context.Context, docs []model.Document) ([]IndexFailure, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, doc := range docs {
+		r.indexed = append(r.indexed, doc.ID)
+	}
+	return nil, nil
+}
+func (r *recordingIndex) Delete(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deleted = append(r.deleted, key)
+	return nil
+}
+func (r *recordingIndex) Entries(ctx context.Context) (map[string]time.Time, error) { return nil, nil }
+func (r *recordingIndex) Search(ctx context.Context, req SearchRequest) (*SearchResults, error) {
+	return &SearchResults{}, nil
+}
+
+// testDocumentID is the ID of the document answered by onDocument in tests.
+const testDocumentID = "11111111-2222-3333-4444-555555555555"
+
+var fakeDocumentColumns = []string{"id", "title", "category", "jurisdiction", "ocr_text", "original_url"}
+
+// onDocument answers lookups of a single document
+func onDocument(fake *fakeDB, id, category, ocrText, originalURL string) {
+	fake.on(`FROM "documents" WHERE id = \$1`, fakeDocumentColumns,
+		[]driver.Value{id, "Supply agreement", category, "us", ocrText, originalURL})
+}
+
+func TestPatchDocumentValidation(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	onDocument(fake, testDocumentID, "nda", "text", "")
+	s := &DocumentService{db: db}
+
+	empty, unknown := "  ", "spaceship"
+	cases := map[string]DocumentPatch{
+		"empty title":      {Title: &empty},
+		"unknown category": {Category: &unknown},
+		"no fields":        {},
+	}
+	for name, patch := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := s.PatchDocument(testDocumentID, patch)
+			assert.ErrorIs(t, err, ErrInvalidDocument)
+		})
+	}
+	assert.Empty(t, fake.executed(`^UPDATE`))
+}
+
+func TestDocumentLookupsRejectNonUUIDIDs(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	s := &DocumentService{db: db}
+	title := "Supply agreement"
+
+	_, err := s.GetDocument("not-a-uuid")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = s.PatchDocument("not-a-uuid", DocumentPatch{Title: &title})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, s.DeleteDocument(context.Background(), "not-a-uuid"), gorm.ErrRecordNotFound)
+	assert.Empty(t, fake.executed(`.`), "IDs that cannot exist are not looked up")
+}
+
+func TestPatchDocumentReevaluatesOnScopeChange(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	onDocument(fake, testDocumentID, "nda", "The supplier shall deliver.", "")
+	fake.on(`INSERT INTO "processing_jobs"`, []string{"id"}, []driver.Value{"job-1"})
+	index := &recordingIndex{}
+	s := &DocumentService{db: db, search: index}
+
+	title := "Renamed agreement"
+	update, err := s.PatchDocument(testDocumentID, DocumentPatch{Title: &title})
+	require.NoError(t, err)
+	assert.Nil(t, update.ReevaluationJob, "a title change does not affect which rules apply")
+	assert.Empty(t, fake.executed(`INSERT INTO "processing_jobs"`))
+
+	jurisdiction := "EU"
+	update, err = s.PatchDocument(testDocumentID, DocumentPatch{Jurisdiction: &jurisdiction})
+	require.NoError(t, err)
+	require.NotNil(t, update.ReevaluationJob)
+	assert.Equal(t, "job-1", update.ReevaluationJob.ID)
+	assert.Equal(t, model.JobTypeReevaluate, update.ReevaluationJob.Type)
+	assert.JSONEq(t, `{"document_ids":["11111111-2222-3333-4444-555555555555"]}`, string(update.ReevaluationJob.Params))
+	assert.Equal(t, []string{testDocumentID, testDocumentID}, index.indexed)
+
+	unchanged := "US"
+	update, err = s.PatchDocument(testDocumentID, DocumentPatch{Jurisdiction: &unchanged})
+	require.NoError(t, err)
+	assert.Nil(t, update.ReevaluationJob, "setting the same jurisdiction changes nothing")
+}
+
+func TestDeleteDocumentKeepsSharedFile(t *testing.T) {
+	t.Setenv("SUPABASE_BUCKET", "legal")
+	db, fake := newFakeGormDB(t)
+	onDocument(fake, testDocumentID, "nda", "text", "https://x.supabase.co/storage/v1/object/public/legal/a.pdf")
+	fake.on(`SELECT count\(\*\) FROM "documents"`, []string{"count"}, []driver.Value{int64(1)})
+	storage, index := newFakeS3(), &recordingIndex{}
+	s := &DocumentService{db: db, s3Client: storage, search: index}
+
+	require.NoError(t, s.DeleteDocument(context.Background(), testDocumentID))
+	assert.Len(t, fake.executed(`^DELETE FROM "documents"`), 1)
+	assert.Equal(t, []string{testDocumentID}, index.deleted)
+	assert.Empty(t, storage.deleted, "another document still uses the file")
+}
+
+func TestDeleteDocumentDeletesUnsharedFile(t *testing.T) {
+	t.Setenv("SUPABASE_BUCKET", "legal")
+	db, fake := newFakeGormDB(t)
+	onDocument(fake, testDocumentID, "nda", "text", "https://x.supabase.co/storage/v1/object/public/legal/a.pdf")
+	fake.on(`SELECT count\(\*\) FROM "documents"`, []string{"count"}, []driver.Value{int64(0)})
+	storage, index := newFakeS3(), &recordingIndex{}
+	s := &DocumentService{db: db, s3Client: storage, search: index}
+
+	require.NoError(t, s.DeleteDocument(context.Background(), testDocumentID))
+	assert.Equal(t, []string{testDocumentID}, index.deleted)
+	assert.Equal(t, []string{"a.pdf"}, storage.deleted)
+}
+
+func TestDeleteDocumentReRootsLaterVersions(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	onDocument(fake, testDocumentID, "nda", "text", "")
+	fake.on(`FROM "document_versions" WHERE root_document_id = \$1 AND document_id <> \$2`,
+		[]string{"id", "root_document_id", "document_id", "version_number"},
+		[]driver.Value{"ver-2", testDocumentID, "doc-2", int64(2)})
+	s := &DocumentService{db: db}
+
+	require.NoError(t, s.DeleteDocument(context.Background(), testDocumentID))
+
+	updates := fake.executed(`^UPDATE "document_versions" SET "root_document_id"`)
+	require.Len(t, updates, 1)
+	assert.Equal(t, []interface{}{"doc-2", testDocumentID, testDocumentID}, updates[0].Args)
+	assert.Contains(t, fake.executed(`FROM "document_versions"`)[0].SQL, "FOR UPDATE")
+
+	statements := fake.executed(`^(UPDATE "document_versions"|DELETE FROM "documents")`)
+	require.Len(t, statements, 2)
+	assert.Contains(t, statements[1].SQL, "DELETE", "the lineage is re-rooted before the root is deleted")
+}
+
+func TestDeleteDocumentWithoutLaterVersions(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	onDocument(fake, testDocumentID, "nda", "text", "")
+	s := &DocumentService{db: db}
+
+	require.NoError(t, s.DeleteDocument(context.Background(), testDocumentID))
+	assert.Empty(t, fake.executed(`^UPDATE "document_versions"`))
+	assert.Len(t, fake.executed(`^DELETE FROM "documents"`), 1)
+}
+
+func TestStorageKeyFromURL(t *testing.T) {
+	t.Setenv("SUPABASE_BUCKET", "legal")
+
+	assert.Equal(t, "1700000000-contract.pdf", storageKeyFromURL("https://x.supabase.co/storage/v1/object/public/legal/1700000000-contract.pdf"))
+	assert.Equal(t, "", storageKeyFromURL("https://x.supabase.co/storage/v1/object/public/other/1700000000-contract.pdf"))
+	assert.Equal(t, "", storageKeyFromURL(""))
+
+	t.Setenv("SUPABASE_BUCKET", "")
+	assert.Equal(t, "", storageKeyFromURL("https://x.supabase.co/storage/v1/object/public/legal/a.pdf"))
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// DefaultEmbeddingBaseURL is the OpenAI endpoint used when EMBEDDING_BASE_URL is not set.
+const DefaultEmbeddingBaseURL = "https://api.openai.com/v1"
+
+// DefaultStubEmbeddingDimensions is the vector size of the stub provider when EMBEDDING_DIMENSIONS is
+// not set.
+const DefaultStubEmbeddingDimensions = 256
+
+// openAIEmbeddingDimensions are the vector sizes of known OpenAI models. Other models need
+// EMBEDDING_DIMENSIONS.
+var openAIEmbeddingDimensions = map[string]int{
+	"text-embedding-3-small": 1536,
+	"text-embedding-3-large": 3072,
+	"text-embedding-ada-002": 1536,
+}
+
+// EmbeddingProvider computes embeddings of text passages.
+type EmbeddingProvider interface {
+	// Name identifies the provider and model. It is stored with every vector, since only vectors of
+	// the same model can be compared.
+	Name() string
+	// VectorDimensions is the size of every vector the provider returns.
+	VectorDimensions() int
+	// Embed returns one vector per text, in order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// NewEmbeddingProviderFromEnv selects the embedding provider configured by EMBEDDING_PROVIDER.
+// Supported values are "openai" and "stub"; when unset or "none" no provider is returned and documents
+// are not embedded.
+//
+//	EMBEDDING_BASE_URL     OpenAI-compatible base URL (default: OpenAI)
+//	EMBEDDING_API_KEY      API key, falling back to OPENAI_API_KEY
+//	EMBEDDING_MODEL        model (default: text-embedding-3-small)
+//	EMBEDDING_DIMENSIONS   vector size; required for models whose size is not known
+func NewEmbeddingProviderFromEnv() (EmbeddingProvider, error) {
+	provider := strings.ToLower(strings.TrimSpace(os.Getenv("EMBEDDING_PROVIDER")))
+	dimensions := 0
+	if value := os.Getenv("EMBEDDING_DIMENSIONS"); value != "" {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 {
+			return nil, fmt.Errorf("EMBEDDING_DIMENSIONS must be a positive integer, got %q", value)
+		}
+		dimensions = number
+	}
+
+	switch provider {
+	case "", "none":
+		return nil, nil
+	case "openai":
+		p := NewOpenAIEmbeddingProvider(os.Getenv("EMBEDDING_API_KEY"), os.Getenv("EMBEDDING_MODEL"))
+		if p.APIKey == "" {
+			p.APIKey = strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
+		}
+		if baseURL := os.Getenv("EMBEDDING_BASE_URL"); baseURL != "" {
+			p.BaseURL = baseURL
+		}
+		p.Dimensions = dimensions
+		if p.VectorDimensions() == 0 {
+			return nil, fmt.Errorf("EMBEDDING_DIMENSIONS is required for embedding model %q", p.Model)
+		}
+		return p, nil
+	case "stub", "fake":
+		if dimensions == 0 {
+			dimensions = DefaultStubEmbeddingDimensions
+		}
+		return NewStubEmbeddingProvider(dimensions), nil
+	default:
+		return nil, fmt.Errorf("unknown EMBEDDING_PROVIDER %q", provider)
+	}
+}
+
+// OpenAIEmbeddingProvider calls any server implementing the OpenAI embeddings API (OpenAI, Azure,
+// vLLM, Ollama, ...).
+type OpenAIEmbeddingProvider struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	// Dimensions asks the model for shorter vectors; zero keeps the model's own size.
+	Dimensions int
+	// BatchSize is the number of texts sent in one request.
+	BatchSize int
+	Client    *http.Client
+}
+
+// NewOpenAIEmbeddingProvider creates a provider using the OpenAI endpoint and text-embedding-3-small
+// unless another model is given.
+func NewOpenAIEmbeddingProvider(apiKey, model string) *OpenAIEmbeddingProvider {
+	if model == "" {
+		model = "text-embedding-3-small"
+	}
+	return &OpenAIEmbeddingProvider{
+		BaseURL:   DefaultEmbeddingBaseURL,
+		APIKey:    strings.TrimSpace(apiKey),
+		Model:     model,
+		BatchSize: 64,
+		Client:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (p *OpenAIEmbeddingProvider) Name() string {
+	if p.Dimensions > 0 {
+		return fmt.Sprintf("openai:%s:%d", p.Model, p.Dimensions)
+	}
+	return "openai:" + p.Model
+}
+
+// VectorDimensions returns the configured vector size, or the size of a known model
+func (p *OpenAIEmbeddingProvider) VectorDimensions() int {
+	if p.Dimensions > 0 {
+		return p.Dimensions
+	}
+	return openAIEmbeddingDimensions[p.Model]
+}
+
+// Embed sends the texts to /embeddings in batches of BatchSize
+func (p *OpenAIEmbeddingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	batchSize := p.BatchSize
+	if batchSize <= 0 {
+		batchSize = len(texts)
+	}
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		batch, err := p.embedBatch(ctx, texts[start:min(start+batchSize, len(texts))])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// embedBatch performs a single embeddings request
+func (p *OpenAIEmbeddingProvider) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	payload := map[string]interface{}{
+		"model": p.Model,
+		"input": texts,
+	}
+	if p.Dimensions > 0 {
+		payload["dimensions"] = p.Dimensions
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding request body: %w", err)
+	}
+
+	endpoint := strings.TrimRight(p.BaseURL, "/") + "/embeddings"
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("non-200 status code: %d, response: %s", resp.StatusCode, string(respBody))
+	}
+	return parseEmbeddingResponse(respBody, len(texts))
+}
+
+// parseEmbeddingResponse returns the vectors of an embeddings response in the order of the inputs
+func parseEmbeddingResponse(body []byte, count int) ([][]float32, error) {
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse embedding response: %w", err)
+	}
+	if len(result.Data) != count {
+		return nil, fmt.Errorf("embedding response has %d vectors for %d inputs", len(result.Data), count)
+	}
+	vectors := make([][]float32, count)
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= count || vectors[item.Index] != nil {
+			return nil, fmt.Errorf("embedding response has an unexpected index %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
+
+// StubEmbeddingProvider computes deterministic embeddings without contacting any service: each word
+// is hashed into one of Dimensions buckets, so texts sharing words have similar vectors. It is
+// intended for tests and local development; it does not capture meaning.
+type StubEmbeddingProvider struct {
+	mu         sync.Mutex
+	Dimensions int
+	// Err, when set, is returned instead of any vectors.
+	Err   error
+	Calls [][]string
+}
+
+// NewStubEmbeddingProvider creates a stub provider returning vectors of the given size.
+func NewStubEmbeddingProvider(dimensions int) *StubEmbeddingProvider {
+	return &StubEmbeddingProvider{Dimensions: dimensions}
+}
+
+func (p *StubEmbeddingProvider) Name() string { return fmt.Sprintf("stub:%d", p.Dimensions) }
+
+func (p *StubEmbeddingProvider) VectorDimensions() int { return p.Dimensions }
+
+// Embed records the call and returns the hashed word vectors of texts
+func (p *StubEmbeddingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Calls = append(p.Calls, texts)
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = p.embed(text)
+	}
+	return vectors, nil
+}
+
+// embed hashes the lower-cased words of text into a unit vector
+func (p *StubEmbeddingProvider) embed(text string) []float32 {
+	vector := make([]float32, p.Dimensions)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		sum := h.Sum64()
+		sign := float32(1)
+		if sum&1 == 1 {
+			sign = -1
+		}
+		vector[(sum>>1)%uint64(p.Dimensions)] += sign
+	}
+	return normalizeVector(vector)
+}
+
+// normalizeVector scales v to unit length; a zero vector is returned unchanged
+func normalizeVector(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return v
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] = float32(float64(v[i]) / norm)
+	}
+	return v
+}
+
+// isZeroVector reports whether every component of v is zero
+func isZeroVector(v []float32) bool {
+	for _, x := range v {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
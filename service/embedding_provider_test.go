@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIEmbeddingProvider_Embed(t *testing.T) {
+	var batches [][]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/embeddings", r.URL.Path)
+		assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "embed-model", body["model"])
+		assert.Equal(t, float64(3), body["dimensions"])
+		input := body["input"].([]interface{})
+		batches = append(batches, input)
+
+		// Answer out of order; the provider must restore the input order.
+		data := []map[string]interface{}{}
+		for i := len(input) - 1; i >= 0; i-- {
+			data = append(data, map[string]interface{}{"index": i, "embedding": []float64{float64(len(batches)), float64(i), 0}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIEmbeddingProvider("test-key", "embed-model")
+	provider.BaseURL = server.URL + "/v1"
+	provider.Dimensions = 3
+	provider.BatchSize = 2
+
+	vectors, err := provider.Embed(context.Background(), []string{"a", "b", "c"})
+	require.NoError(t, err)
+	assert.Len(t, batches, 2)
+	assert.Equal(t, [][]float32{{1, 0, 0}, {1, 1, 0}, {2, 0, 0}}, vectors)
+	assert.Equal(t, "openai:embed-model:3", provider.Name())
+}
+
+func TestParseEmbeddingResponse(t *testing.T) {
+	_, err := parseEmbeddingResponse([]byte(`{"data":[{"index":0,"embedding":[1]}]}`), 2)
+	assert.ErrorContains(t, err, "1 vectors for 2 inputs")
+
+	_, err = parseEmbeddingResponse([]byte(`{"data":[{"index":0,"embedding":[1]},{"index":0,"embedding":[2]}]}`), 2)
+	assert.ErrorContains(t, err, "unexpected index")
+}
+
+func TestStubEmbeddingProvider(t *testing.T) {
+	provider := NewStubEmbeddingProvider(64)
+	vectors, err := provider.Embed(context.Background(), []string{
+		"The Recipient shall keep the information confidential.",
+		"the recipient SHALL keep the information confidential",
+		"Fees are payable within thirty days of invoice.",
+	})
+	require.NoError(t, err)
+	require.Len(t, vectors, 3)
+	assert.Len(t, vectors[0], 64)
+	assert.Equal(t, vectors[0], vectors[1], "case and punctuation do not change the vector")
+	assert.InDelta(t, 1, cosine(vectors[0], vectors[0]), 1e-6)
+	assert.Greater(t, cosine(vectors[0], vectors[1]), cosine(vectors[0], vectors[2]))
+
+	empty, err := provider.Embed(context.Background(), []string{"..."})
+	require.NoError(t, err)
+	assert.True(t, isZeroVector(empty[0]))
+}
+
+func TestNewEmbeddingProviderFromEnv(t *testing.T) {
+	t.Setenv("EMBEDDING_PROVIDER", "")
+	provider, err := NewEmbeddingProviderFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, provider)
+
+	t.Setenv("EMBEDDING_PROVIDER", "stub")
+	provider, err = NewEmbeddingProviderFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "stub:256", provider.Name())
+
+	t.Setenv("EMBEDDING_PROVIDER", "openai")
+	t.Setenv("EMBEDDING_MODEL", "text-embedding-3-large")
+	t.Setenv("EMBEDDING_DIMENSIONS", "1024")
+	provider, err = NewEmbeddingProviderFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "openai:text-embedding-3-large:1024", provider.Name())
+
+	assert.Equal(t, 1024, provider.VectorDimensions())
+
+	t.Setenv("EMBEDDING_MODEL", "")
+	t.Setenv("EMBEDDING_DIMENSIONS", "")
+	provider, err = NewEmbeddingProviderFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, 1536, provider.VectorDimensions(), "known models need no dimensions")
+
+	t.Setenv("EMBEDDING_MODEL", "nomic-embed-text")
+	_, err = NewEmbeddingProviderFromEnv()
+	assert.ErrorContains(t, err, "EMBEDDING_DIMENSIONS is required")
+
+	t.Setenv("EMBEDDING_DIMENSIONS", "-1")
+	_, err = NewEmbeddingProviderFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("EMBEDDING_DIMENSIONS", "")
+	t.Setenv("EMBEDDING_PROVIDER", "word2vec")
+	_, err = NewEmbeddingProviderFromEnv()
+	assert.Error(t, err)
+}
+
+// cosine returns the cosine similarity of two vectors
+func cosine(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	return dot / math.Sqrt(normA*normB)
+}
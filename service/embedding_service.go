@@ -0,0 +1,545 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrSemanticSearchDisabled is returned by semantic searches when no embedding provider is configured.
+var ErrSemanticSearchDisabled = errors.New("semantic search is not enabled")
+
+// Chunk sizes in bytes of OCR text. Clauses longer than maxChunkBytes are split into overlapping
+// chunks so that a passage cut at a chunk boundary is still found whole.
+const (
+	maxChunkBytes     = 1200
+	chunkOverlapBytes = 200
+)
+
+// Semantic search result counts.
+const (
+	DefaultSemanticSearchSize = 10
+	MaxSemanticSearchSize     = 50
+)
+
+// DefaultKeywordWeight is the share of the keyword ranking in the hybrid score.
+const DefaultKeywordWeight = 0.5
+
+// semanticCandidates is the number of chunks each ranking contributes to the hybrid ranking.
+const semanticCandidates = 100
+
+// rrfK damps reciprocal rank fusion so that the top few ranks do not dominate the combined score.
+const rrfK = 60
+
+// maxIndexedDimensions is the largest vector size pgvector can index with HNSW.
+const maxIndexedDimensions = 2000
+
+// SemanticSearchRequest describes a hybrid keyword and vector search over document chunks.
+type SemanticSearchRequest struct {
+	Query string
+	// Size is the number of chunks to return, DefaultSemanticSearchSize when zero.
+	Size int
+	// KeywordWeight is the share of the keyword ranking in the score, from 0 (vectors only) to 1
+	// (keywords only); nil means DefaultKeywordWeight.
+	KeywordWeight *float64
+	Filter        SearchFilter
+}
+
+// SemanticHit is a matching chunk with the document it was taken from. VectorScore is the cosine
+// similarity of the chunk to the query and KeywordRank its full-text rank; each is omitted when the
+// chunk was not a candidate of that ranking.
+type SemanticHit struct {
+	ChunkID     string   `json:"chunk_id"`
+	ClauseID    *string  `json:"clause_id,omitempty"`
+	Position    int      `json:"position"`
+	Heading     string   `json:"heading,omitempty"`
+	ClauseType  string   `json:"clause_type,omitempty"`
+	Content     string   `json:"content"`
+	StartOffset int      `json:"start_offset"`
+	EndOffset   int      `json:"end_offset"`
+	Page        int      `json:"page"`
+	Score       float64  `json:"score"`
+	VectorScore *float64 `json:"vector_score,omitempty"`
+	KeywordRank *float64 `json:"keyword_rank,omitempty"`
+	// Document describes the chunk's document; its Score is the chunk's.
+	Document SearchHit `json:"document"`
+}
+
+// SemanticSearchResults are the best matching chunks, best first, and the embedding model used.
+type SemanticSearchResults struct {
+	Model string        `json:"model"`
+	Hits  []SemanticHit `json:"hits"`
+}
+
+// chunkMatch is a chunk ranked by one of the rankings of a hybrid search.
+type chunkMatch struct {
+	ID    string
+	Score float64
+}
+
+// rankedChunk is a chunk with its hybrid score and the scores it had in each ranking.
+type rankedChunk struct {
+	ID          string
+	Score       float64
+	VectorScore *float64
+	KeywordRank *float64
+}
+
+// chunkVectorIndexSQL creates the HNSW index over the vectors of one embedding model. The index is
+// named after a hash of the model, since model names may be longer than an identifier.
+func chunkVectorIndexSQL(embeddingModel string, dimensions int) string {
+	h := fnv.New32a()
+	h.Write([]byte(embeddingModel))
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_document_chunks_embedding_%08x ON document_chunks "+
+		"USING hnsw ((embedding::vector(%d)) vector_cosine_ops) WHERE embedding_model = '%s'",
+		h.Sum32(), dimensions, strings.ReplaceAll(embeddingModel, "'", "''"))
+}
+
+// chunkDistanceSQL is the cosine distance of a chunk to the query vector. It repeats the expression
+// of the model's index so that searches use it.
+func chunkDistanceSQL(dimensions int) string {
+	return fmt.Sprintf("embedding::vector(%d) <=> ?::vector(%d)", dimensions, dimensions)
+}
+
+// setupChunkStorage checks that the chunk table exists and creates the vector index of the
+// provider's model. The table is created by migration 022 only where pgvector is available.
+func setupChunkStorage(ctx context.Context, db *gorm.DB, embedder EmbeddingProvider) error {
+	var table *string
+	if err := db.WithContext(ctx).Raw("SELECT to_regclass('document_chunks')::text").Scan(&table).Error; err != nil {
+		return err
+	}
+	if table == nil {
+		return fmt.Errorf("the document_chunks table does not exist; install pgvector and run db/migrations/022_create_document_chunks.up.sql again")
+	}
+
+	dimensions := embedder.VectorDimensions()
+	if dimensions > maxIndexedDimensions {
+		log.Printf("Warning: %s vectors have %d dimensions, more than pgvector can index; semantic searches will scan every chunk",
+			embedder.Name(), dimensions)
+		return nil
+	}
+	return db.WithContext(ctx).Exec(chunkVectorIndexSQL(embedder.Name(), dimensions)).Error
+}
+
+// normalizeSemanticSearchRequest validates req and fills in the defaults
+func normalizeSemanticSearchRequest(req *SemanticSearchRequest) error {
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" {
+		return fmt.Errorf("%w: query is required", ErrInvalidSearch)
+	}
+	if req.Size <= 0 {
+		req.Size = DefaultSemanticSearchSize
+	}
+	if req.Size > MaxSemanticSearchSize {
+		req.Size = MaxSemanticSearchSize
+	}
+	if req.KeywordWeight == nil {
+		weight := DefaultKeywordWeight
+		req.KeywordWeight = &weight
+	}
+	if *req.KeywordWeight < 0 || *req.KeywordWeight > 1 {
+		return fmt.Errorf("%w: keyword_weight must be between 0 and 1", ErrInvalidSearch)
+	}
+	if req.Filter.MinRisk != nil && req.Filter.MaxRisk != nil && *req.Filter.MinRisk > *req.Filter.MaxRisk {
+		return fmt.Errorf("%w: min_risk must not exceed max_risk", ErrInvalidSearch)
+	}
+	return nil
+}
+
+// chunkDocument splits the OCR text of a document into chunks along its clauses. Clauses longer
+// than maxChunkBytes are split at sentence or word boundaries, overlapping by chunkOverlapBytes.
+// Without clauses the whole text is chunked. DocumentID and the embedding are left for the caller.
+func chunkDocument(text string, clauses []model.DocumentClause) []model.DocumentChunk {
+	type section struct {
+		span   textSpan
+		clause *model.DocumentClause
+	}
+	var sections []section
+	for i := range clauses {
+		clause := &clauses[i]
+		if clause.StartOffset < 0 || clause.EndOffset > len(text) || clause.StartOffset >= clause.EndOffset {
+			continue
+		}
+		sections = append(sections, section{textSpan{clause.StartOffset, clause.EndOffset}, clause})
+	}
+	if len(sections) == 0 {
+		sections = append(sections, section{span: textSpan{0, len(text)}})
+	}
+
+	pages := splitPages(text)
+	var chunks []model.DocumentChunk
+	for _, section := range sections {
+		for _, span := range splitPassage(text, section.span, maxChunkBytes, chunkOverlapBytes) {
+			chunk := model.DocumentChunk{
+				Position:    len(chunks),
+				Content:     strings.ReplaceAll(text[span.start:span.end], PageSeparator, "\n"),
+				StartOffset: span.start,
+				EndOffset:   span.end,
+				Page:        pageOf(pages, span.start),
+			}
+			if clause := section.clause; clause != nil {
+				if clause.ID != "" {
+					id := clause.ID
+					chunk.ClauseID = &id
+				}
+				chunk.Heading = strings.TrimSpace(clause.Number + " " + clause.Heading)
+				chunk.ClauseType = clause.ClauseType
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
+
+// splitPassage splits span of text into trimmed spans of at most maxBytes, each starting overlap
+// bytes before the end of the previous one
+func splitPassage(text string, span textSpan, maxBytes, overlap int) []textSpan {
+	var spans []textSpan
+	start := span.start
+	for start < span.end {
+		end := span.end
+		if end-start > maxBytes {
+			end = passageBreak(text, start, start+maxBytes)
+		}
+		if part := trimSpan(text, textSpan{start, end}); part.start < part.end {
+			spans = append(spans, part)
+		}
+		if end >= span.end {
+			break
+		}
+		next := end - overlap
+		if next <= start {
+			next = end
+		} else {
+			next = wordStart(text, next, end)
+		}
+		start = next
+	}
+	return spans
+}
+
+// passageBreak returns where to end a chunk starting at start and ending at limit at the latest:
+// after the last sentence or line in its second half, else after its last space, else at limit
+func passageBreak(text string, start, limit int) int {
+	half := start + (limit-start)/2
+	if i := strings.LastIndexAny(text[half:limit], "\n"+PageSeparator); i >= 0 {
+		return half + i + 1
+	}
+	for i := limit - 1; i > half; i-- {
+		if (text[i-1] == '.' || text[i-1] == ';') && unicode.IsSpace(rune(text[i])) {
+			return i
+		}
+	}
+	if i := strings.LastIndexAny(text[half:limit], " \t"); i >= 0 {
+		return half + i + 1
+	}
+	for limit > start && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return limit
+}
+
+// wordStart moves offset forward to the start of the next word, staying before end
+func wordStart(text string, offset, end int) int {
+	if offset > 0 && !unicode.IsSpace(rune(text[offset-1])) {
+		if i := strings.IndexAny(text[offset:end], " \t\n"+PageSeparator); i >= 0 {
+			return offset + i + 1
+		}
+	}
+	for offset < end && !utf8.RuneStart(text[offset]) {
+		offset++
+	}
+	return offset
+}
+
+// fuseRankings combines the vector and keyword candidates by weighted reciprocal rank fusion: a
+// chunk scores weight/(rrfK+rank) in each ranking it appears in. The result is ordered best first.
+func fuseRankings(vector, keyword []chunkMatch, keywordWeight float64) []rankedChunk {
+	byID := map[string]*rankedChunk{}
+	var ranked []*rankedChunk
+	add := func(matches []chunkMatch, weight float64, keywordRanking bool) {
+		for i, match := range matches {
+			chunk, ok := byID[match.ID]
+			if !ok {
+				chunk = &rankedChunk{ID: match.ID}
+				byID[match.ID] = chunk
+				ranked = append(ranked, chunk)
+			}
+			score := match.Score
+			if keywordRanking {
+				chunk.KeywordRank = &score
+			} else {
+				chunk.VectorScore = &score
+			}
+			chunk.Score += weight / float64(rrfK+i+1)
+		}
+	}
+	add(vector, 1-keywordWeight, false)
+	add(keyword, keywordWeight, true)
+
+	result := make([]rankedChunk, len(ranked))
+	for i, chunk := range ranked {
+		result[i] = *chunk
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// embedDocument replaces the chunks of a document with newly embedded ones and returns how many
+// were stored
+func (s *DocumentService) embedDocument(ctx context.Context, docID, text string, clauses []model.DocumentClause) (int, error) {
+	chunks := chunkDocument(text, clauses)
+	texts := make([]string, len(chunks))
+	for i := range chunks {
+		texts[i] = chunks[i].Content
+	}
+
+	var vectors [][]float32
+	if len(texts) > 0 {
+		var err error
+		if vectors, err = s.embedder.Embed(ctx, texts); err != nil {
+			return 0, fmt.Errorf("failed to embed chunks with %s: %w", s.embedder.Name(), err)
+		}
+		if len(vectors) != len(chunks) {
+			return 0, fmt.Errorf("%s returned %d embeddings for %d chunks", s.embedder.Name(), len(vectors), len(chunks))
+		}
+	}
+	now := time.Now()
+	for i := range chunks {
+		if len(vectors[i]) != s.embedder.VectorDimensions() {
+			return 0, fmt.Errorf("%s returned an embedding of %d dimensions for chunk %d", s.embedder.Name(), len(vectors[i]), i)
+		}
+		chunks[i].DocumentID = docID
+		chunks[i].EmbeddingModel = s.embedder.Name()
+		chunks[i].Embedding = model.Vector(vectors[i])
+		chunks[i].CreatedAt = now
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", docID).Delete(&model.DocumentChunk{}).Error; err != nil {
+			return fmt.Errorf("failed to delete chunks: %w", err)
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+		if err := tx.CreateInBatches(&chunks, 100).Error; err != nil {
+			return fmt.Errorf("failed to save chunks: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(chunks), nil
+}
+
+// stageEmbed chunks the stored document along its clauses and saves the embeddings of the chunks.
+// Like indexing it runs after the document is committed, so a failure is logged rather than undoing
+// the upload; the embed command repairs it.
+func (s *DocumentService) stageEmbed(ctx context.Context, state *pipelineState) error {
+	if s.embedder == nil {
+		return errStageSkipped
+	}
+	count, err := s.embedDocument(ctx, state.doc.ID, state.ocrText, state.clauses)
+	if err != nil {
+		log.Printf("Embedding error for document %s: %v", state.doc.ID, err)
+		return nil
+	}
+	log.Printf("Embedded %d chunks of document %s with %s", count, state.doc.ID, s.embedder.Name())
+	return nil
+}
+
+// EmbedDocuments embeds the stored documents that have no chunks from the configured provider, or
+// every document when all is set. Documents are embedded one at a time; a failure is recorded and the
+// rest continue.
+func (s *DocumentService) EmbedDocuments(ctx context.Context, all bool) (*model.JobProgress, error) {
+	if s.embedder == nil {
+		return nil, ErrSemanticSearchDisabled
+	}
+	query := s.db.WithContext(ctx).Model(&model.Document{}).Where("COALESCE(ocr_text, '') <> ''")
+	if !all {
+		query = query.Where("NOT EXISTS (SELECT 1 FROM document_chunks c WHERE c.document_id = documents.id AND c.embedding_model = ?)", s.embedder.Name())
+	}
+	var ids []string
+	if err := query.Order("created_at, id").Pluck("id", &ids).Error; err != nil {
+		log.Printf("[EmbedDocuments] Error listing documents: %v", err)
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	progress := &model.JobProgress{Total: len(ids)}
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return progress, ctx.Err()
+		}
+		progress.Processed++
+		if err := s.embedStoredDocument(ctx, id); err != nil {
+			log.Printf("[EmbedDocuments] Error embedding document %s: %v", id, err)
+			progress.Failed++
+			addProgressError(progress, id, err.Error())
+			continue
+		}
+		progress.Changed++
+	}
+	log.Printf("[EmbedDocuments] Embedded %d of %d documents with %s (%d failed)", progress.Changed, progress.Total, s.embedder.Name(), progress.Failed)
+	return progress, nil
+}
+
+// embedStoredDocument embeds a stored document along its clauses, extracting them if needed
+func (s *DocumentService) embedStoredDocument(ctx context.Context, id string) error {
+	var doc model.Document
+	if err := s.db.WithContext(ctx).Select("id", "ocr_text").First(&doc, "id = ?", id).Error; err != nil {
+		return err
+	}
+	clauses, err := s.GetDocumentClauses(id, "")
+	if err != nil {
+		return fmt.Errorf("failed to load clauses: %w", err)
+	}
+	_, err = s.embedDocument(ctx, id, doc.OcrText, clauses)
+	return err
+}
+
+// SemanticSearch finds the document chunks closest in meaning to the query. The chunks nearest to
+// the query embedding and the best full-text matches are combined by weighted reciprocal rank
+// fusion, so passages that paraphrase the query rank alongside those using its words.
+func (s *DocumentService) SemanticSearch(ctx context.Context, req SemanticSearchRequest) (*SemanticSearchResults, error) {
+	if s.embedder == nil {
+		return nil, ErrSemanticSearchDisabled
+	}
+	if err := normalizeSemanticSearchRequest(&req); err != nil {
+		return nil, err
+	}
+	documents, err := filterDocuments(s.db.Model(&model.Document{}).Select("id"), req.Filter.documentFilter())
+	if err != nil {
+		return nil, err
+	}
+
+	var vectorMatches, keywordMatches []chunkMatch
+	if *req.KeywordWeight < 1 {
+		vectors, err := s.embedder.Embed(ctx, []string{req.Query})
+		if err != nil {
+			log.Printf("[SemanticSearch] Error embedding query: %v", err)
+			return nil, fmt.Errorf("failed to embed query with %s: %w", s.embedder.Name(), err)
+		}
+		if len(vectors) != 1 {
+			return nil, fmt.Errorf("%s returned %d embeddings for the query", s.embedder.Name(), len(vectors))
+		}
+		// A query without any words has a zero vector, which has no direction to compare.
+		if query := model.Vector(vectors[0]); !isZeroVector(query) {
+			distance := clause.Expr{SQL: chunkDistanceSQL(s.embedder.VectorDimensions()), Vars: []interface{}{query}, WithoutParentheses: true}
+			err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				// An HNSW scan returns at most ef_search chunks, 40 unless raised.
+				if err := tx.Exec(fmt.Sprintf("SET LOCAL hnsw.ef_search = %d", semanticCandidates)).Error; err != nil {
+					return err
+				}
+				return tx.Model(&model.DocumentChunk{}).
+					Select("id, 1 - ("+distance.SQL+") AS score", query).
+					Where("embedding_model = ? AND document_id IN (?)", s.embedder.Name(), documents).
+					Order(clause.OrderBy{Expression: distance}).
+					Limit(semanticCandidates).
+					Scan(&vectorMatches).Error
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to rank chunks by similarity: %w", err)
+			}
+		}
+	}
+	if *req.KeywordWeight > 0 {
+		err = s.db.WithContext(ctx).Model(&model.DocumentChunk{}).
+			Select("id, ts_rank_cd(search_vector, "+searchQuerySQL+") AS score", req.Query).
+			Where("search_vector @@ "+searchQuerySQL, req.Query).
+			Where("document_id IN (?)", documents).
+			Order("score DESC, id").
+			Limit(semanticCandidates).
+			Scan(&keywordMatches).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to rank chunks by keywords: %w", err)
+		}
+	}
+
+	ranked := fuseRankings(vectorMatches, keywordMatches, *req.KeywordWeight)
+	if len(ranked) > req.Size {
+		ranked = ranked[:req.Size]
+	}
+	hits, err := s.semanticHits(ctx, ranked)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[SemanticSearch] %q matched %d chunks (%d by similarity, %d by keywords) with %s",
+		req.Query, len(hits), len(vectorMatches), len(keywordMatches), s.embedder.Name())
+	return &SemanticSearchResults{Model: s.embedder.Name(), Hits: hits}, nil
+}
+
+// semanticHits loads the ranked chunks with their documents, keeping the order of ranked
+func (s *DocumentService) semanticHits(ctx context.Context, ranked []rankedChunk) ([]SemanticHit, error) {
+	hits := []SemanticHit{}
+	if len(ranked) == 0 {
+		return hits, nil
+	}
+	ids := make([]string, len(ranked))
+	for i, chunk := range ranked {
+		ids[i] = chunk.ID
+	}
+	var chunks []model.DocumentChunk
+	if err := s.db.WithContext(ctx).Omit("embedding").Where("id IN ?", ids).Find(&chunks).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch matching chunks: %w", err)
+	}
+	chunksByID := make(map[string]model.DocumentChunk, len(chunks))
+	var docIDs []string
+	for _, chunk := range chunks {
+		chunksByID[chunk.ID] = chunk
+		docIDs = append(docIDs, chunk.DocumentID)
+	}
+	var documents []model.Document
+	if err := s.db.WithContext(ctx).Omit("ocr_text").Where("id IN ?", removeDuplicates(docIDs)).Find(&documents).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch documents of matching chunks: %w", err)
+	}
+	documentsByID := make(map[string]model.Document, len(documents))
+	for _, doc := range documents {
+		documentsByID[doc.ID] = doc
+	}
+
+	for _, match := range ranked {
+		chunk, ok := chunksByID[match.ID]
+		if !ok {
+			continue
+		}
+		doc, ok := documentsByID[chunk.DocumentID]
+		if !ok {
+			continue
+		}
+		hit := SemanticHit{
+			ChunkID:     chunk.ID,
+			ClauseID:    chunk.ClauseID,
+			Position:    chunk.Position,
+			Heading:     chunk.Heading,
+			ClauseType:  chunk.ClauseType,
+			Content:     chunk.Content,
+			StartOffset: chunk.StartOffset,
+			EndOffset:   chunk.EndOffset,
+			Page:        chunk.Page,
+			Score:       match.Score,
+			VectorScore: match.VectorScore,
+			KeywordRank: match.KeywordRank,
+			Document:    postgresSearchHit(doc),
+		}
+		hit.Document.Score = match.Score
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
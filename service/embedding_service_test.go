@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkDocument(t *testing.T) {
+	long := strings.Repeat("The Recipient shall keep all Confidential Information secret. ", 50)
+	text := "1. Definitions\nTerms used here.\n" + PageSeparator + "2. Confidentiality\n" + long
+	clauses := ExtractClauses(text)
+	require.Len(t, clauses, 2)
+	clauses[0].ID = "clause-1"
+
+	chunks := chunkDocument(text, clauses)
+	require.Greater(t, len(chunks), 3)
+	assert.Equal(t, "clause-1", *chunks[0].ClauseID)
+	assert.Equal(t, "1 Definitions", chunks[0].Heading)
+	assert.Equal(t, 1, chunks[0].Page)
+	assert.Nil(t, chunks[1].ClauseID)
+	assert.Equal(t, "2 Confidentiality", chunks[1].Heading)
+	assert.Equal(t, ClauseTypeConfidentiality, chunks[1].ClauseType)
+	assert.Equal(t, 2, chunks[1].Page)
+
+	for i, chunk := range chunks {
+		assert.Equal(t, i, chunk.Position)
+		assert.LessOrEqual(t, chunk.EndOffset-chunk.StartOffset, maxChunkBytes)
+		assert.Equal(t, strings.ReplaceAll(text[chunk.StartOffset:chunk.EndOffset], PageSeparator, "\n"), chunk.Content)
+		if i > 1 {
+			// Chunks of a long clause overlap, break after a sentence and start at a word.
+			assert.Less(t, chunk.StartOffset, chunks[i-1].EndOffset)
+			assert.Equal(t, byte(' '), text[chunk.StartOffset-1], "chunk %d starts mid-word", i)
+			assert.True(t, strings.HasSuffix(chunks[i-1].Content, "."), "chunk %d ends mid-sentence", i-1)
+		}
+	}
+	assert.Equal(t, len(strings.TrimSpace(text)), chunks[len(chunks)-1].EndOffset)
+
+	// Without clauses the whole text is chunked.
+	chunks = chunkDocument("  A short note.  ", nil)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, "A short note.", chunks[0].Content)
+	assert.Empty(t, chunkDocument("", nil))
+}
+
+func TestFuseRankings(t *testing.T) {
+	vector := []chunkMatch{{ID: "paraphrase", Score: 0.9}, {ID: "both", Score: 0.8}}
+	keyword := []chunkMatch{{ID: "both", Score: 0.4}, {ID: "words", Score: 0.3}}
+
+	ranked := fuseRankings(vector, keyword, 0.5)
+	require.Len(t, ranked, 3)
+	assert.Equal(t, "both", ranked[0].ID)
+	assert.Equal(t, 0.8, *ranked[0].VectorScore)
+	assert.Equal(t, 0.4, *ranked[0].KeywordRank)
+	assert.Equal(t, "paraphrase", ranked[1].ID)
+	assert.Nil(t, ranked[1].KeywordRank)
+	assert.Equal(t, "words", ranked[2].ID)
+
+	ranked = fuseRankings(vector, keyword, 1)
+	assert.Equal(t, "both", ranked[0].ID)
+	assert.Equal(t, "words", ranked[1].ID)
+	assert.Zero(t, ranked[2].Score)
+
+	ranked = fuseRankings(vector, keyword, 0)
+	assert.Equal(t, "paraphrase", ranked[0].ID)
+}
+
+func TestNormalizeSemanticSearchRequest(t *testing.T) {
+	req := SemanticSearchRequest{Query: " keep secret ", Size: 500}
+	require.NoError(t, normalizeSemanticSearchRequest(&req))
+	assert.Equal(t, "keep secret", req.Query)
+	assert.Equal(t, MaxSemanticSearchSize, req.Size)
+	assert.Equal(t, DefaultKeywordWeight, *req.KeywordWeight)
+
+	weight := 1.5
+	assert.ErrorIs(t, normalizeSemanticSearchRequest(&SemanticSearchRequest{Query: "secret", KeywordWeight: &weight}), ErrInvalidSearch)
+	assert.ErrorIs(t, normalizeSemanticSearchRequest(&SemanticSearchRequest{Query: "  "}), ErrInvalidSearch)
+}
+
+func TestVectorValueAndScan(t *testing.T) {
+	value, err := model.Vector{0.5, -1, 0.25}.Value()
+	require.NoError(t, err)
+	assert.Equal(t, "[0.5,-1,0.25]", value)
+
+	var vector model.Vector
+	require.NoError(t, vector.Scan([]byte("[0.5, -1, 0.25]")))
+	assert.Equal(t, model.Vector{0.5, -1, 0.25}, vector)
+	assert.Error(t, vector.Scan("0.5,1"))
+}
+
+func TestChunkVectorIndexSQL(t *testing.T) {
+	statement := chunkVectorIndexSQL("openai:it's-a-model", 256)
+	assert.Contains(t, statement, "USING hnsw ((embedding::vector(256)) vector_cosine_ops)")
+	assert.Contains(t, statement, "WHERE embedding_model = 'openai:it''s-a-model'")
+	assert.Contains(t, statement, "("+strings.SplitN(chunkDistanceSQL(256), " <=>", 2)[0]+")",
+		"searches must repeat the indexed expression")
+	indexName := func(statement string) string { return strings.Fields(statement)[5] }
+	assert.NotEqual(t, indexName(chunkVectorIndexSQL("stub:256", 256)), indexName(chunkVectorIndexSQL("stub:128", 128)),
+		"each model has its own index")
+}
+
+func TestSetupChunkStorage(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	err := setupChunkStorage(context.Background(), db, NewStubEmbeddingProvider(64))
+	assert.ErrorContains(t, err, "022_create_document_chunks", "a database without pgvector has no chunk table")
+	assert.Empty(t, fake.executed(`^CREATE`))
+
+	db, fake = newFakeGormDB(t)
+	fake.on(`to_regclass\('document_chunks'\)`, []string{"to_regclass"}, []driver.Value{"document_chunks"})
+	require.NoError(t, setupChunkStorage(context.Background(), db, NewStubEmbeddingProvider(64)))
+	statements := fake.executed(`^CREATE`)
+	require.Len(t, statements, 1, "only the model's vector index is created at startup")
+	assert.Contains(t, statements[0].SQL, "USING hnsw ((embedding::vector(64))")
+}
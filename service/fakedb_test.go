@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeDB is an in-memory database/sql driver for testing code that queries through gorm. Each
+// statement is answered by the first handler whose pattern matches it; other statements affect no
+// rows and return none. Every statement is recorded with its arguments.
+type fakeDB struct {
+	mu         sync.Mutex
+	handlers   []*fakeHandler
+	statements []fakeStatement
+}
+
+// fakeStatement is a statement run against a fakeDB.
+type fakeStatement struct {
+	SQL  string
+	Args []interface{}
+}
+
+// fakeHandler answers the statements matching pattern.
+type fakeHandler struct {
+	pattern  *regexp.Regexp
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+	// once removes the handler after its first use so that the next match falls through.
+	once bool
+}
+
+// newFakeGormDB opens a gorm connection with the Postgres dialect to a new fakeDB
+func newFakeGormDB(t *testing.T) (*gorm.DB, *fakeDB) {
+	fake := &fakeDB{}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(fake)}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	return db, fake
+}
+
+// on answers queries matching pattern with rows of the given columns
+func (f *fakeDB) on(pattern string, columns []string, rows ...[]driver.Value) *fakeHandler {
+	return f.add(&fakeHandler{pattern: regexp.MustCompile(pattern), columns: columns, rows: rows})
+}
+
+// onExec reports affected rows for statements matching pattern
+func (f *fakeDB) onExec(pattern string, affected int64) *fakeHandler {
+	return f.add(&fakeHandler{pattern: regexp.MustCompile(pattern), affected: affected})
+}
+
+// fail makes statements matching pattern fail with err
+func (f *fakeDB) fail(pattern string, err error) *fakeHandler {
+	return f.add(&fakeHandler{pattern: regexp.MustCompile(pattern), err: err})
+}
+
+func (f *fakeDB) add(h *fakeHandler) *fakeHandler {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers = append(f.handlers, h)
+	return h
+}
+
+// onlyOnce makes the handler answer a single statement
+func (h *fakeHandler) onlyOnce() *fakeHandler {
+	h.once = true
+	return h
+}
+
+// executed returns the recorded statements matching pattern
+func (f *fakeDB) executed(pattern string) []fakeStatement {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	re := regexp.MustCompile(pattern)
+	var matches []fakeStatement
+	for _, statement := range f.statements {
+		if re.MatchString(statement.SQL) {
+			matches = append(matches, statement)
+		}
+	}
+	return matches
+}
+
+// handle records a statement and returns the handler answering it, if any
+func (f *fakeDB) handle(query string, args []driver.NamedValue) *fakeHandler {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	f.statements = append(f.statements, fakeStatement{SQL: query, Args: values})
+	for i, h := range f.handlers {
+		if h.pattern.MatchString(query) {
+			if h.once {
+				f.handlers = append(f.handlers[:i:i], f.handlers[i+1:]...)
+			}
+			return h
+		}
+	}
+	return nil
+}
+
+// Connect and Driver make fakeDB a driver.Connector.
+func (f *fakeDB) Connect(ctx context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                            { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("fake driver connections are opened through fakeDB")
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fake driver does not prepare statements")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+// CheckNamedValue passes every argument to the handlers unchanged
+func (c *fakeConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	h := c.db.handle(query, args)
+	if h == nil {
+		return driver.RowsAffected(0), nil
+	}
+	if h.err != nil {
+		return nil, h.err
+	}
+	return driver.RowsAffected(h.affected), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	h := c.db.handle(query, args)
+	if h == nil {
+		return &fakeRows{}, nil
+	}
+	if h.err != nil {
+		return nil, h.err
+	}
+	return &fakeRows{columns: h.columns, rows: h.rows}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,604 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// jobQueueSize bounds the number of job IDs buffered in memory; jobs are persisted regardless.
+const jobQueueSize = 100
+
+// jobPollInterval is how often idle workers look for jobs that were not handed to them directly.
+const jobPollInterval = 10 * time.Second
+
+// jobLeaseDuration is how long a running job stays claimed without its worker renewing the lease.
+const jobLeaseDuration = 2 * time.Minute
+
+// ErrIdempotencyKeyConflict is returned when an idempotency key is reused for a different upload.
+var ErrIdempotencyKeyConflict = errors.New("idempotency key was already used for a different upload")
+
+// ErrParentDocumentNotFound is returned when an upload names a parent document that does not exist.
+var ErrParentDocumentNotFound = errors.New("parent document not found")
+
+// UploadOptions carries the optional parameters of an upload request.
+type UploadOptions struct {
+	// IdempotencyKey makes retried submissions return the job created by the first request.
+	IdempotencyKey string
+	// NewVersion processes a file even if a document with identical content already exists. The
+	// result is recorded as a new version of the identical document unless ParentDocumentID is set.
+	NewVersion bool
+	// ParentDocumentID records the upload as a revision of an existing document.
+	ParentDocumentID string
+	// Category, Jurisdiction and Tags describe the document and decide which rules apply to it.
+	Category     string
+	Jurisdiction string
+	Tags         []string
+}
+
+// SubmitResult describes the outcome of SubmitDocument.
+type SubmitResult struct {
+	Job *model.ProcessingJob
+	// Replayed is true when the idempotency key matched an earlier submission.
+	Replayed bool
+	// Duplicate is the existing document with identical content; no job is created in that case.
+	Duplicate *model.Document
+	// InProgress is true when Job is an earlier, unfinished job for identical content.
+	InProgress bool
+}
+
+// SubmitDocument stores the upload as a queued processing job and hands it to the worker pool
+func (s *DocumentService) SubmitDocument(file multipart.File, header *multipart.FileHeader, opts UploadOptions) (*SubmitResult, error) {
+	log.Printf("[SubmitDocument] File details: Name=%s, Size=%d", header.Filename, header.Size)
+
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		log.Printf("[SubmitDocument] ERROR reading file: %v", err)
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	hash := contentHash(fileBytes)
+
+	if opts.ParentDocumentID != "" {
+		exists, err := s.documentExists(opts.ParentDocumentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up parent document: %w", err)
+		}
+		if !exists {
+			return nil, ErrParentDocumentNotFound
+		}
+	}
+
+	if opts.IdempotencyKey != "" {
+		if existing, err := s.findJobByIdempotencyKey(opts.IdempotencyKey); err != nil {
+			return nil, err
+		} else if existing != nil {
+			return s.replayJob(existing, hash)
+		}
+	}
+
+	// Identical content is only processed again when a new version is explicitly requested.
+	existingDoc, err := s.findDocumentByContentHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if existingDoc != nil && !opts.NewVersion {
+		log.Printf("[SubmitDocument] %s is identical to document %s; skipping processing", header.Filename, existingDoc.ID)
+		return &SubmitResult{Duplicate: existingDoc}, nil
+	}
+	if existingDoc == nil && !opts.NewVersion {
+		if inFlight, err := s.findUnfinishedJobByContentHash(hash); err != nil {
+			return nil, err
+		} else if inFlight != nil {
+			log.Printf("[SubmitDocument] %s is already being processed by job %s", header.Filename, inFlight.ID)
+			return &SubmitResult{Job: inFlight, InProgress: true}, nil
+		}
+	}
+
+	stages := make([]model.JobStage, 0)
+	for _, name := range s.pipelineStageNames() {
+		stages = append(stages, model.JobStage{Name: name, Status: model.JobStatusPending})
+	}
+	stagesJSON, err := json.Marshal(stages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job stages: %w", err)
+	}
+
+	job := model.ProcessingJob{
+		Type:        model.JobTypeUpload,
+		Status:      model.JobStatusQueued,
+		Stages:      datatypes.JSON(stagesJSON),
+		FileName:    header.Filename,
+		ContentType: header.Header.Get("Content-Type"),
+		FileID:      newFileID(header.Filename),
+		ContentHash: hash,
+		Input:       fileBytes,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if opts.IdempotencyKey != "" {
+		job.IdempotencyKey = &opts.IdempotencyKey
+	}
+	if opts.ParentDocumentID != "" {
+		job.ParentDocumentID = &opts.ParentDocumentID
+	}
+	scope := DocumentScope{Category: opts.Category, Jurisdiction: opts.Jurisdiction, Tags: opts.Tags}
+	if scope.normalize(); scope.Category != "" || scope.Jurisdiction != "" || len(scope.Tags) > 0 {
+		params, err := json.Marshal(scope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal job params: %w", err)
+		}
+		job.Params = datatypes.JSON(params)
+	}
+	if existingDoc != nil {
+		// Reuse the stored file and OCR text of the identical document.
+		job.SourceDocumentID = &existingDoc.ID
+		job.Input = nil
+		if job.ParentDocumentID == nil {
+			job.ParentDocumentID = &existingDoc.ID
+		}
+	}
+	if err := s.db.Create(&job).Error; err != nil {
+		// A concurrent request with the same key may have won the race for the unique index.
+		if opts.IdempotencyKey != "" {
+			if existing, findErr := s.findJobByIdempotencyKey(opts.IdempotencyKey); findErr == nil && existing != nil {
+				return s.replayJob(existing, hash)
+			}
+		}
+		log.Printf("[SubmitDocument] ERROR creating processing job: %v", err)
+		return nil, fmt.Errorf("failed to create processing job: %w", err)
+	}
+	log.Printf("[SubmitDocument] Queued processing job %s for %s", job.ID, job.FileName)
+
+	s.enqueueJob(job.ID)
+	job.Input = nil
+	return &SubmitResult{Job: &job}, nil
+}
+
+// findJobByIdempotencyKey returns the job created with key, or nil if there is none
+func (s *DocumentService) findJobByIdempotencyKey(key string) (*model.ProcessingJob, error) {
+	var jobs []model.ProcessingJob
+	if err := s.db.Omit("Input").Where("idempotency_key = ?", key).Limit(1).Find(&jobs).Error; err != nil {
+		log.Printf("[SubmitDocument] Error looking up idempotency key: %v", err)
+		return nil, fmt.Errorf("failed to look up idempotency key: %w", err)
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	return &jobs[0], nil
+}
+
+// findDocumentByContentHash returns the most recent document with the given content hash, or nil
+func (s *DocumentService) findDocumentByContentHash(hash string) (*model.Document, error) {
+	var docs []model.Document
+	if err := s.db.Where("content_hash = ?", hash).Order("created_at DESC").Limit(1).Find(&docs).Error; err != nil {
+		log.Printf("[SubmitDocument] Error looking up content hash: %v", err)
+		return nil, fmt.Errorf("failed to look up content hash: %w", err)
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	return &docs[0], nil
+}
+
+// findUnfinishedJobByContentHash returns a queued or running job for identical content, or nil
+func (s *DocumentService) findUnfinishedJobByContentHash(hash string) (*model.ProcessingJob, error) {
+	var jobs []model.ProcessingJob
+	if err := s.db.Omit("Input").
+		Where("content_hash = ? AND status IN ?", hash, []string{model.JobStatusQueued, model.JobStatusRunning}).
+		Order("created_at").Limit(1).Find(&jobs).Error; err != nil {
+		log.Printf("[SubmitDocument] Error looking up unfinished jobs: %v", err)
+		return nil, fmt.Errorf("failed to look up unfinished jobs: %w", err)
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	return &jobs[0], nil
+}
+
+// replayJob returns an earlier submission for a retried request
+func (s *DocumentService) replayJob(job *model.ProcessingJob, hash string) (*SubmitResult, error) {
+	if job.ContentHash != hash {
+		return nil, ErrIdempotencyKeyConflict
+	}
+	log.Printf("[SubmitDocument] Idempotency key matched job %s; not creating a new job", job.ID)
+	return &SubmitResult{Job: job, Replayed: true}, nil
+}
+
+// GetJob retrieves a processing job without its stored input
+func (s *DocumentService) GetJob(jobID string) (*model.ProcessingJob, error) {
+	if !uuidPattern.MatchString(jobID) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var job model.ProcessingJob
+	if err := s.db.Omit("Input").First(&job, "id = ?", jobID).Error; err != nil {
+		log.Printf("[GetJob] Error fetching job %s: %v", jobID, err)
+		return nil, err
+	}
+	return &job, nil
+}
+
+// StartJobWorkers starts the worker pool. Besides the jobs handed over by enqueueJob, workers poll
+// for queued jobs and for running jobs whose lease expired, so jobs left unfinished by a stopped
+// instance are resumed without taking over the jobs other instances are still running.
+func (s *DocumentService) StartJobWorkers(ctx context.Context, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if s.jobQueue == nil {
+		s.jobQueue = make(chan string, jobQueueSize)
+	}
+
+	for i := 0; i < workers; i++ {
+		go s.jobWorker(ctx, i+1)
+	}
+	log.Printf("[JobWorkers] Started %d workers polling every %s", workers, jobPollInterval)
+	return nil
+}
+
+// enqueueJob hands a job ID to the workers without blocking the caller. When the queue is full the
+// job is left queued in the database, where a polling worker picks it up.
+func (s *DocumentService) enqueueJob(jobID string) {
+	if s.jobQueue == nil {
+		log.Printf("[JobWorkers] Workers not started; job %s stays queued", jobID)
+		return
+	}
+	select {
+	case s.jobQueue <- jobID:
+	default:
+		log.Printf("[JobWorkers] Queue full; job %s waits for the next poll", jobID)
+	}
+}
+
+// jobWorker processes jobs from the queue and from polling until ctx is cancelled
+func (s *DocumentService) jobWorker(ctx context.Context, id int) {
+	poll := time.NewTicker(jobPollInterval)
+	defer poll.Stop()
+
+	s.runClaimableJobs(ctx, id)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("[JobWorker %d] Stopping", id)
+			return
+		case jobID := <-s.jobQueue:
+			if err := s.runJob(ctx, jobID); err != nil {
+				log.Printf("[JobWorker %d] Job %s failed: %v", id, jobID, err)
+			}
+		case <-poll.C:
+			s.runClaimableJobs(ctx, id)
+		}
+	}
+}
+
+// runClaimableJobs runs claimable jobs one after another until there are none left
+func (s *DocumentService) runClaimableJobs(ctx context.Context, id int) {
+	for ctx.Err() == nil {
+		job, err := s.claimNextJob(ctx)
+		if err != nil {
+			log.Printf("[JobWorker %d] Error claiming a job: %v", id, err)
+			return
+		}
+		if job == nil {
+			return
+		}
+		if err := s.executeJob(ctx, job); err != nil {
+			log.Printf("[JobWorker %d] Job %s failed: %v", id, job.ID, err)
+		}
+	}
+}
+
+// claimUpdates are the column updates that mark a job as running under a new lease
+func claimUpdates() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"Status":         model.JobStatusRunning,
+		"StartedAt":      now,
+		"UpdatedAt":      now,
+		"Attempts":       gorm.Expr("attempts + 1"),
+		"Error":          "",
+		"CompletedAt":    nil,
+		"LeaseExpiresAt": jobLeaseExpiry(),
+	}
+}
+
+// jobLeaseExpiry is the end of a lease taken now, computed by the database so that the clocks of
+// the instances sharing it do not matter
+func jobLeaseExpiry() clause.Expr {
+	return gorm.Expr("now() + make_interval(secs => ?)", jobLeaseDuration.Seconds())
+}
+
+// claimNextJob claims the oldest queued job, or a running job whose lease expired because the
+// instance running it stopped. Rows locked by other workers are skipped. It returns nil when no job
+// can be claimed.
+func (s *DocumentService) claimNextJob(ctx context.Context) (*model.ProcessingJob, error) {
+	var claimed *model.ProcessingJob
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var candidates []model.ProcessingJob
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Select("id").
+			Where("status = ? OR (status = ? AND (lease_expires_at IS NULL OR lease_expires_at < now()))",
+				model.JobStatusQueued, model.JobStatusRunning).
+			Order("created_at").
+			Limit(1).
+			Find(&candidates).Error; err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&model.ProcessingJob{}).Where("id = ?", candidates[0].ID).Updates(claimUpdates()).Error; err != nil {
+			return err
+		}
+		var job model.ProcessingJob
+		if err := tx.First(&job, "id = ?", candidates[0].ID).Error; err != nil {
+			return err
+		}
+		claimed = &job
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+	if claimed != nil && claimed.Attempts > 1 {
+		log.Printf("[claimNextJob] Resuming job %s (attempt %d)", claimed.ID, claimed.Attempts)
+	}
+	return claimed, nil
+}
+
+// renewJobLease extends the lease of a running job until ctx is cancelled, so that other workers
+// do not claim it while it makes progress
+func (s *DocumentService) renewJobLease(ctx context.Context, jobID string) {
+	ticker := time.NewTicker(jobLeaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			renewal := s.db.Model(&model.ProcessingJob{}).
+				Where("id = ? AND status = ?", jobID, model.JobStatusRunning).
+				Update("lease_expires_at", jobLeaseExpiry())
+			if renewal.Error != nil {
+				log.Printf("[renewJobLease] Error renewing the lease of job %s: %v", jobID, renewal.Error)
+			} else if renewal.RowsAffected == 0 {
+				return
+			}
+		}
+	}
+}
+
+// runJob claims a queued job by ID and runs it
+func (s *DocumentService) runJob(ctx context.Context, jobID string) error {
+	claim := s.db.Model(&model.ProcessingJob{}).
+		Where("id = ? AND status = ?", jobID, model.JobStatusQueued).
+		Updates(claimUpdates())
+	if claim.Error != nil {
+		return fmt.Errorf("failed to claim job: %w", claim.Error)
+	}
+	if claim.RowsAffected == 0 {
+		log.Printf("[runJob] Job %s is no longer queued; skipping", jobID)
+		return nil
+	}
+
+	var job model.ProcessingJob
+	if err := s.db.First(&job, "id = ?", jobID).Error; err != nil {
+		return fmt.Errorf("failed to load job: %w", err)
+	}
+	return s.executeJob(ctx, &job)
+}
+
+// executeJob runs a claimed job, renewing its lease until it finishes, and records per-stage progress
+func (s *DocumentService) executeJob(ctx context.Context, job *model.ProcessingJob) error {
+	leaseCtx, stopRenewing := context.WithCancel(ctx)
+	defer stopRenewing()
+	go s.renewJobLease(leaseCtx, job.ID)
+
+	switch job.Type {
+	case model.JobTypeReevaluate:
+		return s.runReevaluationJob(ctx, job)
+	case model.JobTypeReindex:
+		return s.runReindexJob(ctx, job)
+	}
+	return s.runUploadJob(ctx, job)
+}
+
+// runUploadJob runs the pipeline for an uploaded file
+func (s *DocumentService) runUploadJob(ctx context.Context, job *model.ProcessingJob) error {
+	tracker := newJobTracker(s, job, s.pipelineStageNames())
+
+	// The document was committed before the process stopped; running the pipeline again would
+	// create a duplicate, so only the job bookkeeping is finished.
+	if job.DocumentID != nil {
+		log.Printf("[runUploadJob] Job %s already persisted document %s; completing without reprocessing", job.ID, *job.DocumentID)
+		var doc model.Document
+		if err := s.db.First(&doc, "id = ?", *job.DocumentID).Error; err != nil {
+			tracker.fail(err)
+			return fmt.Errorf("failed to load persisted document: %w", err)
+		}
+		tracker.complete(&pipelineState{
+			fileID:         job.FileID,
+			fileURL:        doc.OriginalURL,
+			ocrText:        doc.OcrText,
+			parsedDataJSON: doc.ParsedData,
+			riskScore:      doc.RiskScore,
+			classification: Classification{Category: doc.Category, Confidence: doc.CategoryConfidence, Source: doc.CategorySource},
+			doc:            doc,
+		})
+		return nil
+	}
+
+	state := &pipelineState{
+		fileBytes:   job.Input,
+		filename:    job.FileName,
+		contentType: job.ContentType,
+		fileID:      job.FileID,
+		contentHash: job.ContentHash,
+		jobID:       job.ID,
+	}
+	if job.ParentDocumentID != nil {
+		state.parentDocID = *job.ParentDocumentID
+	}
+	if len(job.Params) > 0 {
+		if err := json.Unmarshal(job.Params, &state.scope); err != nil {
+			tracker.fail(err)
+			return fmt.Errorf("failed to decode job params: %w", err)
+		}
+	}
+	if job.SourceDocumentID != nil {
+		var source model.Document
+		if err := s.db.First(&source, "id = ?", *job.SourceDocumentID).Error; err != nil {
+			tracker.fail(err)
+			return fmt.Errorf("failed to load source document: %w", err)
+		}
+		state.sourceDoc = &source
+	}
+
+	if err := s.runPipeline(ctx, state, tracker); err != nil {
+		tracker.fail(err)
+		return err
+	}
+	tracker.complete(state)
+	return nil
+}
+
+// jobTracker records stage progress of a running job in the processing_jobs table.
+type jobTracker struct {
+	s      *DocumentService
+	job    *model.ProcessingJob
+	stages []model.JobStage
+}
+
+// newJobTracker creates a tracker for a new attempt of job. Every attempt starts from the first
+// stage, so the stage list is rebuilt from the current pipeline.
+func newJobTracker(s *DocumentService, job *model.ProcessingJob, stageNames []string) *jobTracker {
+	stages := make([]model.JobStage, len(stageNames))
+	for i, name := range stageNames {
+		stages[i] = model.JobStage{Name: name, Status: model.JobStatusPending}
+	}
+	return &jobTracker{s: s, job: job, stages: stages}
+}
+
+// stage returns the entry for name, adding it if it is not part of the stage list
+func (t *jobTracker) stage(name string) *model.JobStage {
+	for i := range t.stages {
+		if t.stages[i].Name == name {
+			return &t.stages[i]
+		}
+	}
+	t.stages = append(t.stages, model.JobStage{Name: name, Status: model.JobStatusPending})
+	return &t.stages[len(t.stages)-1]
+}
+
+func (t *jobTracker) stageStarted(name string) {
+	now := time.Now()
+	stage := t.stage(name)
+	stage.Status = model.JobStatusRunning
+	stage.StartedAt = &now
+	t.job.CurrentStage = name
+	t.save(map[string]interface{}{"CurrentStage": name})
+}
+
+func (t *jobTracker) stageFinished(name string, err error) {
+	now := time.Now()
+	stage := t.stage(name)
+	stage.FinishedAt = &now
+	if stage.StartedAt != nil {
+		stage.DurationMs = now.Sub(*stage.StartedAt).Milliseconds()
+	}
+	switch {
+	case errors.Is(err, errStageSkipped):
+		stage.Status = model.JobStatusSkipped
+	case err != nil:
+		stage.Status = model.JobStatusFailed
+		stage.Error = err.Error()
+	default:
+		stage.Status = model.JobStatusCompleted
+	}
+	t.save(nil)
+}
+
+// fail marks the job as failed; the input is kept for inspection
+func (t *jobTracker) fail(err error) {
+	now := time.Now()
+	t.save(map[string]interface{}{
+		"Status":      model.JobStatusFailed,
+		"Error":       err.Error(),
+		"CompletedAt": now,
+	})
+}
+
+// complete marks an upload job as completed, stores a summary of the result and drops the raw input
+func (t *jobTracker) complete(state *pipelineState) {
+	t.completeWith(map[string]interface{}{
+		"fileID":            state.fileID,
+		"fileURL":           state.fileURL,
+		"complianceResults": state.complianceResults,
+		"riskScore":         state.riskScore,
+		"classification":    state.classification,
+	}, state.doc.ID)
+}
+
+// completeWith marks the job as completed with summary as its result
+func (t *jobTracker) completeWith(summary interface{}, documentID string) {
+	now := time.Now()
+	result, err := json.Marshal(summary)
+	if err != nil {
+		log.Printf("[jobTracker] Error marshaling result for job %s: %v", t.job.ID, err)
+		result = []byte("{}")
+	}
+	for i := range t.stages {
+		if t.stages[i].Status == model.JobStatusPending {
+			t.stages[i].Status = model.JobStatusSkipped
+		}
+	}
+	updates := map[string]interface{}{
+		"Status":       model.JobStatusCompleted,
+		"CurrentStage": "",
+		"Result":       datatypes.JSON(result),
+		"Input":        nil,
+		"CompletedAt":  now,
+	}
+	if documentID != "" {
+		updates["DocumentID"] = documentID
+	}
+	t.save(updates)
+}
+
+// saveProgress records the progress of a job that processes many items
+func (t *jobTracker) saveProgress(progress model.JobProgress) {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		log.Printf("[jobTracker] Error marshaling progress for job %s: %v", t.job.ID, err)
+		return
+	}
+	t.save(map[string]interface{}{"Progress": datatypes.JSON(data)})
+}
+
+// save persists the stage list together with any additional column updates
+func (t *jobTracker) save(updates map[string]interface{}) {
+	stagesJSON, err := json.Marshal(t.stages)
+	if err != nil {
+		log.Printf("[jobTracker] Error marshaling stages for job %s: %v", t.job.ID, err)
+		return
+	}
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["Stages"] = datatypes.JSON(stagesJSON)
+	updates["UpdatedAt"] = time.Now()
+
+	if err := t.s.db.Model(&model.ProcessingJob{}).Where("id = ?", t.job.ID).Updates(updates).Error; err != nil {
+		log.Printf("[jobTracker] Error updating job %s: %v", t.job.ID, err)
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"runtime"
+	"testing"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestEnqueueJob_FullQueueDoesNotBlock(t *testing.T) {
+	s := &DocumentService{jobQueue: make(chan string, 1)}
+	goroutines := runtime.NumGoroutine()
+
+	s.enqueueJob("job-1")
+	s.enqueueJob("job-2")
+	s.enqueueJob("job-3")
+
+	assert.Len(t, s.jobQueue, 1)
+	assert.Equal(t, "job-1", <-s.jobQueue)
+	assert.Equal(t, goroutines, runtime.NumGoroutine(), "jobs that do not fit stay queued in the database")
+}
+
+func TestClaimNextJob(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	fake.on(`^SELECT "id" FROM "processing_jobs"`, []string{"id"}, []driver.Value{"job-1"})
+	fake.on(`^SELECT \* FROM "processing_jobs" WHERE id = \$1`, []string{"id", "type", "status", "attempts"},
+		[]driver.Value{"job-1", model.JobTypeReindex, model.JobStatusRunning, int64(2)})
+	s := &DocumentService{db: db}
+
+	job, err := s.claimNextJob(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, "job-1", job.ID)
+	assert.Equal(t, 2, job.Attempts)
+
+	selects := fake.executed(`^SELECT "id" FROM "processing_jobs"`)
+	require.Len(t, selects, 1)
+	assert.Contains(t, selects[0].SQL, "FOR UPDATE SKIP LOCKED", "jobs locked by other workers are skipped")
+	assert.Contains(t, selects[0].SQL, "lease_expires_at < now()", "running jobs are only claimed once their lease expired")
+	assert.Equal(t, []interface{}{model.JobStatusQueued, model.JobStatusRunning}, selects[0].Args[:2])
+
+	claims := fake.executed(`^UPDATE "processing_jobs"`)
+	require.Len(t, claims, 1)
+	assert.Contains(t, claims[0].SQL, `"lease_expires_at"=now() + make_interval(secs => $`)
+	assert.Contains(t, claims[0].Args, jobLeaseDuration.Seconds())
+	assert.Contains(t, claims[0].Args, model.JobStatusRunning)
+	assert.Contains(t, claims[0].Args, "job-1")
+}
+
+func TestClaimNextJob_NothingToClaim(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	s := &DocumentService{db: db}
+
+	job, err := s.claimNextJob(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, job)
+	assert.Empty(t, fake.executed(`^UPDATE`))
+}
+
+func TestGetJob_NonUUIDIsNotFound(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	s := &DocumentService{db: db}
+
+	_, err := s.GetJob("not-a-uuid")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Empty(t, fake.executed(`.`))
+}
+
+func TestStartJobWorkers_LeavesRunningJobsAlone(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	s := &DocumentService{db: db}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.NoError(t, s.StartJobWorkers(ctx, 2))
+	assert.Empty(t, fake.executed(`^UPDATE "processing_jobs"`), "jobs running on other instances are not re-queued")
+}
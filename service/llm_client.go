@@ -0,0 +1,324 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LLMTask identifies what an LLM call is used for so that each task can use its own model and timeout.
+type LLMTask string
+
+const (
+	LLMTaskRuleDetection      LLMTask = "rule_detection"
+	LLMTaskBatchRuleDetection LLMTask = "batch_rule_detection"
+	LLMTaskRuleCompliance     LLMTask = "rule_compliance"
+	LLMTaskClassification     LLMTask = "classification"
+)
+
+// DefaultLLMBaseURL is the OpenAI-compatible Groq endpoint used when LLM_BASE_URL is not set.
+const DefaultLLMBaseURL = "https://api.groq.com/openai/v1"
+
+// ErrLLMRateLimited is returned when the server keeps answering 429 after all retries.
+var ErrLLMRateLimited = errors.New("llm rate limit exceeded")
+
+// ChatMessage is a single message of a chat completion request.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatRequest describes a chat completion independent of the provider.
+type ChatRequest struct {
+	Task        LLMTask
+	Messages    []ChatMessage
+	Temperature float64
+	MaxTokens   int
+	// JSONResponse asks the server to return a JSON object.
+	JSONResponse bool
+}
+
+// LLMClient sends chat completion requests and returns the content of the first choice.
+type LLMClient interface {
+	Complete(ctx context.Context, req ChatRequest) (string, error)
+}
+
+// LLMConfig configures an OpenAI-compatible chat completion client.
+type LLMConfig struct {
+	BaseURL      string
+	APIKey       string
+	DefaultModel string
+	Models       map[LLMTask]string
+	Timeout      time.Duration
+	TaskTimeouts map[LLMTask]time.Duration
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// TaskRetryBackoffs overrides RetryBackoff for individual tasks.
+	TaskRetryBackoffs map[LLMTask]time.Duration
+}
+
+// LLMConfigFromEnv builds the LLM configuration from environment variables.
+//
+//	LLM_BASE_URL             OpenAI-compatible base URL (default: Groq)
+//	LLM_API_KEY              API key, falling back to GROQ_API_KEY and VITE_GROQ_API_KEY
+//	LLM_MODEL                default model
+//	LLM_MODEL_<TASK>         model for a task, e.g. LLM_MODEL_RULE_COMPLIANCE
+//	LLM_TIMEOUT_SECONDS      request timeout
+//	LLM_TIMEOUT_<TASK>       request timeout in seconds for a task
+//	LLM_MAX_RETRIES          attempts after the first one
+//	LLM_RETRY_BACKOFF_MS     base backoff between attempts
+//	LLM_RETRY_BACKOFF_<TASK> base backoff in milliseconds for a task
+func LLMConfigFromEnv() LLMConfig {
+	cfg := LLMConfig{
+		BaseURL:      DefaultLLMBaseURL,
+		DefaultModel: "llama-3.3-70b-versatile",
+		Models: map[LLMTask]string{
+			LLMTaskRuleDetection:      "llama-3.3-70b-versatile",
+			LLMTaskBatchRuleDetection: "llama-3.3-70b-versatile",
+			LLMTaskRuleCompliance:     "mixtral-8x7b-32768",
+		},
+		Timeout: 30 * time.Second,
+		TaskTimeouts: map[LLMTask]time.Duration{
+			LLMTaskBatchRuleDetection: 60 * time.Second,
+			LLMTaskRuleCompliance:     45 * time.Second,
+		},
+		MaxRetries:   2,
+		RetryBackoff: 10 * time.Second,
+		// Rule detection backs off long enough for Groq's rate limit to reset; the other tasks have
+		// always retried quickly.
+		TaskRetryBackoffs: map[LLMTask]time.Duration{
+			LLMTaskBatchRuleDetection: time.Second,
+			LLMTaskRuleCompliance:     time.Second,
+		},
+	}
+
+	if baseURL := os.Getenv("LLM_BASE_URL"); baseURL != "" {
+		cfg.BaseURL = baseURL
+	}
+	for _, key := range []string{"LLM_API_KEY", "GROQ_API_KEY", "VITE_GROQ_API_KEY"} {
+		if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+			cfg.APIKey = value
+			break
+		}
+	}
+	if model := os.Getenv("LLM_MODEL"); model != "" {
+		cfg.DefaultModel = model
+		// An explicit default model overrides the built-in per-task defaults.
+		cfg.Models = map[LLMTask]string{}
+	}
+	for _, task := range []LLMTask{LLMTaskRuleDetection, LLMTaskBatchRuleDetection, LLMTaskRuleCompliance, LLMTaskClassification} {
+		suffix := strings.ToUpper(string(task))
+		if model := os.Getenv("LLM_MODEL_" + suffix); model != "" {
+			cfg.Models[task] = model
+		}
+		if seconds, err := strconv.Atoi(os.Getenv("LLM_TIMEOUT_" + suffix)); err == nil && seconds > 0 {
+			cfg.TaskTimeouts[task] = time.Duration(seconds) * time.Second
+		}
+	}
+	if seconds, err := strconv.Atoi(os.Getenv("LLM_TIMEOUT_SECONDS")); err == nil && seconds > 0 {
+		cfg.Timeout = time.Duration(seconds) * time.Second
+	}
+	if retries, err := strconv.Atoi(os.Getenv("LLM_MAX_RETRIES")); err == nil && retries >= 0 {
+		cfg.MaxRetries = retries
+	}
+	if ms, err := strconv.Atoi(os.Getenv("LLM_RETRY_BACKOFF_MS")); err == nil && ms >= 0 {
+		cfg.RetryBackoff = time.Duration(ms) * time.Millisecond
+		// An explicit default backoff overrides the built-in per-task defaults.
+		cfg.TaskRetryBackoffs = map[LLMTask]time.Duration{}
+	}
+	for _, task := range []LLMTask{LLMTaskRuleDetection, LLMTaskBatchRuleDetection, LLMTaskRuleCompliance, LLMTaskClassification} {
+		if ms, err := strconv.Atoi(os.Getenv("LLM_RETRY_BACKOFF_" + strings.ToUpper(string(task)))); err == nil && ms >= 0 {
+			cfg.TaskRetryBackoffs[task] = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return cfg
+}
+
+// ModelFor returns the model configured for a task.
+func (c LLMConfig) ModelFor(task LLMTask) string {
+	if model, ok := c.Models[task]; ok && model != "" {
+		return model
+	}
+	return c.DefaultModel
+}
+
+// TimeoutFor returns the request timeout configured for a task.
+func (c LLMConfig) TimeoutFor(task LLMTask) time.Duration {
+	if timeout, ok := c.TaskTimeouts[task]; ok && timeout > 0 {
+		return timeout
+	}
+	return c.Timeout
+}
+
+// RetryBackoffFor returns the base backoff between attempts configured for a task.
+func (c LLMConfig) RetryBackoffFor(task LLMTask) time.Duration {
+	if backoff, ok := c.TaskRetryBackoffs[task]; ok {
+		return backoff
+	}
+	return c.RetryBackoff
+}
+
+// OpenAIClient talks to any server implementing the OpenAI chat completions API (Groq, OpenAI, vLLM, Ollama, ...).
+type OpenAIClient struct {
+	config     LLMConfig
+	httpClient *http.Client
+}
+
+// NewOpenAIClient creates a chat completion client for the given configuration.
+func NewOpenAIClient(config LLMConfig) *OpenAIClient {
+	return &OpenAIClient{
+		config: config,
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				MaxIdleConns:        10,
+				IdleConnTimeout:     60 * time.Second,
+				DisableCompression:  true,
+				TLSHandshakeTimeout: 15 * time.Second,
+			},
+		},
+	}
+}
+
+// Config returns the configuration of the client.
+func (c *OpenAIClient) Config() LLMConfig {
+	return c.config
+}
+
+// Complete sends the request to /chat/completions, retrying on transport errors, 429 and 5xx responses.
+func (c *OpenAIClient) Complete(ctx context.Context, req ChatRequest) (string, error) {
+	payload := map[string]interface{}{
+		"model":       c.config.ModelFor(req.Task),
+		"messages":    req.Messages,
+		"temperature": req.Temperature,
+	}
+	if req.MaxTokens > 0 {
+		payload["max_tokens"] = req.MaxTokens
+	}
+	if req.JSONResponse {
+		payload["response_format"] = map[string]string{"type": "json_object"}
+	}
+
+	reqBody, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request body: %w", err)
+	}
+
+	endpoint := strings.TrimRight(c.config.BaseURL, "/") + "/chat/completions"
+	var lastErr error
+	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			waitTime := time.Duration(attempt) * c.config.RetryBackoffFor(req.Task)
+			log.Printf("[LLM] Retrying %s request in %v (attempt %d)", req.Task, waitTime, attempt+1)
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(waitTime):
+			}
+		}
+
+		content, retry, err := c.do(ctx, endpoint, req.Task, reqBody)
+		if err == nil {
+			return content, nil
+		}
+		lastErr = err
+		log.Printf("[LLM] %s request attempt %d failed: %v", req.Task, attempt+1, err)
+		if !retry {
+			break
+		}
+	}
+	return "", lastErr
+}
+
+// do performs a single request. The boolean result reports whether the failure is worth retrying.
+func (c *OpenAIClient) do(ctx context.Context, endpoint string, task LLMTask, body []byte) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.TimeoutFor(task))
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create LLM request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.config.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return "", true, fmt.Errorf("LLM request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", true, fmt.Errorf("failed to read LLM response: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return "", true, fmt.Errorf("%w: %s", ErrLLMRateLimited, resp.Status)
+	case resp.StatusCode >= 500:
+		return "", true, fmt.Errorf("non-200 status code: %d, response: %s", resp.StatusCode, string(respBody))
+	case resp.StatusCode != http.StatusOK:
+		return "", false, fmt.Errorf("non-200 status code: %d, response: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", false, fmt.Errorf("failed to parse LLM response structure: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", false, fmt.Errorf("LLM response contained no choices")
+	}
+	return result.Choices[0].Message.Content, false, nil
+}
+
+// StubLLMClient is a deterministic LLMClient for tests and offline development.
+// Responses are looked up by task; Handler, when set, takes precedence.
+type StubLLMClient struct {
+	mu        sync.Mutex
+	Responses map[LLMTask]string
+	Handler   func(req ChatRequest) (string, error)
+	Err       error
+	Requests  []ChatRequest
+}
+
+// NewStubLLMClient creates a stub that answers each task with a fixed response.
+func NewStubLLMClient(responses map[LLMTask]string) *StubLLMClient {
+	return &StubLLMClient{Responses: responses}
+}
+
+// Complete records the request and returns the canned response for its task
+func (c *StubLLMClient) Complete(ctx context.Context, req ChatRequest) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Requests = append(c.Requests, req)
+	if c.Err != nil {
+		return "", c.Err
+	}
+	if c.Handler != nil {
+		return c.Handler(req)
+	}
+	response, ok := c.Responses[req.Task]
+	if !ok {
+		return "", fmt.Errorf("no stub response for task %s", req.Task)
+	}
+	return response, nil
+}
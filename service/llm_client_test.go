@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLLMConfig(baseURL string) LLMConfig {
+	return LLMConfig{
+		BaseURL:      baseURL,
+		APIKey:       "test-key",
+		DefaultModel: "default-model",
+		Models:       map[LLMTask]string{LLMTaskRuleCompliance: "compliance-model"},
+		Timeout:      5 * time.Second,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	}
+}
+
+func TestOpenAIClient_Complete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "compliance-model", body["model"])
+		assert.Equal(t, map[string]interface{}{"type": "json_object"}, body["response_format"])
+
+		w.Write([]byte(`{"choices":[{"message":{"content":"{\"status\":\"pass\"}"}}]}`))
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient(testLLMConfig(server.URL + "/v1"))
+	content, err := client.Complete(context.Background(), ChatRequest{
+		Task:         LLMTaskRuleCompliance,
+		Messages:     []ChatMessage{{Role: "user", Content: "check"}},
+		JSONResponse: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{"status":"pass"}`, content)
+}
+
+func TestOpenAIClient_RetriesRateLimit(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient(testLLMConfig(server.URL))
+	content, err := client.Complete(context.Background(), ChatRequest{Task: LLMTaskRuleDetection})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", content)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestOpenAIClient_DoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient(testLLMConfig(server.URL))
+	_, err := client.Complete(context.Background(), ChatRequest{Task: LLMTaskRuleDetection})
+	assert.ErrorContains(t, err, "401")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestLLMConfigFromEnv(t *testing.T) {
+	t.Setenv("LLM_BASE_URL", "http://localhost:11434/v1")
+	t.Setenv("LLM_API_KEY", "")
+	t.Setenv("GROQ_API_KEY", "")
+	t.Setenv("VITE_GROQ_API_KEY", "vite-key")
+	t.Setenv("LLM_MODEL", "")
+	t.Setenv("LLM_MODEL_RULE_DETECTION", "local-model")
+	t.Setenv("LLM_TIMEOUT_SECONDS", "12")
+	t.Setenv("LLM_MAX_RETRIES", "0")
+
+	cfg := LLMConfigFromEnv()
+	assert.Equal(t, "http://localhost:11434/v1", cfg.BaseURL)
+	assert.Equal(t, "vite-key", cfg.APIKey)
+	assert.Equal(t, "local-model", cfg.ModelFor(LLMTaskRuleDetection))
+	assert.Equal(t, "mixtral-8x7b-32768", cfg.ModelFor(LLMTaskRuleCompliance))
+	assert.Equal(t, 12*time.Second, cfg.TimeoutFor(LLMTaskRuleDetection))
+	assert.Equal(t, 0, cfg.MaxRetries)
+}
+
+func TestLLMConfigFromEnv_RetryBackoff(t *testing.T) {
+	t.Setenv("LLM_RETRY_BACKOFF_MS", "")
+	t.Setenv("LLM_RETRY_BACKOFF_CLASSIFICATION", "250")
+
+	cfg := LLMConfigFromEnv()
+	assert.Equal(t, 10*time.Second, cfg.RetryBackoffFor(LLMTaskRuleDetection))
+	assert.Equal(t, time.Second, cfg.RetryBackoffFor(LLMTaskRuleCompliance), "compliance checks keep their quick retries")
+	assert.Equal(t, time.Second, cfg.RetryBackoffFor(LLMTaskBatchRuleDetection))
+	assert.Equal(t, 250*time.Millisecond, cfg.RetryBackoffFor(LLMTaskClassification))
+
+	t.Setenv("LLM_RETRY_BACKOFF_MS", "500")
+	cfg = LLMConfigFromEnv()
+	assert.Equal(t, 500*time.Millisecond, cfg.RetryBackoffFor(LLMTaskRuleCompliance))
+	assert.Equal(t, 250*time.Millisecond, cfg.RetryBackoffFor(LLMTaskClassification))
+}
+
+func TestCheckRuleCompliance_WithStubLLM(t *testing.T) {
+	stub := NewStubLLMClient(map[LLMTask]string{
+		LLMTaskRuleCompliance: `{"status":"partial_pass","confidence_score":80}`,
+	})
+	s := &DocumentService{llm: stub}
+
+	result, err := s.CheckRuleCompliance("This document is Confidential", "Confidentiality Marking", "Confidential")
+	require.NoError(t, err)
+	assert.Equal(t, "fail", result["status"])
+	assert.Equal(t, "Confidentiality Marking", result["rule_name"])
+	require.Len(t, stub.Requests, 1)
+	assert.Equal(t, LLMTaskRuleCompliance, stub.Requests[0].Task)
+	assert.Contains(t, stub.Requests[0].Messages[1].Content, "Initial Compliance Check: true")
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// obligationLeadDays is how many days before the contract date an obligation has to be acted on
+// when the contract does not say, e.g. 90 days before an auto-renewal without a notice period.
+var obligationLeadDays = map[string]int{
+	model.ObligationRenewal:  90,
+	model.ObligationExpiry:   90,
+	model.ObligationPayment:  0,
+	model.ObligationDeadline: 0,
+}
+
+// obligationPriorities is the priority of the reminder action item of each obligation type.
+var obligationPriorities = map[string]string{
+	model.ObligationRenewal:  "High",
+	model.ObligationExpiry:   "Medium",
+	model.ObligationPayment:  "High",
+	model.ObligationDeadline: "Medium",
+}
+
+var (
+	datedObligationPattern = regexp.MustCompile(`(?i)` + datePattern)
+	obligationCuePattern   = regexp.MustCompile(`(?i)\b(?:shall|must|due|payable|no\s+later\s+than|on\s+or\s+before|deadline)\b`)
+	paymentCuePattern      = regexp.MustCompile(`(?i)\b(?:pay|paid|payable|payment|invoice|fees?|rent|instal+ments?)\b`)
+)
+
+// DeriveObligations finds the dated obligations of a document from its text and key terms. The end of
+// the term follows from the effective date and duration; an auto-renewing contract is rolled forward
+// to its next renewal after now. Sentences that tie a date to "shall", "due" or similar become payment
+// or deadline obligations. Obligations dated before now are marked past.
+func DeriveObligations(text string, terms []model.DocumentTerm, now time.Time) []model.Obligation {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	var obligations []model.Obligation
+	add := func(obligation model.Obligation) {
+		if obligation.DueDate.Before(today) {
+			obligation.Status = model.ObligationPast
+		} else {
+			obligation.Status = model.ObligationOpen
+		}
+		obligations = append(obligations, obligation)
+	}
+
+	effective, duration, renewal := firstTerm(terms, model.TermEffectiveDate), firstTerm(terms, model.TermDuration), firstTerm(terms, model.TermRenewal)
+	if effective != nil && effective.DateValue != nil && duration != nil && duration.NumberValue != nil {
+		end := addPeriod(*effective.DateValue, *duration.NumberValue, duration.Unit)
+		if renewal != nil {
+			// Without a stated renewal period the contract renews for its original term.
+			period, unit := *duration.NumberValue, duration.Unit
+			if renewal.NumberValue != nil {
+				period, unit = *renewal.NumberValue, renewal.Unit
+			}
+			for renewals := 0; end.Before(today) && renewals < 100; renewals++ {
+				end = addPeriod(end, period, unit)
+			}
+			obligation := model.Obligation{
+				ObligationType: model.ObligationRenewal,
+				Description:    fmt.Sprintf("Contract renews automatically on %s for %s %s", end.Format("2006-01-02"), formatNumber(period), unit),
+				DueDate:        end,
+				ActBy:          end.AddDate(0, 0, -obligationLeadDays[model.ObligationRenewal]),
+				SourceText:     renewal.SourceText,
+				StartOffset:    renewal.StartOffset,
+				EndOffset:      renewal.EndOffset,
+			}
+			if notice := nearestTerm(terms, model.TermNoticePeriod, renewal.StartOffset); notice != nil && notice.NumberValue != nil {
+				obligation.ActBy = addPeriod(end, -*notice.NumberValue, notice.Unit)
+				obligation.Description += fmt.Sprintf(" unless notice of non-renewal is given by %s (%s notice)", obligation.ActBy.Format("2006-01-02"), notice.Value)
+			}
+			add(obligation)
+		} else {
+			add(model.Obligation{
+				ObligationType: model.ObligationExpiry,
+				Description:    fmt.Sprintf("Contract term of %s ends on %s", duration.Value, end.Format("2006-01-02")),
+				DueDate:        end,
+				ActBy:          end.AddDate(0, 0, -obligationLeadDays[model.ObligationExpiry]),
+				SourceText:     duration.SourceText,
+				StartOffset:    duration.StartOffset,
+				EndOffset:      duration.EndOffset,
+			})
+		}
+	}
+
+	for _, loc := range datedObligationPattern.FindAllStringIndex(text, -1) {
+		// The effective date is when the contract starts, not something to act on.
+		if effective != nil && loc[0] < effective.EndOffset && loc[1] > effective.StartOffset {
+			continue
+		}
+		start, end := sentenceAround(text, loc[0], loc[1])
+		sentence := text[start:end]
+		if !obligationCuePattern.MatchString(sentence) {
+			continue
+		}
+		date, _, ok := parseTermDate(text[loc[0]:loc[1]])
+		if !ok {
+			continue
+		}
+		obligationType := model.ObligationDeadline
+		if paymentCuePattern.MatchString(sentence) {
+			obligationType = model.ObligationPayment
+		}
+		add(model.Obligation{
+			ObligationType: obligationType,
+			Description:    truncateText(strings.Join(strings.Fields(sentence), " "), 200),
+			DueDate:        date,
+			ActBy:          date.AddDate(0, 0, -obligationLeadDays[obligationType]),
+			SourceText:     sentence,
+			StartOffset:    loc[0],
+			EndOffset:      loc[1],
+		})
+	}
+
+	sort.SliceStable(obligations, func(i, j int) bool { return obligations[i].DueDate.Before(obligations[j].DueDate) })
+	return obligations
+}
+
+// firstTerm returns the first term of termType, or nil
+func firstTerm(terms []model.DocumentTerm, termType string) *model.DocumentTerm {
+	for i := range terms {
+		if terms[i].TermType == termType {
+			return &terms[i]
+		}
+	}
+	return nil
+}
+
+// nearestTerm returns the term of termType closest to offset, or nil
+func nearestTerm(terms []model.DocumentTerm, termType string, offset int) *model.DocumentTerm {
+	var nearest *model.DocumentTerm
+	distance := 0
+	for i := range terms {
+		if terms[i].TermType != termType {
+			continue
+		}
+		d := terms[i].StartOffset - offset
+		if d < 0 {
+			d = -d
+		}
+		if nearest == nil || d < distance {
+			nearest, distance = &terms[i], d
+		}
+	}
+	return nearest
+}
+
+// addPeriod adds n units ("years", "months", "weeks" or "days") to date; n may be negative
+func addPeriod(date time.Time, n float64, unit string) time.Time {
+	count := int(n)
+	switch strings.TrimSuffix(unit, "s") {
+	case "year":
+		return date.AddDate(count, 0, 0)
+	case "month":
+		return date.AddDate(0, count, 0)
+	case "week":
+		return date.AddDate(0, 0, 7*count)
+	default:
+		return date.AddDate(0, 0, count)
+	}
+}
+
+// sentenceAround returns the bounds of the sentence or line containing text[start:end]
+func sentenceAround(text string, start, end int) (int, int) {
+	from := strings.LastIndexAny(text[:start], "\n") + 1
+	if dot := strings.LastIndex(text[:start], ". "); dot+2 > from {
+		from = dot + 2
+	}
+	to := len(text)
+	if newline := strings.IndexByte(text[end:], '\n'); newline >= 0 {
+		to = end + newline
+	}
+	if dot := strings.Index(text[end:], ". "); dot >= 0 && end+dot+1 < to {
+		to = end + dot + 1
+	}
+	return from, to
+}
+
+// truncateText shortens text to at most limit bytes, marking the cut with "..."
+func truncateText(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	end := limit - 3
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return strings.TrimSpace(text[:end]) + "..."
+}
+
+// stageObligations derives dated obligations from the OCR text and key terms; they are saved with the
+// document
+func (s *DocumentService) stageObligations(ctx context.Context, state *pipelineState) error {
+	state.obligations = DeriveObligations(state.ocrText, state.terms, time.Now())
+	log.Printf("Derived %d obligations", len(state.obligations))
+	return nil
+}
+
+// saveObligations stores obligations for docID using tx and creates a reminder action item, due on
+// the obligation's act-by date, for each open one. Obligations already stored are kept unchanged.
+func saveObligations(tx *gorm.DB, docID string, obligations []model.Obligation) error {
+	now := time.Now()
+	for i := range obligations {
+		obligation := &obligations[i]
+		obligation.DocumentID = docID
+		obligation.CreatedAt = now
+		obligation.UpdatedAt = now
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(obligation)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 || obligation.Status != model.ObligationOpen {
+			continue
+		}
+
+		action := model.ActionItem{
+			DocumentID:   docID,
+			ObligationID: &obligation.ID,
+			Description:  fmt.Sprintf("Contract %s: %s", obligation.ObligationType, obligation.Description),
+			Priority:     obligationPriorities[obligation.ObligationType],
+			Status:       "pending",
+			DueDate:      obligation.ActBy,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		if err := tx.Omit("AssignedTo").Create(&action).Error; err != nil {
+			return err
+		}
+		obligation.ActionItemID = &action.ID
+		if err := tx.Model(obligation).Update("action_item_id", action.ID).Error; err != nil {
+			return err
+		}
+		log.Printf("Reminder action item created for %s obligation of document %s, due %s", obligation.ObligationType, docID, action.DueDate.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// GetDocumentObligations lists the obligations of a document by due date. Documents processed before
+// obligation tracking existed have their obligations derived, with reminders, on first request.
+func (s *DocumentService) GetDocumentObligations(docID string) ([]model.Obligation, error) {
+	if !uuidPattern.MatchString(docID) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var doc model.Document
+	if err := s.db.Select("id", "ocr_text").First(&doc, "id = ?", docID).Error; err != nil {
+		log.Printf("[GetDocumentObligations] Error fetching document %s: %v", docID, err)
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&model.Obligation{}).Where("document_id = ?", docID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 && doc.OcrText != "" {
+		terms, err := s.getDocumentTerms(doc)
+		if err != nil {
+			log.Printf("[GetDocumentObligations] Error fetching key terms of %s: %v", docID, err)
+			return nil, err
+		}
+		obligations := DeriveObligations(doc.OcrText, terms, time.Now())
+		if err := s.db.Transaction(func(tx *gorm.DB) error { return saveObligations(tx, docID, obligations) }); err != nil {
+			log.Printf("[GetDocumentObligations] Error saving obligations of %s: %v", docID, err)
+			return nil, err
+		}
+	}
+
+	obligations := []model.Obligation{}
+	if err := s.db.Where("document_id = ?", docID).Order("due_date").Find(&obligations).Error; err != nil {
+		log.Printf("[GetDocumentObligations] Error fetching obligations of %s: %v", docID, err)
+		return nil, err
+	}
+	return obligations, nil
+}
+
+// GetUpcomingObligations lists the open obligations of all documents that have to be acted on within
+// the given number of days, including overdue ones, soonest first
+func (s *DocumentService) GetUpcomingObligations(days int) ([]model.Obligation, error) {
+	obligations := []model.Obligation{}
+	until := time.Now().AddDate(0, 0, days)
+	if err := s.db.Where("status = ? AND act_by <= ?", model.ObligationOpen, until).Order("act_by").Find(&obligations).Error; err != nil {
+		log.Printf("[GetUpcomingObligations] Error fetching obligations: %v", err)
+		return nil, err
+	}
+	return obligations, nil
+}
+
+// completeObligation marks the obligation reminded by a completed action item as completed
+func (s *DocumentService) completeObligation(obligationID string) error {
+	return s.db.Model(&model.Obligation{}).Where("id = ?", obligationID).Updates(map[string]interface{}{
+		"status":     model.ObligationCompleted,
+		"updated_at": time.Now(),
+	}).Error
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestDeriveObligations(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("auto-renewal rolls forward and respects the notice period", func(t *testing.T) {
+		text := "The Effective Date of this Agreement is 15 January 2023.\n" +
+			"The term of this Agreement shall be one (1) year and shall automatically renew for successive one (1) year periods " +
+			"unless either party gives sixty (60) days' written notice.\n"
+		obligations := DeriveObligations(text, ExtractTerms(text), now)
+		require.Len(t, obligations, 1)
+
+		renewal := obligations[0]
+		assert.Equal(t, model.ObligationRenewal, renewal.ObligationType)
+		assert.Equal(t, model.ObligationOpen, renewal.Status)
+		assert.Equal(t, "2026-01-15", renewal.DueDate.Format("2006-01-02"))
+		assert.Equal(t, "2025-11-16", renewal.ActBy.Format("2006-01-02"))
+		assert.Contains(t, renewal.Description, "unless notice of non-renewal is given by 2025-11-16")
+	})
+
+	t.Run("renewal without notice period is flagged 90 days ahead", func(t *testing.T) {
+		text := "Effective Date: 2025-01-01. The term of 12 months shall renew automatically."
+		obligations := DeriveObligations(text, ExtractTerms(text), now)
+		require.Len(t, obligations, 1)
+		assert.Equal(t, "2026-01-01", obligations[0].DueDate.Format("2006-01-02"))
+		assert.Equal(t, "2025-10-03", obligations[0].ActBy.Format("2006-01-02"))
+	})
+
+	t.Run("fixed term expires", func(t *testing.T) {
+		text := "This Agreement is effective as of 1 June 2024. The term of 2 years begins on that date."
+		obligations := DeriveObligations(text, ExtractTerms(text), now)
+		require.Len(t, obligations, 1)
+		assert.Equal(t, model.ObligationExpiry, obligations[0].ObligationType)
+		assert.Equal(t, "2026-06-01", obligations[0].DueDate.Format("2006-01-02"))
+		assert.Equal(t, text[obligations[0].StartOffset:obligations[0].EndOffset], obligations[0].SourceText)
+	})
+
+	t.Run("dated payments and deadlines", func(t *testing.T) {
+		text := "Effective Date: 1 January 2025.\n" +
+			"The Client shall pay the setup fee on or before 31 March 2025. " +
+			"The Supplier must deliver the final report no later than 30 April 2025.\n" +
+			"The first invoice shall be paid by 15 January 2025.\n" +
+			"Signed on 2 January 2025."
+		obligations := DeriveObligations(text, ExtractTerms(text), now)
+		require.Len(t, obligations, 3)
+
+		assert.Equal(t, model.ObligationPayment, obligations[0].ObligationType)
+		assert.Equal(t, model.ObligationPast, obligations[0].Status)
+
+		assert.Equal(t, model.ObligationPayment, obligations[1].ObligationType)
+		assert.Equal(t, "2025-03-31", obligations[1].DueDate.Format("2006-01-02"))
+		assert.Equal(t, obligations[1].DueDate, obligations[1].ActBy)
+		assert.Equal(t, "The Client shall pay the setup fee on or before 31 March 2025.", obligations[1].Description)
+
+		assert.Equal(t, model.ObligationDeadline, obligations[2].ObligationType)
+		assert.Equal(t, model.ObligationOpen, obligations[2].Status)
+	})
+
+	t.Run("no dates", func(t *testing.T) {
+		assert.Empty(t, DeriveObligations("The parties shall cooperate.", nil, now))
+	})
+}
+
+func TestTruncateText(t *testing.T) {
+	assert.Equal(t, "short", truncateText("short", 200))
+
+	// The cut at byte 197 falls inside the three-byte rupee sign starting at byte 196.
+	text := strings.Repeat("a", 196) + "₹5,000 is payable on signing."
+	truncated := truncateText(text, 200)
+	assert.True(t, utf8.ValidString(truncated))
+	assert.LessOrEqual(t, len(truncated), 200)
+	assert.Equal(t, strings.Repeat("a", 196)+"...", truncated)
+}
+
+func TestGetDocumentObligations_NonUUIDIsNotFound(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	s := &DocumentService{db: db}
+
+	_, err := s.GetDocumentObligations("not-a-uuid")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Empty(t, fake.executed(`.`))
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PageSeparator separates the pages of extracted text. Tesseract emits it natively.
+const PageSeparator = "\f"
+
+// OCRProvider extracts text from an uploaded file.
+type OCRProvider interface {
+	// Name identifies the provider in logs and error messages.
+	Name() string
+	// ExtractText returns the text contained in fileBytes. filename is used to detect the file type.
+	ExtractText(ctx context.Context, fileBytes []byte, filename string) (string, error)
+}
+
+// NewOCRProviderFromEnv selects the OCR provider configured by OCR_PROVIDER.
+// Supported values are "ocrspace" (default), "tesseract" and "fake".
+func NewOCRProviderFromEnv() (OCRProvider, error) {
+	provider := strings.ToLower(strings.TrimSpace(os.Getenv("OCR_PROVIDER")))
+	switch provider {
+	case "", "ocrspace", "ocr.space":
+		return NewOCRSpaceProvider(os.Getenv("OCR_SPACE_API_KEY")), nil
+	case "tesseract", "local":
+		command := os.Getenv("OCR_TESSERACT_PATH")
+		if command == "" {
+			command = "tesseract"
+		}
+		return NewLocalExecOCRProvider(command, "{input}", "stdout"), nil
+	case "fake":
+		return NewFakeOCRProvider(os.Getenv("OCR_FAKE_TEXT")), nil
+	default:
+		return nil, fmt.Errorf("unknown OCR_PROVIDER %q", provider)
+	}
+}
+
+// OCRSpaceProvider sends files to the OCR.space parse API.
+type OCRSpaceProvider struct {
+	APIKey   string
+	Endpoint string
+	Client   *http.Client
+}
+
+// NewOCRSpaceProvider creates an OCR.space provider using the public endpoint.
+func NewOCRSpaceProvider(apiKey string) *OCRSpaceProvider {
+	return &OCRSpaceProvider{
+		APIKey:   strings.TrimSpace(apiKey),
+		Endpoint: "https://api.ocr.space/parse/image",
+		Client:   &http.Client{Timeout: 90 * time.Second},
+	}
+}
+
+func (p *OCRSpaceProvider) Name() string { return "ocrspace" }
+
+// ExtractText sends the file to OCR.space and returns the extracted text
+func (p *OCRSpaceProvider) ExtractText(ctx context.Context, fileBytes []byte, filename string) (string, error) {
+	apiKey := p.APIKey
+	if apiKey == "" {
+		return "", fmt.Errorf("OCR.space API key is not set")
+	}
+
+	// Additional validation for API key
+	if len(apiKey) < 10 {
+		return "", fmt.Errorf("invalid OCR.space API key format")
+	}
+
+	log.Printf("Using OCR.space API Key (first 4 chars): %s", apiKey[:4])
+
+	fileType := ocrSpaceFileType(filename)
+
+	// Prepare multipart form
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+
+	// Add form fields
+	if err := w.WriteField("apikey", apiKey); err != nil {
+		return "", fmt.Errorf("failed to write apikey field: %w", err)
+	}
+	if err := w.WriteField("language", "eng"); err != nil {
+		return "", fmt.Errorf("failed to write language field: %w", err)
+	}
+	if err := w.WriteField("isOverlayRequired", "false"); err != nil {
+		return "", fmt.Errorf("failed to write isOverlayRequired field: %w", err)
+	}
+	if err := w.WriteField("filetype", fileType); err != nil {
+		return "", fmt.Errorf("failed to write filetype field: %w", err)
+	}
+
+	// Add file
+	fw, err := w.CreateFormFile("file", filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := fw.Write(fileBytes); err != nil {
+		return "", fmt.Errorf("failed to write file bytes: %w", err)
+	}
+	w.Close()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.Endpoint, &b)
+	if err != nil {
+		return "", fmt.Errorf("failed to create OCR request: %w", err)
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	log.Printf("OCR Request Endpoint: %s", p.Endpoint)
+	log.Printf("OCR File Type: %s", fileType)
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("OCR request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	log.Printf("OCR Response Status: %s", resp.Status)
+	log.Printf("OCR Response Body: %s", string(bodyBytes))
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		// If it's a plain text error message, return it as an error
+		errorMsg := string(bodyBytes)
+		log.Printf("OCR API Error (JSON Unmarshal): %s", errorMsg)
+		return "", fmt.Errorf("OCR API error: %s", errorMsg)
+	}
+
+	// OCR.space reports ErrorMessage either as a string or as a list of strings
+	switch errorMessage := result["ErrorMessage"].(type) {
+	case string:
+		if errorMessage != "" {
+			log.Printf("OCR.space Error Message: %s", errorMessage)
+			return "", fmt.Errorf("OCR.space error: %s", errorMessage)
+		}
+	case []interface{}:
+		if len(errorMessage) > 0 {
+			log.Printf("OCR.space Error Message: %v", errorMessage)
+			return "", fmt.Errorf("OCR.space error: %v", errorMessage[0])
+		}
+	}
+
+	parsedResults, ok := result["ParsedResults"].([]interface{})
+	if !ok || len(parsedResults) == 0 {
+		log.Println("No OCR results found in response")
+		return "", fmt.Errorf("no OCR results found in response")
+	}
+
+	// OCR.space returns one result per page
+	pages := make([]string, 0, len(parsedResults))
+	for _, parsedResult := range parsedResults {
+		pageResult, ok := parsedResult.(map[string]interface{})
+		if !ok {
+			log.Println("Invalid parsed results format")
+			return "", fmt.Errorf("invalid parsed results format")
+		}
+
+		pageText, ok := pageResult["ParsedText"].(string)
+		if !ok {
+			log.Println("Failed to extract ParsedText")
+			return "", fmt.Errorf("failed to extract ParsedText from OCR response")
+		}
+		pages = append(pages, pageText)
+	}
+	parsedText := strings.Join(pages, PageSeparator)
+
+	log.Printf("OCR Text extracted successfully: %d characters", len(parsedText))
+	return parsedText, nil
+}
+
+// ocrSpaceFileType maps a filename extension to the filetype value expected by OCR.space
+func ocrSpaceFileType(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".pdf":
+		return "PDF"
+	case ".png":
+		return "PNG"
+	case ".jpg", ".jpeg":
+		return "JPG"
+	case ".gif":
+		return "GIF"
+	case ".tiff", ".tif":
+		return "TIFF"
+	default:
+		log.Printf("Unknown file type for %s, defaulting to PDF", filename)
+		return "PDF" // Default to PDF if unknown
+	}
+}
+
+// LocalExecOCRProvider runs a local OCR binary (e.g. tesseract) and reads the text from its stdout.
+// The placeholder "{input}" in Args is replaced with the path of a temporary copy of the file.
+type LocalExecOCRProvider struct {
+	Command string
+	Args    []string
+	Timeout time.Duration
+}
+
+// NewLocalExecOCRProvider creates a provider that invokes command with args.
+func NewLocalExecOCRProvider(command string, args ...string) *LocalExecOCRProvider {
+	return &LocalExecOCRProvider{Command: command, Args: args, Timeout: 2 * time.Minute}
+}
+
+func (p *LocalExecOCRProvider) Name() string { return "exec:" + filepath.Base(p.Command) }
+
+// ExtractText writes the file to a temporary location and runs the configured command on it
+func (p *LocalExecOCRProvider) ExtractText(ctx context.Context, fileBytes []byte, filename string) (string, error) {
+	tmp, err := os.CreateTemp("", "ocr-*"+filepath.Ext(filename))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary OCR input: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(fileBytes); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write temporary OCR input: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to close temporary OCR input: %w", err)
+	}
+
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	args := make([]string, len(p.Args))
+	for i, arg := range p.Args {
+		args[i] = strings.ReplaceAll(arg, "{input}", tmp.Name())
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Command, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	log.Printf("Running local OCR: %s %v", p.Command, args)
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("local OCR command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	text := stdout.String()
+	log.Printf("OCR Text extracted successfully: %d characters", len(text))
+	return text, nil
+}
+
+// FakeOCRProvider returns canned text without contacting any service. It is intended for tests
+// and local development.
+type FakeOCRProvider struct {
+	mu sync.Mutex
+	// Text is returned for every file that has no entry in TextByFilename.
+	Text           string
+	TextByFilename map[string]string
+	// Err, when set, is returned instead of any text.
+	Err   error
+	Calls []string
+}
+
+// NewFakeOCRProvider creates a fake provider that returns text for every file.
+func NewFakeOCRProvider(text string) *FakeOCRProvider {
+	return &FakeOCRProvider{Text: text, TextByFilename: make(map[string]string)}
+}
+
+func (p *FakeOCRProvider) Name() string { return "fake" }
+
+// ExtractText records the call and returns the configured text
+func (p *FakeOCRProvider) ExtractText(ctx context.Context, fileBytes []byte, filename string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Calls = append(p.Calls, filename)
+	if p.Err != nil {
+		return "", p.Err
+	}
+	if text, ok := p.TextByFilename[filename]; ok {
+		return text, nil
+	}
+	return p.Text, nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOCRSpaceProvider_ExtractText(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "test-api-key-123", r.FormValue("apikey"))
+		assert.Equal(t, "PNG", r.FormValue("filetype"))
+		w.Write([]byte(`{"ParsedResults":[{"ParsedText":"This Agreement is Confidential"}],"ErrorMessage":""}`))
+	}))
+	defer server.Close()
+
+	provider := NewOCRSpaceProvider("test-api-key-123")
+	provider.Endpoint = server.URL
+
+	text, err := provider.ExtractText(context.Background(), []byte("image"), "scan.png")
+	require.NoError(t, err)
+	assert.Equal(t, "This Agreement is Confidential", text)
+}
+
+func TestOCRSpaceProvider_JoinsPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ParsedResults":[{"ParsedText":"Page one"},{"ParsedText":"Page two"}],"ErrorMessage":""}`))
+	}))
+	defer server.Close()
+
+	provider := NewOCRSpaceProvider("test-api-key-123")
+	provider.Endpoint = server.URL
+
+	text, err := provider.ExtractText(context.Background(), []byte("pdf"), "contract.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "Page one"+PageSeparator+"Page two", text)
+}
+
+func TestOCRSpaceProvider_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ErrorMessage":["File failed validation"]}`))
+	}))
+	defer server.Close()
+
+	provider := NewOCRSpaceProvider("test-api-key-123")
+	provider.Endpoint = server.URL
+	_, err := provider.ExtractText(context.Background(), []byte("pdf"), "contract.pdf")
+	assert.ErrorContains(t, err, "File failed validation")
+
+	_, err = NewOCRSpaceProvider("").ExtractText(context.Background(), []byte("pdf"), "contract.pdf")
+	assert.ErrorContains(t, err, "API key is not set")
+}
+
+func TestLocalExecOCRProvider_ExtractText(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat is not available")
+	}
+
+	provider := NewLocalExecOCRProvider("cat", "{input}")
+	text, err := provider.ExtractText(context.Background(), []byte("Signed and dated"), "contract.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "Signed and dated", text)
+
+	provider = NewLocalExecOCRProvider("definitely-not-an-ocr-binary", "{input}")
+	_, err = provider.ExtractText(context.Background(), []byte("x"), "contract.txt")
+	assert.Error(t, err)
+}
+
+func TestFakeOCRProvider(t *testing.T) {
+	provider := NewFakeOCRProvider("default text")
+	provider.TextByFilename["nda.pdf"] = "nda text"
+
+	text, err := provider.ExtractText(context.Background(), nil, "nda.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "nda text", text)
+
+	text, err = provider.ExtractText(context.Background(), nil, "other.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "default text", text)
+	assert.Equal(t, []string{"nda.pdf", "other.pdf"}, provider.Calls)
+
+	provider.Err = errors.New("ocr down")
+	_, err = provider.ExtractText(context.Background(), nil, "nda.pdf")
+	assert.ErrorContains(t, err, "ocr down")
+}
+
+func TestNewOCRProviderFromEnv(t *testing.T) {
+	t.Setenv("OCR_PROVIDER", "")
+	provider, err := NewOCRProviderFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "ocrspace", provider.Name())
+
+	t.Setenv("OCR_PROVIDER", "tesseract")
+	provider, err = NewOCRProviderFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "exec:tesseract", provider.Name())
+
+	t.Setenv("OCR_PROVIDER", "fake")
+	t.Setenv("OCR_FAKE_TEXT", "hello")
+	provider, err = NewOCRProviderFromEnv()
+	require.NoError(t, err)
+	text, _ := provider.ExtractText(context.Background(), nil, "a.pdf")
+	assert.Equal(t, "hello", text)
+
+	t.Setenv("OCR_PROVIDER", "unknown")
+	_, err = NewOCRProviderFromEnv()
+	assert.Error(t, err)
+}
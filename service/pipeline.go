@@ -0,0 +1,357 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// pipelineState carries the intermediate results of the upload pipeline between stages.
+type pipelineState struct {
+	fileBytes   []byte
+	filename    string
+	contentType string
+	fileID      string
+	contentHash string
+	// jobID links the pipeline run to its processing job; it is empty for synchronous runs.
+	jobID string
+	// sourceDoc is an existing document with identical content whose stored file and OCR text
+	// are reused instead of uploading and running OCR again.
+	sourceDoc *model.Document
+	// parentDocID, when set, records the new document as the next version of that document.
+	parentDocID string
+	// scope is the category, jurisdiction and tags supplied with the upload.
+	scope DocumentScope
+
+	uploaded          bool
+	fileURL           string
+	ocrText           string
+	classification    Classification
+	clauses           []model.DocumentClause
+	terms             []model.DocumentTerm
+	obligations       []model.Obligation
+	rules             []model.ComplianceRule
+	complianceResults []map[string]interface{}
+	parsedDataJSON    []byte
+	riskScore         float64
+	doc               model.Document
+}
+
+// pipelineStage is a single named step of the upload pipeline. compensate, when set, undoes the
+// side effects of a completed stage if a later stage fails.
+type pipelineStage struct {
+	name       string
+	run        func(ctx context.Context, state *pipelineState) error
+	compensate func(ctx context.Context, state *pipelineState)
+}
+
+// errStageSkipped is returned by a stage that had nothing to do. It is not treated as a failure.
+var errStageSkipped = errors.New("stage skipped")
+
+// stageRecorder is notified when a stage starts and finishes. It may be nil.
+type stageRecorder interface {
+	stageStarted(name string)
+	stageFinished(name string, err error)
+}
+
+// pipelineStages returns the upload pipeline in execution order
+func (s *DocumentService) pipelineStages() []pipelineStage {
+	return []pipelineStage{
+		{name: "upload", run: s.stageUpload, compensate: s.compensateUpload},
+		{name: "ocr", run: s.stageOCR},
+		{name: "classify", run: s.stageClassify},
+		{name: "clauses", run: s.stageClauses},
+		{name: "terms", run: s.stageTerms},
+		{name: "obligations", run: s.stageObligations},
+		{name: "compliance", run: s.stageCompliance},
+		{name: "persist", run: s.stagePersist},
+		{name: "index", run: s.stageIndex},
+		{name: "embed", run: s.stageEmbed},
+	}
+}
+
+// pipelineStageNames returns the names of the pipeline stages in execution order
+func (s *DocumentService) pipelineStageNames() []string {
+	stages := s.pipelineStages()
+	names := make([]string, len(stages))
+	for i, stage := range stages {
+		names[i] = stage.name
+	}
+	return names
+}
+
+// runPipeline executes every stage in order. On the first error it compensates the stages that
+// already completed, in reverse order, and returns the error.
+func (s *DocumentService) runPipeline(ctx context.Context, state *pipelineState, recorder stageRecorder) error {
+	var completed []pipelineStage
+	for _, stage := range s.pipelineStages() {
+		if recorder != nil {
+			recorder.stageStarted(stage.name)
+		}
+		start := time.Now()
+		err := stage.run(ctx, state)
+		log.Printf("[Pipeline] Stage %s for %s finished in %v", stage.name, state.fileID, time.Since(start))
+		if recorder != nil {
+			recorder.stageFinished(stage.name, err)
+		}
+		if errors.Is(err, errStageSkipped) {
+			err = nil
+		}
+		if err != nil {
+			for i := len(completed) - 1; i >= 0; i-- {
+				if completed[i].compensate != nil {
+					log.Printf("[Pipeline] Compensating stage %s for %s", completed[i].name, state.fileID)
+					// Compensation must run even if ctx was cancelled by the failure.
+					completed[i].compensate(context.Background(), state)
+				}
+			}
+			return err
+		}
+		completed = append(completed, stage)
+	}
+	return nil
+}
+
+// newFileID builds the storage key for an uploaded file
+func newFileID(filename string) string {
+	return fmt.Sprintf("%d-%s", time.Now().Unix(), filename)
+}
+
+// contentHash returns the hex-encoded SHA-256 digest of the file contents
+func contentHash(fileBytes []byte) string {
+	sum := sha256.Sum256(fileBytes)
+	return hex.EncodeToString(sum[:])
+}
+
+// stageUpload stores the original file in Supabase S3
+func (s *DocumentService) stageUpload(ctx context.Context, state *pipelineState) error {
+	if state.sourceDoc != nil {
+		state.fileURL = state.sourceDoc.OriginalURL
+		log.Printf("Reusing stored file of document %s: %s", state.sourceDoc.ID, state.fileURL)
+		return errStageSkipped
+	}
+
+	bucket := os.Getenv("SUPABASE_BUCKET")
+	if bucket == "" {
+		log.Println("SUPABASE_BUCKET environment variable is not set")
+		return fmt.Errorf("bucket name not configured")
+	}
+
+	uploadInput := &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(state.fileID),
+		Body:        bytes.NewReader(state.fileBytes),
+		ACL:         aws.String("public-read"),
+		ContentType: aws.String(state.contentType),
+	}
+
+	if _, err := s.s3Client.PutObjectWithContext(ctx, uploadInput); err != nil {
+		log.Printf("S3 upload error: %v", err)
+		return fmt.Errorf("failed to upload file to S3: %w", err)
+	}
+
+	state.uploaded = true
+	state.fileURL = fmt.Sprintf("%s/object/public/%s/%s", os.Getenv("SUPABASE_S3_URL"), bucket, state.fileID)
+	log.Printf("File stored at: %s", state.fileURL)
+	return nil
+}
+
+// stageOCR extracts the document text with the configured OCR provider
+func (s *DocumentService) stageOCR(ctx context.Context, state *pipelineState) error {
+	if state.sourceDoc != nil {
+		state.ocrText = state.sourceDoc.OcrText
+		log.Printf("Reusing OCR text of document %s", state.sourceDoc.ID)
+		return errStageSkipped
+	}
+
+	if s.ocr == nil {
+		log.Println("No OCR provider configured")
+		return fmt.Errorf("OCR provider not configured")
+	}
+
+	ocrText, err := s.ocr.ExtractText(ctx, state.fileBytes, state.filename)
+	if err != nil {
+		log.Printf("ERROR in OCR processing: %v", err)
+		return fmt.Errorf("failed to process OCR with %s: %w", s.ocr.Name(), err)
+	}
+	log.Printf("OCR Text extracted: %d characters", len(ocrText))
+	state.ocrText = ocrText
+	return nil
+}
+
+// stageClassify assigns the document a category unless one was supplied with the upload. A
+// classified category only selects rules when the classifier is confident enough.
+func (s *DocumentService) stageClassify(ctx context.Context, state *pipelineState) error {
+	if category := strings.ToLower(strings.TrimSpace(state.scope.Category)); category != "" {
+		state.classification = Classification{Category: category, Confidence: 1, Source: CategorySourceUpload}
+		log.Printf("Using category %s supplied with the upload", category)
+		return errStageSkipped
+	}
+
+	state.classification = s.classifyDocument(ctx, state.ocrText)
+	if state.classification.Confidence >= s.classifierMinConfidence() {
+		state.scope.Category = state.classification.Category
+	}
+	return nil
+}
+
+// stageIndex adds the stored document's text and metadata to the search index. It runs after the
+// document is committed, so a failure is logged rather than undoing the upload; a reindex repairs it.
+func (s *DocumentService) stageIndex(ctx context.Context, state *pipelineState) error {
+	if err := s.indexDocument(ctx, state.doc); err != nil {
+		log.Printf("Search indexing error for document %s: %v", state.doc.ID, err)
+		return nil
+	}
+	log.Printf("Document indexed successfully with ID: %s", state.doc.ID)
+	return nil
+}
+
+// stageCompliance evaluates every rule in the document's scope against the OCR text and calculates
+// the risk score. Rules outside the scope are recorded as skipped.
+func (s *DocumentService) stageCompliance(ctx context.Context, state *pipelineState) error {
+	// Fetch all rules to build complete parsed_data
+	allRules, err := s.GetAllComplianceRules()
+	if err != nil {
+		log.Printf("ERROR fetching all rules from database: %v", err)
+		return fmt.Errorf("failed to fetch rules from database: %w", err)
+	}
+	log.Printf("Fetched %d rules from database", len(allRules))
+
+	state.scope.FileType = fileTypeOf(state.filename)
+	state.scope.normalize()
+	complianceResults, err := s.evaluateScopedCompliance(ctx, state.ocrText, allRules, state.scope, withLLMFallback)
+	if err != nil {
+		return err
+	}
+
+	state.rules = allRules
+	state.complianceResults = complianceResults
+	state.riskScore = s.CalculateRiskScore(complianceResults, allRules)
+	log.Printf("Calculated Risk Score: %f", state.riskScore)
+
+	parsedDataJSON, err := json.Marshal(complianceResults)
+	if err != nil {
+		log.Printf("ERROR marshaling compliance results: %v", err)
+		return fmt.Errorf("failed to marshal compliance results: %w", err)
+	}
+	state.parsedDataJSON = parsedDataJSON
+	log.Printf("Compliance Results JSON: %s", string(parsedDataJSON))
+	return nil
+}
+
+// stagePersist saves the document, its action items, rule results, clauses, key terms, obligations and
+// version link in a single transaction.
+// For job runs the job's document_id is set in the same transaction, which makes a retried job
+// detect that persistence already happened.
+func (s *DocumentService) stagePersist(ctx context.Context, state *pipelineState) error {
+	fileName := filepath.Base(state.fileURL)
+	fileType := filepath.Ext(fileName)
+	if fileType != "" {
+		fileType = fileType[1:] // Remove the leading dot
+	}
+	title := strings.TrimSuffix(fileName, fileType)
+
+	state.doc = model.Document{
+		Title:              title,
+		FileType:           fileType,
+		Category:           state.classification.Category,
+		CategoryConfidence: state.classification.Confidence,
+		CategorySource:     state.classification.Source,
+		Jurisdiction:       state.scope.Jurisdiction,
+		Tags:               state.scope.Tags,
+		OriginalURL:        state.fileURL,
+		ContentHash:        state.contentHash,
+		OcrText:            state.ocrText,
+		ParsedData:         datatypes.JSON(state.parsedDataJSON),
+		RiskScore:          state.riskScore,
+		CreatedAt:          time.Now(),
+		UpdatedAt:          time.Now(),
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&state.doc).Error; err != nil {
+			log.Printf("ERROR saving document to database: %v", err)
+			return fmt.Errorf("failed to save to database: %w", err)
+		}
+		log.Printf("Document saved to database with ID: %s", state.doc.ID)
+
+		if err := s.createActionItems(tx, state.doc); err != nil {
+			log.Printf("Error creating action items: %v", err)
+			return fmt.Errorf("failed to create action items: %w", err)
+		}
+
+		if err := saveClauses(tx, state.doc.ID, state.clauses); err != nil {
+			return fmt.Errorf("failed to save clauses: %w", err)
+		}
+		if err := saveTerms(tx, state.doc.ID, state.terms); err != nil {
+			return fmt.Errorf("failed to save key terms: %w", err)
+		}
+		if err := saveObligations(tx, state.doc.ID, state.obligations); err != nil {
+			return fmt.Errorf("failed to save obligations: %w", err)
+		}
+
+		if state.parentDocID != "" {
+			if err := s.linkVersion(tx, state.parentDocID, state.doc.ID); err != nil {
+				return err
+			}
+		}
+
+		if state.jobID != "" {
+			if err := tx.Model(&model.ProcessingJob{}).Where("id = ?", state.jobID).
+				Update("document_id", state.doc.ID).Error; err != nil {
+				return fmt.Errorf("failed to link document to job: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		state.doc = model.Document{}
+		return err
+	}
+	log.Printf("Document %s and action items committed", state.doc.ID)
+	return nil
+}
+
+// compensateUpload removes the uploaded object from S3. Reused objects belong to another document
+// and are left alone.
+func (s *DocumentService) compensateUpload(ctx context.Context, state *pipelineState) {
+	if !state.uploaded {
+		return
+	}
+	if err := s.deleteStoredFile(ctx, state.fileID); err != nil {
+		log.Printf("[Pipeline] Failed to delete S3 object %s: %v", state.fileID, err)
+	}
+}
+
+// deleteStoredFile deletes an object from the configured bucket
+func (s *DocumentService) deleteStoredFile(ctx context.Context, fileID string) error {
+	bucket := os.Getenv("SUPABASE_BUCKET")
+	if bucket == "" || fileID == "" {
+		return nil
+	}
+	_, err := s.s3Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(fileID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete file from S3: %w", err)
+	}
+	log.Printf("Deleted S3 object %s", fileID)
+	return nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/Itish41/LegalEagle/models"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 records uploaded objects in memory.
+type fakeS3 struct {
+	s3iface.S3API
+	objects map[string][]byte
+	deleted []string
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: make(map[string][]byte)}
+}
+
+func (f *fakeS3) PutObjectWithContext(ctx aws.Context, input *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[aws.StringValue(input.Key)] = body
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObjectWithContext(ctx aws.Context, input *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	f.deleted = append(f.deleted, aws.StringValue(input.Key))
+	delete(f.objects, aws.StringValue(input.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// recordingTracker collects stage events emitted by runPipeline.
+type recordingTracker struct {
+	events []string
+}
+
+func (r *recordingTracker) stageStarted(name string) {
+	r.events = append(r.events, "start:"+name)
+}
+
+func (r *recordingTracker) stageFinished(name string, err error) {
+	if err != nil {
+		r.events = append(r.events, "fail:"+name)
+		return
+	}
+	r.events = append(r.events, "done:"+name)
+}
+
+func TestRunPipeline_StopsAtFailingStageAndCompensates(t *testing.T) {
+	t.Setenv("SUPABASE_BUCKET", "documents")
+	t.Setenv("SUPABASE_S3_URL", "https://storage.example.com")
+
+	storage := newFakeS3()
+	ocr := NewFakeOCRProvider("")
+	ocr.Err = errors.New("ocr unavailable")
+	s := &DocumentService{s3Client: storage, ocr: ocr}
+
+	state := &pipelineState{fileBytes: []byte("%PDF"), filename: "nda.pdf", fileID: "1-nda.pdf"}
+	recorder := &recordingTracker{}
+	err := s.runPipeline(context.Background(), state, recorder)
+
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "ocr unavailable")
+	assert.Equal(t, []string{"start:upload", "done:upload", "start:ocr", "fail:ocr"}, recorder.events)
+	assert.Equal(t, "https://storage.example.com/object/public/documents/1-nda.pdf", state.fileURL)
+
+	// The uploaded object is removed because a later stage failed.
+	assert.Equal(t, []string{"1-nda.pdf"}, storage.deleted)
+	assert.Empty(t, storage.objects)
+}
+
+func TestPipelineStageNames(t *testing.T) {
+	s := &DocumentService{}
+	names := s.pipelineStageNames()
+	require.NotEmpty(t, names)
+	assert.Equal(t, "upload", names[0])
+	assert.Contains(t, names, "ocr")
+	assert.Contains(t, names, "persist")
+}
+
+func TestContentHash(t *testing.T) {
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", contentHash(nil))
+	assert.Equal(t, contentHash([]byte("contract")), contentHash([]byte("contract")))
+	assert.NotEqual(t, contentHash([]byte("contract v1")), contentHash([]byte("contract v2")))
+}
+
+func TestPipeline_ReusesSourceDocument(t *testing.T) {
+	t.Setenv("SUPABASE_BUCKET", "documents")
+
+	storage := newFakeS3()
+	ocr := NewFakeOCRProvider("fresh OCR text")
+	s := &DocumentService{s3Client: storage, ocr: ocr}
+
+	source := &models.Document{ID: "doc1", OriginalURL: "https://storage.example.com/nda.pdf", OcrText: "stored OCR text"}
+	state := &pipelineState{fileBytes: []byte("%PDF"), filename: "nda.pdf", fileID: "2-nda.pdf", sourceDoc: source}
+
+	assert.ErrorIs(t, s.stageUpload(context.Background(), state), errStageSkipped)
+	assert.ErrorIs(t, s.stageOCR(context.Background(), state), errStageSkipped)
+	assert.Equal(t, source.OriginalURL, state.fileURL)
+	assert.Equal(t, "stored OCR text", state.ocrText)
+	assert.Empty(t, storage.objects)
+	assert.Empty(t, ocr.Calls)
+
+	// The reused object belongs to the source document and must survive compensation.
+	s.compensateUpload(context.Background(), state)
+	assert.Empty(t, storage.deleted)
+}
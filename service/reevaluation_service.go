@@ -0,0 +1,377 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidReevaluation is returned when a re-evaluation request has invalid filters.
+var ErrInvalidReevaluation = errors.New("invalid re-evaluation request")
+
+// maxProgressErrors caps how many per-document errors a job records.
+const maxProgressErrors = 20
+
+// reevaluationStageNames are the stages reported for re-evaluation jobs.
+var reevaluationStageNames = []string{"select", "evaluate"}
+
+// ReevaluateParams selects the documents and rules of a re-evaluation job. Empty filters match
+// everything. When RuleIDs is set only those rules are re-evaluated and the results of other rules
+// are kept; otherwise every active rule is evaluated and results of deleted rules are dropped.
+type ReevaluateParams struct {
+	RuleIDs     []string   `json:"rule_ids,omitempty"`
+	DocumentIDs []string   `json:"document_ids,omitempty"`
+	From        *time.Time `json:"from,omitempty"`
+	To          *time.Time `json:"to,omitempty"`
+}
+
+// SubmitReevaluation validates params and queues a job that reruns compliance for stored documents
+func (s *DocumentService) SubmitReevaluation(params ReevaluateParams) (*model.ProcessingJob, error) {
+	if params.From != nil && params.To != nil && params.From.After(*params.To) {
+		return nil, fmt.Errorf("%w: from must not be after to", ErrInvalidReevaluation)
+	}
+	for _, id := range params.DocumentIDs {
+		if !uuidPattern.MatchString(id) {
+			return nil, fmt.Errorf("%w: document id %q is not a UUID", ErrInvalidReevaluation, id)
+		}
+	}
+	for _, id := range params.RuleIDs {
+		if !uuidPattern.MatchString(id) {
+			return nil, fmt.Errorf("%w: rule id %q is not a UUID", ErrInvalidReevaluation, id)
+		}
+	}
+	if len(params.RuleIDs) > 0 {
+		rules, err := s.activeRulesByID(params.RuleIDs)
+		if err != nil {
+			return nil, err
+		}
+		if len(rules) != len(removeDuplicates(params.RuleIDs)) {
+			return nil, fmt.Errorf("%w: unknown or deleted rule in rule_ids", ErrInvalidReevaluation)
+		}
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job params: %w", err)
+	}
+	stages := make([]model.JobStage, len(reevaluationStageNames))
+	for i, name := range reevaluationStageNames {
+		stages[i] = model.JobStage{Name: name, Status: model.JobStatusPending}
+	}
+	stagesJSON, err := json.Marshal(stages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job stages: %w", err)
+	}
+
+	job := model.ProcessingJob{
+		Type:      model.JobTypeReevaluate,
+		Status:    model.JobStatusQueued,
+		Stages:    datatypes.JSON(stagesJSON),
+		Params:    datatypes.JSON(paramsJSON),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.db.Create(&job).Error; err != nil {
+		log.Printf("[SubmitReevaluation] Error creating job: %v", err)
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+	log.Printf("[SubmitReevaluation] Queued re-evaluation job %s with params %s", job.ID, string(paramsJSON))
+	s.enqueueJob(job.ID)
+	return &job, nil
+}
+
+// activeRulesByID loads the non-deleted rules among ids
+func (s *DocumentService) activeRulesByID(ids []string) ([]model.ComplianceRule, error) {
+	var rules []model.ComplianceRule
+	if err := s.db.Where("id IN ?", ids).Find(&rules).Error; err != nil {
+		log.Printf("[activeRulesByID] Error fetching rules: %v", err)
+		return nil, err
+	}
+	return rules, nil
+}
+
+// runReevaluationJob reruns compliance for every document selected by the job's params. Re-evaluating
+// a document twice gives the same outcome, so an interrupted job simply starts over.
+func (s *DocumentService) runReevaluationJob(ctx context.Context, job *model.ProcessingJob) error {
+	tracker := newJobTracker(s, job, reevaluationStageNames)
+
+	var params ReevaluateParams
+	if len(job.Params) > 0 {
+		if err := json.Unmarshal(job.Params, &params); err != nil {
+			tracker.fail(err)
+			return fmt.Errorf("failed to parse job params: %w", err)
+		}
+	}
+
+	tracker.stageStarted("select")
+	docIDs, rules, allRules, err := s.selectReevaluation(params)
+	tracker.stageFinished("select", err)
+	if err != nil {
+		tracker.fail(err)
+		return err
+	}
+
+	progress := model.JobProgress{Total: len(docIDs)}
+	tracker.saveProgress(progress)
+
+	tracker.stageStarted("evaluate")
+	for _, docID := range docIDs {
+		if ctx.Err() != nil {
+			// The job stays running until its lease expires and a worker claims it again.
+			return ctx.Err()
+		}
+
+		changed, err := s.reevaluateDocument(ctx, docID, rules, allRules, len(params.RuleIDs) > 0)
+		progress.Processed++
+		switch {
+		case err != nil:
+			log.Printf("[runReevaluationJob] Error re-evaluating document %s: %v", docID, err)
+			progress.Failed++
+			if len(progress.Errors) < maxProgressErrors {
+				if progress.Errors == nil {
+					progress.Errors = make(map[string]string)
+				}
+				progress.Errors[docID] = err.Error()
+			}
+		case changed:
+			progress.Changed++
+		}
+		tracker.saveProgress(progress)
+	}
+	tracker.stageFinished("evaluate", nil)
+
+	tracker.completeWith(progress, "")
+	log.Printf("[runReevaluationJob] Job %s re-evaluated %d documents (%d changed, %d failed)", job.ID, progress.Processed, progress.Changed, progress.Failed)
+	return nil
+}
+
+// selectReevaluation resolves params into the documents to process, the rules to evaluate and all
+// active rules (used for risk scoring)
+func (s *DocumentService) selectReevaluation(params ReevaluateParams) ([]string, []model.ComplianceRule, []model.ComplianceRule, error) {
+	var allRules []model.ComplianceRule
+	if err := s.db.Find(&allRules).Error; err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to fetch rules: %w", err)
+	}
+
+	rules := allRules
+	if len(params.RuleIDs) > 0 {
+		wanted := make(map[string]bool, len(params.RuleIDs))
+		for _, id := range params.RuleIDs {
+			wanted[id] = true
+		}
+		rules = nil
+		for _, rule := range allRules {
+			if wanted[rule.ID] {
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	query := s.db.Model(&model.Document{}).Where("ocr_text IS NOT NULL AND ocr_text <> ''")
+	if len(params.DocumentIDs) > 0 {
+		query = query.Where("id IN ?", params.DocumentIDs)
+	}
+	if params.From != nil {
+		query = query.Where("created_at >= ?", *params.From)
+	}
+	if params.To != nil {
+		query = query.Where("created_at <= ?", *params.To)
+	}
+	var docIDs []string
+	if err := query.Order("created_at").Pluck("id", &docIDs).Error; err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to select documents: %w", err)
+	}
+	return docIDs, rules, allRules, nil
+}
+
+// reevaluateDocument reruns rules against a stored document's OCR text and updates its parsed_data,
+// risk score, rule results and action items in one transaction. It reports whether anything changed.
+func (s *DocumentService) reevaluateDocument(ctx context.Context, docID string, rules, allRules []model.ComplianceRule, partial bool) (bool, error) {
+	var doc model.Document
+	if err := s.db.Select("id", "ocr_text", "parsed_data", "risk_score", "file_type", "category", "category_confidence", "category_source", "jurisdiction", "tags").First(&doc, "id = ?", docID).Error; err != nil {
+		return false, err
+	}
+
+	// Keyword guesses would overwrite real findings, so a document the LLM cannot judge fails instead.
+	results, err := s.evaluateScopedCompliance(ctx, doc.OcrText, rules, s.documentScope(doc), withoutLLMFallback)
+	if err != nil {
+		return false, err
+	}
+
+	merged := results
+	if partial {
+		merged = mergeRuleResults(doc.ParsedData, results)
+	}
+	riskScore := calculateRiskScore(merged, allRules)
+	parsedData, err := json.Marshal(merged)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal compliance results: %w", err)
+	}
+
+	failing, passing, removed := compareRuleResults(doc.ParsedData, parsedData)
+	changed := len(failing) > 0 || len(passing) > 0 || len(removed) > 0 || riskScore != doc.RiskScore
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Document{}).Where("id = ?", doc.ID).Updates(map[string]interface{}{
+			"ParsedData": datatypes.JSON(parsedData),
+			"RiskScore":  riskScore,
+			"UpdatedAt":  time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update document: %w", err)
+		}
+		// Rules dropped from parsed_data were deleted; their open findings are settled like a skip.
+		outcomes := append(append([]map[string]interface{}{}, results...), deletedRuleResults(removed)...)
+		return syncRuleOutcomes(tx, doc.ID, outcomes)
+	})
+	if err != nil {
+		return false, err
+	}
+	if changed {
+		if err := s.db.First(&doc, "id = ?", doc.ID).Error; err == nil {
+			s.updateIndexedDocument(ctx, doc)
+		}
+	}
+	return changed, nil
+}
+
+// deletedRuleResults returns a "skipped" result for each rule that was removed from parsed_data
+func deletedRuleResults(removed []RuleStatusChange) []map[string]interface{} {
+	results := make([]map[string]interface{}, 0, len(removed))
+	for _, change := range removed {
+		if change.RuleID == "" {
+			continue
+		}
+		results = append(results, map[string]interface{}{
+			"rule_id":      change.RuleID,
+			"rule_name":    change.RuleName,
+			"severity":     change.Severity,
+			"status":       "skipped",
+			"explanation":  fmt.Sprintf("The '%s' rule was not evaluated: it has been deleted.", change.RuleName),
+			"evaluated_by": "reevaluation",
+		})
+	}
+	return results
+}
+
+// mergeRuleResults replaces the entries of existing parsed_data whose rule was re-evaluated and
+// appends results for rules the document had not been judged against
+func mergeRuleResults(existing []byte, results []map[string]interface{}) []map[string]interface{} {
+	var entries []map[string]interface{}
+	if len(existing) > 0 {
+		if err := json.Unmarshal(existing, &entries); err != nil {
+			log.Printf("[mergeRuleResults] Error unmarshaling parsed_data: %v", err)
+			entries = nil
+		}
+	}
+
+	replaced := make(map[int]bool, len(results))
+	for _, result := range results {
+		ruleID, _ := result["rule_id"].(string)
+		ruleName, _ := result["rule_name"].(string)
+		found := false
+		for i, entry := range entries {
+			entryID, _ := entry["rule_id"].(string)
+			entryName, _ := entry["rule_name"].(string)
+			// Entries written before rule IDs were recorded are matched by name.
+			if (entryID != "" && entryID == ruleID) || (entryID == "" && entryName == ruleName) {
+				if !replaced[i] {
+					entries[i] = result
+					replaced[i] = true
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			entries = append(entries, result)
+		}
+	}
+	return entries
+}
+
+// syncRuleOutcomes brings rule results and action items in line with re-evaluated results: failing
+// rules get a failed result and a pending action item, passing and skipped rules have their pending
+// action items completed
+func syncRuleOutcomes(tx *gorm.DB, docID string, results []map[string]interface{}) error {
+	for _, result := range results {
+		ruleID, _ := result["rule_id"].(string)
+		ruleName, _ := result["rule_name"].(string)
+		status, _ := result["status"].(string)
+		if ruleID == "" || (status != "fail" && status != "pass" && status != "skipped") {
+			continue
+		}
+
+		var existing model.DocumentRuleResult
+		err := tx.Where("document_id = ? AND rule_id = ?", docID, ruleID).Order("created_at DESC").First(&existing).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load rule result: %w", err)
+		}
+		hasResult := err == nil
+
+		// A rule that no longer applies to the document settles its findings like a pass.
+		if status == "pass" || status == "skipped" {
+			if hasResult && existing.Status == "fail" {
+				updates := map[string]interface{}{
+					"Status":  status,
+					"Details": datatypes.JSON(marshalResult(result)),
+				}
+				if revisionID := resultRevisionID(result); revisionID != nil {
+					updates["RuleRevisionID"] = revisionID
+				}
+				if err := tx.Model(&existing).Updates(updates).Error; err != nil {
+					return fmt.Errorf("failed to update rule result: %w", err)
+				}
+			}
+			if err := tx.Model(&model.ActionItem{}).
+				Where("document_id = ? AND rule_id = ? AND status = ?", docID, ruleID, "pending").
+				Updates(map[string]interface{}{"Status": "completed", "UpdatedAt": time.Now()}).Error; err != nil {
+				return fmt.Errorf("failed to complete action items: %w", err)
+			}
+			continue
+		}
+
+		if hasResult {
+			if err := tx.Model(&existing).Updates(map[string]interface{}{
+				"Status":         "fail",
+				"Details":        datatypes.JSON(marshalResult(result)),
+				"RuleRevisionID": resultRevisionID(result),
+			}).Error; err != nil {
+				return fmt.Errorf("failed to update rule result: %w", err)
+			}
+		} else {
+			docResult := model.DocumentRuleResult{
+				DocumentID:     docID,
+				RuleID:         ruleID,
+				Status:         "fail",
+				RuleRevisionID: resultRevisionID(result),
+				Details:        datatypes.JSON(marshalResult(result)),
+				CreatedAt:      time.Now(),
+			}
+			if err := tx.Create(&docResult).Error; err != nil {
+				return fmt.Errorf("failed to create rule result: %w", err)
+			}
+		}
+
+		var pending int64
+		if err := tx.Model(&model.ActionItem{}).
+			Where("document_id = ? AND rule_id = ? AND status = ?", docID, ruleID, "pending").
+			Count(&pending).Error; err != nil {
+			return fmt.Errorf("failed to count action items: %w", err)
+		}
+		if pending == 0 {
+			action := newActionItem(docID, ruleID, ruleName, result)
+			if err := tx.Omit("AssignedTo").Create(&action).Error; err != nil {
+				return fmt.Errorf("failed to create action item: %w", err)
+			}
+			log.Printf("Action item created: %s for document %s", action.Description, docID)
+		}
+	}
+	return nil
+}
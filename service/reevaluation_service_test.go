@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func TestMergeRuleResults(t *testing.T) {
+	existing := []byte(`[
+		{"rule_id":"r1","rule_name":"Signature","status":"fail"},
+		{"rule_name":"Governing Law","status":"pass"},
+		{"rule_id":"r3","rule_name":"Payment Terms","status":"pass"}
+	]`)
+	results := []map[string]interface{}{
+		{"rule_id": "r1", "rule_name": "Signature", "status": "pass"},
+		{"rule_id": "r2", "rule_name": "Governing Law", "status": "fail"},
+		{"rule_id": "r4", "rule_name": "Arbitration", "status": "fail"},
+	}
+
+	merged := mergeRuleResults(existing, results)
+	require.Len(t, merged, 4)
+	assert.Equal(t, "pass", merged[0]["status"])
+	// Legacy entries without a rule ID are matched by name.
+	assert.Equal(t, "r2", merged[1]["rule_id"])
+	assert.Equal(t, "fail", merged[1]["status"])
+	// Rules that were not re-evaluated keep their result.
+	assert.Equal(t, "Payment Terms", merged[2]["rule_name"])
+	assert.Equal(t, "Arbitration", merged[3]["rule_name"])
+}
+
+func TestMergeRuleResults_EmptyParsedData(t *testing.T) {
+	results := []map[string]interface{}{{"rule_id": "r1", "status": "fail"}}
+	assert.Equal(t, results, mergeRuleResults(nil, results))
+}
+
+var fakeRuleResultColumns = []string{"id", "document_id", "rule_id", "status"}
+
+// onRuleResult answers lookups of the latest result of a rule with status
+func onRuleResult(fake *fakeDB, status string) {
+	fake.on(`FROM "document_rule_results" WHERE document_id = \$1 AND rule_id = \$2`, fakeRuleResultColumns,
+		[]driver.Value{"result-1", "doc-1", "r1", status})
+}
+
+// onPendingActionItems answers counts of pending action items
+func onPendingActionItems(fake *fakeDB, count int64) {
+	fake.on(`SELECT count\(\*\) FROM "action_items"`, []string{"count"}, []driver.Value{count})
+}
+
+func signatureResult(status string) []map[string]interface{} {
+	return []map[string]interface{}{{"rule_id": "r1", "rule_name": "Signature", "severity": "high", "status": status}}
+}
+
+func TestSyncRuleOutcomes_NewFailureCreatesActionItem(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	onPendingActionItems(fake, 0)
+
+	require.NoError(t, syncRuleOutcomes(db, "doc-1", signatureResult("fail")))
+
+	results := fake.executed(`^INSERT INTO "document_rule_results"`)
+	require.Len(t, results, 1)
+	assert.Contains(t, results[0].Args, "fail")
+	items := fake.executed(`^INSERT INTO "action_items"`)
+	require.Len(t, items, 1)
+	assert.Contains(t, items[0].Args, "pending")
+	assert.Contains(t, items[0].Args, "High")
+}
+
+func TestSyncRuleOutcomes_ExistingPendingItemIsKept(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	onRuleResult(fake, "fail")
+	onPendingActionItems(fake, 1)
+
+	require.NoError(t, syncRuleOutcomes(db, "doc-1", signatureResult("fail")))
+
+	assert.Empty(t, fake.executed(`^INSERT`), "a failing rule keeps a single pending action item")
+	assert.Len(t, fake.executed(`^UPDATE "document_rule_results"`), 1)
+}
+
+func TestSyncRuleOutcomes_PassOrSkipCompletesActionItems(t *testing.T) {
+	for _, status := range []string{"pass", "skipped"} {
+		t.Run(status, func(t *testing.T) {
+			db, fake := newFakeGormDB(t)
+			onRuleResult(fake, "fail")
+
+			require.NoError(t, syncRuleOutcomes(db, "doc-1", signatureResult(status)))
+
+			results := fake.executed(`^UPDATE "document_rule_results"`)
+			require.Len(t, results, 1)
+			assert.Contains(t, results[0].Args, status)
+			items := fake.executed(`^UPDATE "action_items"`)
+			require.Len(t, items, 1)
+			assert.Contains(t, items[0].SQL, `"status"=$1`)
+			assert.Equal(t, "completed", items[0].Args[0], "action items are only ever pending or completed")
+			assert.Contains(t, items[0].Args, "pending")
+			assert.Empty(t, fake.executed(`^INSERT`))
+		})
+	}
+}
+
+func TestRunReevaluationJob_Progress(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	fake.on(`FROM "compliance_rules"`, []string{"id", "name", "pattern", "severity", "requirement"},
+		[]driver.Value{"r1", "Signature", "signed by", "high", "required"})
+	fake.on(`^SELECT "id" FROM "documents"`, []string{"id"},
+		[]driver.Value{"doc-1"}, []driver.Value{"doc-2"}, []driver.Value{"doc-3"})
+
+	rules := []model.ComplianceRule{{ID: "r1", Name: "Signature", Pattern: "signed by", Severity: "high"}}
+	failing := `[{"rule_id":"r1","rule_name":"Signature","severity":"high","status":"fail"}]`
+	failingScore := calculateRiskScore(signatureResult("fail"), rules)
+	columns := []string{"id", "ocr_text", "parsed_data", "risk_score"}
+	// doc-1 now passes, doc-2 has been deleted since it was selected and doc-3 still fails.
+	fake.on(`^SELECT "id","ocr_text"`, columns, []driver.Value{"doc-1", "Signed by both parties.", []byte(failing), failingScore}).onlyOnce()
+	fake.on(`^SELECT "id","ocr_text"`, columns).onlyOnce()
+	fake.on(`^SELECT "id","ocr_text"`, columns, []driver.Value{"doc-3", "Unsigned draft.", []byte(failing), failingScore})
+	onRuleResult(fake, "fail")
+	onPendingActionItems(fake, 1)
+
+	s := &DocumentService{db: db, evaluationMode: EvaluationModeLocal}
+	job := &model.ProcessingJob{ID: "job-1", Type: model.JobTypeReevaluate, Params: datatypes.JSON(`{}`)}
+	require.NoError(t, s.runReevaluationJob(context.Background(), job))
+
+	updates := fake.executed(`^UPDATE "processing_jobs"`)
+	require.NotEmpty(t, updates)
+	var progress model.JobProgress
+	// The completed job stores the final progress as its result.
+	for _, arg := range updates[len(updates)-1].Args {
+		if data, ok := arg.(string); ok && strings.HasPrefix(data, `{"total"`) {
+			require.NoError(t, json.Unmarshal([]byte(data), &progress))
+		}
+	}
+	assert.Equal(t, 3, progress.Total)
+	assert.Equal(t, 3, progress.Processed)
+	assert.Equal(t, 1, progress.Changed)
+	assert.Equal(t, 1, progress.Failed)
+	assert.Contains(t, progress.Errors, "doc-2")
+}
+
+func TestReevaluateDocument_LLMFailureIsNotPersisted(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	fake.on(`^SELECT "id","ocr_text"`, []string{"id", "ocr_text", "parsed_data", "risk_score"},
+		[]driver.Value{"doc-1", "This confidential agreement must be signed.", []byte(`[]`), 0.0})
+	stub := NewStubLLMClient(nil)
+	stub.Err = errors.New("upstream unavailable")
+	s := &DocumentService{db: db, llm: stub, evaluationMode: EvaluationModeLLM}
+	rules := []model.ComplianceRule{{ID: "r1", Name: "Confidentiality Marking", Severity: "high"}}
+
+	changed, err := s.reevaluateDocument(context.Background(), "doc-1", rules, rules, false)
+	assert.ErrorContains(t, err, "upstream unavailable")
+	assert.False(t, changed)
+	assert.Empty(t, fake.executed(`^(UPDATE|INSERT)`), "keyword guesses must not replace stored results")
+
+	// Uploads still get the keyword fallback for a first look.
+	violated, err := s.determineViolatedRules(context.Background(), "This confidential agreement must be signed.", rules, withLLMFallback)
+	require.NoError(t, err)
+	assert.Contains(t, violated, "Confidentiality Marking")
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	limiter := NewRateLimiter(1, 50*time.Millisecond)
+	require.True(t, limiter.Allow("llm"))
+
+	started := time.Now()
+	require.NoError(t, limiter.Wait(context.Background(), "llm"))
+	assert.GreaterOrEqual(t, time.Since(started), 40*time.Millisecond, "the next request waits for the window to reset")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, limiter.Wait(ctx, "llm"), context.Canceled)
+}
+
+func TestReevaluateDocument_SettlesDeletedRules(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	stored := `[{"rule_id":"r1","rule_name":"Signature","status":"pass"},{"rule_id":"r9","rule_name":"Retired Rule","status":"fail","severity":"low"}]`
+	fake.on(`^SELECT "id","ocr_text"`, []string{"id", "ocr_text", "parsed_data", "risk_score"},
+		[]driver.Value{"doc-1", "Signed by both parties.", []byte(stored), 1.0})
+	onRuleResult(fake, "fail")
+	s := &DocumentService{db: db, evaluationMode: EvaluationModeLocal}
+	rules := []model.ComplianceRule{{ID: "r1", Name: "Signature", Pattern: "signed by", Severity: "high"}}
+
+	changed, err := s.reevaluateDocument(context.Background(), "doc-1", rules, rules, false)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	var settled []string
+	for _, statement := range fake.executed(`^UPDATE "action_items"`) {
+		assert.Equal(t, "completed", statement.Args[0])
+		for _, arg := range statement.Args {
+			if id, ok := arg.(string); ok && (id == "r1" || id == "r9") {
+				settled = append(settled, id)
+			}
+		}
+	}
+	assert.ElementsMatch(t, []string{"r1", "r9"}, settled, "the pending action items of the deleted rule are completed")
+	assert.Empty(t, fake.executed(`^INSERT INTO "action_items"`))
+}
+
+func TestSubmitReevaluation_RejectsNonUUIDIDs(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	s := &DocumentService{db: db}
+
+	_, err := s.SubmitReevaluation(ReevaluateParams{DocumentIDs: []string{"not-a-uuid"}})
+	assert.ErrorIs(t, err, ErrInvalidReevaluation)
+	_, err = s.SubmitReevaluation(ReevaluateParams{RuleIDs: []string{"42"}})
+	assert.ErrorIs(t, err, ErrInvalidReevaluation)
+	assert.Empty(t, fake.executed(`.`), "invalid ids are rejected before querying")
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidReindex is returned when a reindex request has an invalid batch size.
+var ErrInvalidReindex = errors.New("invalid reindex request")
+
+// Reindex batch sizes: the number of documents sent in one bulk request.
+const (
+	DefaultReindexBatchSize = 500
+	MaxReindexBatchSize     = 5000
+)
+
+// maxReportIDs caps how many IDs each list of a reconciliation report holds.
+const maxReportIDs = 100
+
+// staleTolerance is how far the updated_at of an index entry may differ from the stored one before the
+// entry counts as stale; the database keeps microseconds while the index may hold nanoseconds.
+const staleTolerance = time.Millisecond
+
+// reindexStageNames are the stages reported for reindex jobs.
+var reindexStageNames = []string{"reconcile", "index", "cleanup"}
+
+// ReindexParams configures a rebuild of the search index from the documents table.
+type ReindexParams struct {
+	// DriftedOnly reindexes only the documents missing from or stale in the index instead of all.
+	DriftedOnly bool `json:"drifted_only,omitempty"`
+	// BatchSize is the number of documents per bulk request, DefaultReindexBatchSize when zero.
+	BatchSize int `json:"batch_size,omitempty"`
+}
+
+// IndexReport compares the search index with the documents table. Missing documents have no index
+// entry, stale documents changed after their entry was written, and orphaned entries have no document,
+// such as entries keyed by file ID before entries were keyed by document ID. The counts are complete;
+// each list holds at most maxReportIDs IDs.
+type IndexReport struct {
+	Backend       string    `json:"backend"`
+	Documents     int       `json:"documents"`
+	Entries       int       `json:"entries"`
+	MissingCount  int       `json:"missing_count"`
+	Missing       []string  `json:"missing"`
+	StaleCount    int       `json:"stale_count"`
+	Stale         []string  `json:"stale"`
+	OrphanedCount int       `json:"orphaned_count"`
+	Orphaned      []string  `json:"orphaned"`
+	CheckedAt     time.Time `json:"checked_at"`
+}
+
+// ReindexResult is the result of a reindex job: how many documents were indexed (Changed) or failed,
+// how many orphaned entries were removed, and the report of the drift found before reindexing.
+type ReindexResult struct {
+	Progress model.JobProgress `json:"progress"`
+	Removed  int               `json:"removed"`
+	Report   *IndexReport      `json:"report"`
+}
+
+// documentVersion identifies the stored version of a document.
+type documentVersion struct {
+	ID        string
+	UpdatedAt time.Time
+}
+
+// normalizeReindexParams validates params and fills in the defaults
+func normalizeReindexParams(params *ReindexParams) error {
+	if params.BatchSize < 0 || params.BatchSize > MaxReindexBatchSize {
+		return fmt.Errorf("%w: batch_size must be between 1 and %d", ErrInvalidReindex, MaxReindexBatchSize)
+	}
+	if params.BatchSize == 0 {
+		params.BatchSize = DefaultReindexBatchSize
+	}
+	return nil
+}
+
+// reconcileEntries compares the index entries with the stored documents. Besides the report it returns
+// every missing or stale document ID, in the order of docs, and every orphaned entry key.
+func reconcileEntries(backend string, docs []documentVersion, entries map[string]time.Time) (*IndexReport, []string, []string) {
+	report := &IndexReport{
+		Backend:   backend,
+		Documents: len(docs),
+		Entries:   len(entries),
+		Missing:   []string{},
+		Stale:     []string{},
+		Orphaned:  []string{},
+		CheckedAt: time.Now().UTC(),
+	}
+	var drifted, orphaned []string
+	stored := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		stored[doc.ID] = true
+		indexedAt, ok := entries[doc.ID]
+		switch {
+		case !ok:
+			report.MissingCount++
+			if len(report.Missing) < maxReportIDs {
+				report.Missing = append(report.Missing, doc.ID)
+			}
+		case indexedAt.Sub(doc.UpdatedAt) > staleTolerance || doc.UpdatedAt.Sub(indexedAt) > staleTolerance:
+			report.StaleCount++
+			if len(report.Stale) < maxReportIDs {
+				report.Stale = append(report.Stale, doc.ID)
+			}
+		default:
+			continue
+		}
+		drifted = append(drifted, doc.ID)
+	}
+	for key := range entries {
+		if !stored[key] {
+			orphaned = append(orphaned, key)
+		}
+	}
+	sort.Strings(orphaned)
+	report.OrphanedCount = len(orphaned)
+	for i := 0; i < len(orphaned) && i < maxReportIDs; i++ {
+		report.Orphaned = append(report.Orphaned, orphaned[i])
+	}
+	return report, drifted, orphaned
+}
+
+// indexState lists the stored document versions, oldest first, and the entries of the search index
+func (s *DocumentService) indexState(ctx context.Context) ([]documentVersion, map[string]time.Time, error) {
+	if s.search == nil {
+		return nil, nil, fmt.Errorf("search index is not configured")
+	}
+	var docs []documentVersion
+	if err := s.db.WithContext(ctx).Model(&model.Document{}).Select("id, updated_at").Order("created_at, id").Scan(&docs).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	entries, err := s.search.Entries(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list %s index entries: %w", s.search.Name(), err)
+	}
+	return docs, entries, nil
+}
+
+// ReconcileIndex reports the documents missing from or stale in the search index and the index
+// entries without a document
+func (s *DocumentService) ReconcileIndex(ctx context.Context) (*IndexReport, error) {
+	docs, entries, err := s.indexState(ctx)
+	if err != nil {
+		log.Printf("[ReconcileIndex] Error reading index state: %v", err)
+		return nil, err
+	}
+	report, _, _ := reconcileEntries(s.search.Name(), docs, entries)
+	log.Printf("[ReconcileIndex] %d documents, %d entries: %d missing, %d stale, %d orphaned",
+		report.Documents, report.Entries, report.MissingCount, report.StaleCount, report.OrphanedCount)
+	return report, nil
+}
+
+// SubmitReindex validates params and queues a job that rebuilds the search index from the documents
+// table
+func (s *DocumentService) SubmitReindex(params ReindexParams) (*model.ProcessingJob, error) {
+	job, err := newReindexJob(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(job).Error; err != nil {
+		log.Printf("[SubmitReindex] Error creating job: %v", err)
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+	log.Printf("[SubmitReindex] Queued reindex job %s with params %s", job.ID, string(job.Params))
+	s.enqueueJob(job.ID)
+	return job, nil
+}
+
+// RunReindex rebuilds the search index in the caller's goroutine, for command-line tools. The job
+// is created already claimed so that the workers of a running server do not take it over, and it
+// fails if its lease expired and another worker claimed it anyway.
+func (s *DocumentService) RunReindex(ctx context.Context, params ReindexParams) (*model.ProcessingJob, error) {
+	job, err := newReindexJob(params)
+	if err != nil {
+		return nil, err
+	}
+	job.Status = model.JobStatusRunning
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(job).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.ProcessingJob{}).Where("id = ?", job.ID).Updates(claimUpdates()).Error; err != nil {
+			return err
+		}
+		return tx.First(job, "id = ?", job.ID).Error
+	})
+	if err != nil {
+		log.Printf("[RunReindex] Error creating job: %v", err)
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+	log.Printf("[RunReindex] Running reindex job %s with params %s", job.ID, string(job.Params))
+
+	runErr := s.executeJob(ctx, job)
+	finished, err := s.GetJob(job.ID)
+	if err != nil {
+		return nil, err
+	}
+	if finished.Attempts != job.Attempts {
+		return nil, fmt.Errorf("job %s was claimed by another worker while running", job.ID)
+	}
+	if runErr != nil && finished.Error == "" {
+		return nil, runErr
+	}
+	return finished, nil
+}
+
+// newReindexJob builds a queued reindex job for params
+func newReindexJob(params ReindexParams) (*model.ProcessingJob, error) {
+	if err := normalizeReindexParams(&params); err != nil {
+		return nil, err
+	}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job params: %w", err)
+	}
+	stages := make([]model.JobStage, len(reindexStageNames))
+	for i, name := range reindexStageNames {
+		stages[i] = model.JobStage{Name: name, Status: model.JobStatusPending}
+	}
+	stagesJSON, err := json.Marshal(stages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job stages: %w", err)
+	}
+
+	return &model.ProcessingJob{
+		Type:      model.JobTypeReindex,
+		Status:    model.JobStatusQueued,
+		Stages:    datatypes.JSON(stagesJSON),
+		Params:    datatypes.JSON(paramsJSON),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}, nil
+}
+
+// runReindexJob compares the index with the documents table, writes the documents to the index in
+// bulk batches and removes orphaned entries. Indexing is idempotent, so an interrupted job simply
+// starts over.
+func (s *DocumentService) runReindexJob(ctx context.Context, job *model.ProcessingJob) error {
+	tracker := newJobTracker(s, job, reindexStageNames)
+
+	var params ReindexParams
+	if len(job.Params) > 0 {
+		if err := json.Unmarshal(job.Params, &params); err != nil {
+			tracker.fail(err)
+			return fmt.Errorf("failed to parse job params: %w", err)
+		}
+	}
+	if err := normalizeReindexParams(&params); err != nil {
+		tracker.fail(err)
+		return err
+	}
+
+	tracker.stageStarted("reconcile")
+	docs, entries, err := s.indexState(ctx)
+	tracker.stageFinished("reconcile", err)
+	if err != nil {
+		tracker.fail(err)
+		return err
+	}
+	report, drifted, orphaned := reconcileEntries(s.search.Name(), docs, entries)
+
+	ids := drifted
+	if !params.DriftedOnly {
+		ids = make([]string, 0, len(docs))
+		for _, doc := range docs {
+			ids = append(ids, doc.ID)
+		}
+	}
+	progress := model.JobProgress{Total: len(ids)}
+	tracker.saveProgress(progress)
+
+	tracker.stageStarted("index")
+	for start := 0; start < len(ids); start += params.BatchSize {
+		if ctx.Err() != nil {
+			// The job stays running until its lease expires and a worker claims it again.
+			return ctx.Err()
+		}
+		batch := ids[start:min(start+params.BatchSize, len(ids))]
+		progress.Processed += len(batch)
+
+		var batchDocs []model.Document
+		if err := s.db.WithContext(ctx).Where("id IN ?", batch).Find(&batchDocs).Error; err != nil {
+			log.Printf("[runReindexJob] Error loading batch at %d: %v", start, err)
+			progress.Failed += len(batch)
+			addProgressError(&progress, batch[0], err.Error())
+			tracker.saveProgress(progress)
+			continue
+		}
+		// Documents deleted since the reconcile stage are simply not indexed.
+		failures, err := s.search.Index(ctx, batchDocs)
+		if err != nil {
+			log.Printf("[runReindexJob] Error indexing batch at %d: %v", start, err)
+			progress.Failed += len(batchDocs)
+			addProgressError(&progress, batch[0], err.Error())
+			tracker.saveProgress(progress)
+			continue
+		}
+		progress.Changed += len(batchDocs) - len(failures)
+		progress.Failed += len(failures)
+		for _, failure := range failures {
+			addProgressError(&progress, failure.DocumentID, failure.Reason)
+		}
+		tracker.saveProgress(progress)
+	}
+	tracker.stageFinished("index", nil)
+
+	removed := 0
+	if len(orphaned) == 0 {
+		tracker.stageFinished("cleanup", errStageSkipped)
+	} else {
+		tracker.stageStarted("cleanup")
+		for _, key := range orphaned {
+			if err := s.search.Delete(ctx, key); err != nil {
+				log.Printf("[runReindexJob] Error removing orphaned entry %s: %v", key, err)
+				addProgressError(&progress, key, err.Error())
+				continue
+			}
+			removed++
+		}
+		tracker.stageFinished("cleanup", nil)
+	}
+
+	tracker.completeWith(ReindexResult{Progress: progress, Removed: removed, Report: report}, "")
+	log.Printf("[runReindexJob] Job %s indexed %d of %d documents (%d failed) and removed %d orphaned entries",
+		job.ID, progress.Changed, progress.Total, progress.Failed, removed)
+	return nil
+}
+
+// addProgressError records why an item failed, up to maxProgressErrors items
+func addProgressError(progress *model.JobProgress, id, message string) {
+	if len(progress.Errors) >= maxProgressErrors {
+		return
+	}
+	if progress.Errors == nil {
+		progress.Errors = make(map[string]string)
+	}
+	progress.Errors[id] = message
+}
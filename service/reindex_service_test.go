@@ -0,0 +1,124 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileEntries(t *testing.T) {
+	updated := time.Date(2025, 5, 1, 10, 0, 0, 123456000, time.UTC)
+	docs := []documentVersion{
+		{ID: "indexed", UpdatedAt: updated},
+		{ID: "missing", UpdatedAt: updated},
+		{ID: "stale", UpdatedAt: updated.Add(time.Hour)},
+	}
+	entries := map[string]time.Time{
+		// The index may keep more precision than the database.
+		"indexed":      updated.Add(789 * time.Nanosecond),
+		"stale":        updated,
+		"legacy-file":  {},
+		"deleted-long": updated,
+	}
+
+	report, drifted, orphaned := reconcileEntries("elasticsearch", docs, entries)
+	assert.Equal(t, 3, report.Documents)
+	assert.Equal(t, 4, report.Entries)
+	assert.Equal(t, []string{"missing"}, report.Missing)
+	assert.Equal(t, []string{"stale"}, report.Stale)
+	assert.Equal(t, 2, report.OrphanedCount)
+	assert.Equal(t, []string{"deleted-long", "legacy-file"}, report.Orphaned)
+	assert.Equal(t, []string{"missing", "stale"}, drifted)
+	assert.Equal(t, []string{"deleted-long", "legacy-file"}, orphaned)
+
+	report, drifted, orphaned = reconcileEntries("postgres", nil, nil)
+	assert.Empty(t, report.Missing)
+	assert.Empty(t, drifted)
+	assert.Empty(t, orphaned)
+}
+
+func TestNormalizeReindexParams(t *testing.T) {
+	params := ReindexParams{}
+	require.NoError(t, normalizeReindexParams(&params))
+	assert.Equal(t, DefaultReindexBatchSize, params.BatchSize)
+
+	assert.ErrorIs(t, normalizeReindexParams(&ReindexParams{BatchSize: -1}), ErrInvalidReindex)
+	assert.ErrorIs(t, normalizeReindexParams(&ReindexParams{BatchSize: MaxReindexBatchSize + 1}), ErrInvalidReindex)
+}
+
+func TestBulkIndexBody(t *testing.T) {
+	t.Setenv("SUPABASE_BUCKET", "legal")
+	docs := []model.Document{
+		{ID: "d1", Title: "Lease", OriginalURL: "https://example.com/storage/v1/object/public/legal/abc.pdf"},
+		{ID: "d2", Title: "NDA"},
+	}
+	body, err := bulkIndexBody(docs, time.Now())
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	require.Len(t, lines, 4)
+	assert.JSONEq(t, `{"index":{"_id":"d1"}}`, lines[0])
+	var source map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &source))
+	assert.Equal(t, "d1", source["id"])
+	assert.Equal(t, "abc.pdf", source["file_id"])
+	assert.JSONEq(t, `{"index":{"_id":"d2"}}`, lines[2])
+}
+
+func TestParseBulkResponse(t *testing.T) {
+	failures, err := parseBulkResponse(bytes.NewBufferString(`{"errors":false,"items":[{"index":{"_id":"d1","status":201}}]}`))
+	require.NoError(t, err)
+	assert.Empty(t, failures)
+
+	failures, err = parseBulkResponse(bytes.NewBufferString(`{"errors":true,"items":[
+		{"index":{"_id":"d1","status":201}},
+		{"index":{"_id":"d2","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [risk_score]"}}}
+	]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []IndexFailure{{DocumentID: "d2", Reason: "mapper_parsing_exception: failed to parse field [risk_score]"}}, failures)
+}
+
+const testJobID = "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee"
+
+var fakeJobColumns = []string{"id", "type", "status", "attempts", "params"}
+
+func TestRunReindex_CreatesClaimedJob(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	fake.on(`^INSERT INTO "processing_jobs"`, []string{"id"}, []driver.Value{testJobID})
+	fake.on(`FROM "processing_jobs" WHERE id = \$1`, fakeJobColumns,
+		[]driver.Value{testJobID, model.JobTypeReindex, model.JobStatusRunning, int64(1), []byte(`{"batch_size":500}`)})
+	s := &DocumentService{db: db, search: &recordingIndex{}}
+
+	job, err := s.RunReindex(context.Background(), ReindexParams{})
+	require.NoError(t, err)
+	assert.Equal(t, testJobID, job.ID)
+
+	inserts := fake.executed(`^INSERT INTO "processing_jobs"`)
+	require.Len(t, inserts, 1)
+	assert.Contains(t, inserts[0].Args, model.JobStatusRunning, "server workers never see the job queued")
+	assert.NotContains(t, inserts[0].Args, model.JobStatusQueued)
+	claims := fake.executed(`^UPDATE "processing_jobs" SET .*"lease_expires_at"`)
+	require.NotEmpty(t, claims)
+	assert.Contains(t, claims[0].Args, testJobID)
+}
+
+func TestRunReindex_FailsWhenClaimIsLost(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	fake.on(`^INSERT INTO "processing_jobs"`, []string{"id"}, []driver.Value{testJobID})
+	fake.on(`FROM "processing_jobs" WHERE id = \$1`, fakeJobColumns,
+		[]driver.Value{testJobID, model.JobTypeReindex, model.JobStatusRunning, int64(1), []byte(`{"batch_size":500}`)}).onlyOnce()
+	fake.on(`FROM "processing_jobs" WHERE id = \$1`, fakeJobColumns,
+		[]driver.Value{testJobID, model.JobTypeReindex, model.JobStatusRunning, int64(2), []byte(`{"batch_size":500}`)})
+	s := &DocumentService{db: db, search: &recordingIndex{}}
+
+	_, err := s.RunReindex(context.Background(), ReindexParams{})
+	assert.ErrorContains(t, err, "claimed by another worker")
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	model "github.com/Itish41/LegalEagle/models"
+)
+
+// maxDryRunDocuments bounds how many stored documents one dry run may evaluate.
+const maxDryRunDocuments = 50
+
+// uuidPattern matches the textual form of IDs. Other IDs cannot exist, so they are not looked up.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// RuleDryRunRequest is a draft rule and the inputs to evaluate it against.
+type RuleDryRunRequest struct {
+	Rule        model.ComplianceRule `json:"rule"`
+	Text        string               `json:"text"`
+	DocumentIDs []string             `json:"document_ids"`
+}
+
+// RuleDryRunResult is the verdict of a draft rule for one input.
+type RuleDryRunResult struct {
+	DocumentID  string      `json:"document_id,omitempty"`
+	Title       string      `json:"title,omitempty"`
+	Status      string      `json:"status"`
+	Explanation string      `json:"explanation,omitempty"`
+	Matches     []RuleMatch `json:"matches"`
+	EvaluatedBy string      `json:"evaluated_by,omitempty"`
+	Error       string      `json:"error,omitempty"`
+}
+
+// RuleDryRunResponse is the outcome of a dry run.
+type RuleDryRunResponse struct {
+	Rule           model.ComplianceRule `json:"rule"`
+	EvaluationMode string               `json:"evaluation_mode"`
+	Results        []RuleDryRunResult   `json:"results"`
+}
+
+// DryRunRule evaluates a draft rule against raw text and/or stored documents through the same path
+// as the upload pipeline. Nothing is written to the database.
+func (s *DocumentService) DryRunRule(ctx context.Context, req RuleDryRunRequest) (*RuleDryRunResponse, error) {
+	if req.Text == "" && len(req.DocumentIDs) == 0 {
+		return nil, fmt.Errorf("%w: text or document_ids is required", ErrInvalidRule)
+	}
+	if len(req.DocumentIDs) > maxDryRunDocuments {
+		return nil, fmt.Errorf("%w: at most %d documents can be tested at once", ErrInvalidRule, maxDryRunDocuments)
+	}
+
+	rule := req.Rule
+	if strings.TrimSpace(rule.Name) == "" {
+		rule.Name = "Draft rule"
+	}
+	if strings.TrimSpace(rule.Severity) == "" {
+		rule.Severity = "medium"
+	}
+	if err := validateComplianceRule(&rule); err != nil {
+		return nil, err
+	}
+
+	response := &RuleDryRunResponse{Rule: rule, EvaluationMode: s.ruleEvaluationMode(), Results: []RuleDryRunResult{}}
+
+	if req.Text != "" {
+		result, err := s.dryRunText(ctx, rule, req.Text)
+		if err != nil {
+			return nil, err
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	if len(req.DocumentIDs) > 0 {
+		// IDs that are not UUIDs cannot match any document and would make the query fail.
+		var ids []string
+		for _, id := range req.DocumentIDs {
+			if uuidPattern.MatchString(id) {
+				ids = append(ids, strings.ToLower(id))
+			}
+		}
+		var docs []model.Document
+		if len(ids) > 0 {
+			if err := s.db.Select("id", "title", "ocr_text").Where("id IN ?", ids).Find(&docs).Error; err != nil {
+				log.Printf("[DryRunRule] Error fetching documents: %v", err)
+				return nil, err
+			}
+		}
+		docsByID := make(map[string]model.Document, len(docs))
+		for _, doc := range docs {
+			docsByID[doc.ID] = doc
+		}
+
+		for _, id := range req.DocumentIDs {
+			doc, ok := docsByID[strings.ToLower(id)]
+			if !ok {
+				response.Results = append(response.Results, RuleDryRunResult{DocumentID: id, Status: "error", Matches: []RuleMatch{}, Error: "document not found"})
+				continue
+			}
+			result, err := s.dryRunText(ctx, rule, doc.OcrText)
+			if err != nil {
+				result = RuleDryRunResult{Status: "error", Matches: []RuleMatch{}, Error: err.Error()}
+			}
+			result.DocumentID = doc.ID
+			result.Title = doc.Title
+			response.Results = append(response.Results, result)
+		}
+	}
+	return response, nil
+}
+
+// dryRunText evaluates rule against a single text
+func (s *DocumentService) dryRunText(ctx context.Context, rule model.ComplianceRule, text string) (RuleDryRunResult, error) {
+	results, err := s.evaluateCompliance(ctx, text, []model.ComplianceRule{rule}, withLLMFallback)
+	if err != nil {
+		return RuleDryRunResult{}, err
+	}
+	entry := results[0]
+
+	result := RuleDryRunResult{Matches: []RuleMatch{}}
+	result.Status, _ = entry["status"].(string)
+	result.Explanation, _ = entry["explanation"].(string)
+	result.EvaluatedBy, _ = entry["evaluated_by"].(string)
+	if matches, ok := entry["matches"].([]RuleMatch); ok && matches != nil {
+		result.Matches = matches
+	}
+	return result, nil
+}
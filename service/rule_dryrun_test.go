@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunRule_Text(t *testing.T) {
+	s := &DocumentService{evaluationMode: EvaluationModeLocal}
+
+	response, err := s.DryRunRule(context.Background(), RuleDryRunRequest{
+		Rule: models.ComplianceRule{Pattern: "any: governing law"},
+		Text: "This Agreement's governing law is India.",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Draft rule", response.Rule.Name)
+	assert.Equal(t, EvaluationModeLocal, response.EvaluationMode)
+	require.Len(t, response.Results, 1)
+	assert.Equal(t, "pass", response.Results[0].Status)
+	assert.Equal(t, []RuleMatch{{Text: "governing law", Start: 17, End: 30}}, response.Results[0].Matches)
+}
+
+func TestDryRunRule_UsesLLMForPatternlessRules(t *testing.T) {
+	stub := NewStubLLMClient(map[LLMTask]string{
+		LLMTaskRuleDetection: `{"violated_rules":["Fair Dealing"]}`,
+	})
+	s := &DocumentService{llm: stub, evaluationMode: EvaluationModeHybrid}
+
+	response, err := s.DryRunRule(context.Background(), RuleDryRunRequest{
+		Rule: models.ComplianceRule{Name: "Fair Dealing", Description: "Terms must be balanced", Severity: "low"},
+		Text: "The Supplier may change any term at any time.",
+	})
+	require.NoError(t, err)
+	require.Len(t, response.Results, 1)
+	assert.Equal(t, "fail", response.Results[0].Status)
+	assert.Equal(t, "llm", response.Results[0].EvaluatedBy)
+	require.Len(t, stub.Requests, 1)
+	assert.Contains(t, stub.Requests[0].Messages[0].Content, "Fair Dealing: Terms must be balanced")
+}
+
+func TestDryRunRule_Invalid(t *testing.T) {
+	s := &DocumentService{evaluationMode: EvaluationModeLocal}
+
+	_, err := s.DryRunRule(context.Background(), RuleDryRunRequest{Rule: models.ComplianceRule{Pattern: "x"}})
+	assert.ErrorIs(t, err, ErrInvalidRule)
+
+	_, err = s.DryRunRule(context.Background(), RuleDryRunRequest{Rule: models.ComplianceRule{Expression: `"a" AND`}, Text: "a"})
+	assert.ErrorIs(t, err, ErrInvalidRule)
+}
+
+func TestDryRunRule_Documents(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	fake.on(`FROM "documents" WHERE id IN`, []string{"id", "title", "ocr_text"},
+		[]driver.Value{"6f1c2a7e-3b4d-4c5e-8f90-1a2b3c4d5e6f", "Supply agreement", "The governing law is India."})
+	s := &DocumentService{db: db, evaluationMode: EvaluationModeLocal}
+
+	response, err := s.DryRunRule(context.Background(), RuleDryRunRequest{
+		Rule:        models.ComplianceRule{Pattern: "any: governing law"},
+		DocumentIDs: []string{"6F1C2A7E-3B4D-4C5E-8F90-1A2B3C4D5E6F", "not-a-uuid", "00000000-0000-0000-0000-000000000000"},
+	})
+	require.NoError(t, err)
+	require.Len(t, response.Results, 3)
+	assert.Equal(t, "pass", response.Results[0].Status)
+	assert.Equal(t, "Supply agreement", response.Results[0].Title)
+	for _, result := range response.Results[1:] {
+		assert.Equal(t, "error", result.Status)
+		assert.Equal(t, "document not found", result.Error)
+	}
+	assert.Equal(t, "not-a-uuid", response.Results[1].DocumentID)
+
+	queries := fake.executed(`FROM "documents" WHERE id IN`)
+	require.Len(t, queries, 1)
+	assert.Equal(t, []interface{}{"6f1c2a7e-3b4d-4c5e-8f90-1a2b3c4d5e6f", "00000000-0000-0000-0000-000000000000"}, queries[0].Args)
+}
+
+func TestDryRunRule_OnlyInvalidDocumentIDs(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	s := &DocumentService{db: db, evaluationMode: EvaluationModeLocal}
+
+	response, err := s.DryRunRule(context.Background(), RuleDryRunRequest{
+		Rule:        models.ComplianceRule{Pattern: "any: governing law"},
+		DocumentIDs: []string{"42"},
+	})
+	require.NoError(t, err)
+	require.Len(t, response.Results, 1)
+	assert.Equal(t, "document not found", response.Results[0].Error)
+	assert.Empty(t, fake.executed(`.`), "no query is run for IDs that cannot exist")
+}
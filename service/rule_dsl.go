@@ -0,0 +1,618 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A rule expression composes patterns with boolean logic and scopes them to parts of a document.
+//
+//	expr    := or
+//	or      := and { "OR" and }
+//	and     := unary { "AND" unary }
+//	unary   := "NOT" unary | primary [ "IN" scope ]
+//	primary := STRING | "count" "(" STRING ")" CMP NUMBER | "(" expr ")"
+//	scope   := "section" "(" STRING ")" | "page" "(" NUMBER | "first" | "last" ")"
+//	CMP     := "=" | "==" | "!=" | "<" | "<=" | ">" | ">="
+//
+// STRING is a double or single quoted pattern in any form accepted by CompileRulePattern. Keywords
+// are case-insensitive. For example:
+//
+//	"any: governing law" AND NOT ("arbitration" AND NOT "india")
+//	"any: signature, signed by" IN page(last)
+//	count("any: shall") >= 3 IN section("obligations")
+//
+// Scopes nest: a scope inside another only sees text covered by both.
+
+// RuleExpressionError describes a syntax error in a rule expression. Position is the byte offset of
+// the offending token; Line and Column are 1-based.
+type RuleExpressionError struct {
+	Message  string `json:"message"`
+	Position int    `json:"position"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+func (e *RuleExpressionError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// RuleExpression is a parsed rule expression.
+type RuleExpression struct {
+	Source string
+	root   exprNode
+}
+
+// ParseRuleExpression parses and validates a rule expression. Errors are *RuleExpressionError.
+func ParseRuleExpression(source string) (*RuleExpression, error) {
+	p := &exprParser{source: source}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	if p.peek().kind == tokEOF {
+		return nil, p.errorAt(p.peek(), "expression is empty")
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorAt(tok, fmt.Sprintf("unexpected %s", tok.describe()))
+	}
+	return &RuleExpression{Source: source, root: root}, nil
+}
+
+// Evaluate reports whether text satisfies the expression, with the spans that contributed to a
+// true result
+func (e *RuleExpression) Evaluate(text string) (bool, []RuleMatch) {
+	doc := &exprDocument{text: text}
+	value, matches := e.root.eval(doc, []textSpan{{0, len(text)}})
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Start < matches[j].Start })
+	return value, matches
+}
+
+// textSpan is a byte range of the document text.
+type textSpan struct {
+	start, end int
+}
+
+// exprDocument holds the text being evaluated and lazily detected pages and sections.
+type exprDocument struct {
+	text     string
+	pages    []textSpan
+	sections []documentSection
+	detected bool
+}
+
+// documentSection is a detected section of a document, starting at its heading line.
+type documentSection struct {
+	Heading string
+	span    textSpan
+}
+
+func (d *exprDocument) detect() {
+	if d.detected {
+		return
+	}
+	d.detected = true
+	d.pages = splitPages(d.text)
+	d.sections = detectSections(d.text)
+}
+
+// splitPages splits OCR text on PageSeparator
+func splitPages(text string) []textSpan {
+	var pages []textSpan
+	start := 0
+	for {
+		idx := strings.Index(text[start:], PageSeparator)
+		if idx < 0 {
+			pages = append(pages, textSpan{start, len(text)})
+			return pages
+		}
+		pages = append(pages, textSpan{start, start + idx})
+		start += idx + len(PageSeparator)
+	}
+}
+
+// numberedHeading matches lines such as "12. Governing Law", "3.1 Fees" or "Article IV - Term".
+var numberedHeading = regexp.MustCompile(`(?i)^(?:(?:section|article|clause|schedule)\s+[\dIVXLC]+[.:)]?|\d+(?:\.\d+)*[.)]?)\s+\S`)
+
+// detectSections finds heading lines and returns the sections they start. Headings are numbered
+// lines or short upper-case lines.
+func detectSections(text string) []documentSection {
+	var sections []documentSection
+	offset := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(strings.ReplaceAll(line, PageSeparator, ""))
+		if isHeadingLine(trimmed) {
+			if n := len(sections); n > 0 {
+				sections[n-1].span.end = offset
+			}
+			sections = append(sections, documentSection{Heading: trimmed, span: textSpan{offset, len(text)}})
+		}
+		offset += len(line)
+	}
+	return sections
+}
+
+// isHeadingLine reports whether a trimmed line looks like a section heading
+func isHeadingLine(line string) bool {
+	if line == "" || len(line) > 80 {
+		return false
+	}
+	if numberedHeading.MatchString(line) {
+		return true
+	}
+	hasLetter := false
+	for _, r := range line {
+		if unicode.IsLower(r) {
+			return false
+		}
+		if unicode.IsLetter(r) {
+			hasLetter = true
+		}
+	}
+	return hasLetter && len(strings.Fields(line)) <= 8
+}
+
+// intersectSpans returns the parts of spans covered by scope
+func intersectSpans(spans, scope []textSpan) []textSpan {
+	var result []textSpan
+	for _, a := range spans {
+		for _, b := range scope {
+			start, end := a.start, a.end
+			if b.start > start {
+				start = b.start
+			}
+			if b.end < end {
+				end = b.end
+			}
+			if start < end {
+				result = append(result, textSpan{start, end})
+			}
+		}
+	}
+	return result
+}
+
+// exprNode is a node of a parsed rule expression.
+type exprNode interface {
+	eval(doc *exprDocument, spans []textSpan) (bool, []RuleMatch)
+}
+
+type andNode struct{ left, right exprNode }
+type orNode struct{ left, right exprNode }
+type notNode struct{ inner exprNode }
+
+type patternNode struct {
+	pattern *RulePattern
+}
+
+type countNode struct {
+	pattern *RulePattern
+	op      string
+	value   int
+}
+
+type scopeNode struct {
+	inner exprNode
+	kind  string // "section" or "page"
+	name  string
+	page  int // 1-based; 0 selects the last page
+}
+
+func (n *andNode) eval(doc *exprDocument, spans []textSpan) (bool, []RuleMatch) {
+	left, leftMatches := n.left.eval(doc, spans)
+	if !left {
+		return false, nil
+	}
+	right, rightMatches := n.right.eval(doc, spans)
+	if !right {
+		return false, nil
+	}
+	return true, append(leftMatches, rightMatches...)
+}
+
+func (n *orNode) eval(doc *exprDocument, spans []textSpan) (bool, []RuleMatch) {
+	left, leftMatches := n.left.eval(doc, spans)
+	right, rightMatches := n.right.eval(doc, spans)
+	var matches []RuleMatch
+	if left {
+		matches = append(matches, leftMatches...)
+	}
+	if right {
+		matches = append(matches, rightMatches...)
+	}
+	return left || right, matches
+}
+
+func (n *notNode) eval(doc *exprDocument, spans []textSpan) (bool, []RuleMatch) {
+	value, _ := n.inner.eval(doc, spans)
+	return !value, nil
+}
+
+// matchSpans runs pattern over every span and returns matches with document offsets
+func matchSpans(pattern *RulePattern, doc *exprDocument, spans []textSpan) []RuleMatch {
+	var matches []RuleMatch
+	for _, span := range spans {
+		for _, m := range pattern.Match(doc.text[span.start:span.end]).Matches {
+			matches = append(matches, RuleMatch{Text: m.Text, Start: m.Start + span.start, End: m.End + span.start})
+		}
+	}
+	return matches
+}
+
+func (n *patternNode) eval(doc *exprDocument, spans []textSpan) (bool, []RuleMatch) {
+	if n.pattern.Kind == PatternAll {
+		// Every keyword must appear somewhere in scope, not necessarily in the same span.
+		var matches []RuleMatch
+		for _, kw := range n.pattern.keywords {
+			found := matchSpans(&RulePattern{Kind: PatternAny, keywords: []string{kw}}, doc, spans)
+			if len(found) == 0 {
+				return false, nil
+			}
+			matches = append(matches, found...)
+		}
+		return true, matches
+	}
+	matches := matchSpans(n.pattern, doc, spans)
+	return len(matches) > 0, matches
+}
+
+func (n *countNode) eval(doc *exprDocument, spans []textSpan) (bool, []RuleMatch) {
+	matches := matchSpans(n.pattern, doc, spans)
+	count := len(matches)
+	var ok bool
+	switch n.op {
+	case "=", "==":
+		ok = count == n.value
+	case "!=":
+		ok = count != n.value
+	case "<":
+		ok = count < n.value
+	case "<=":
+		ok = count <= n.value
+	case ">":
+		ok = count > n.value
+	case ">=":
+		ok = count >= n.value
+	}
+	if !ok {
+		return false, nil
+	}
+	return true, matches
+}
+
+func (n *scopeNode) eval(doc *exprDocument, spans []textSpan) (bool, []RuleMatch) {
+	doc.detect()
+	var scope []textSpan
+	switch n.kind {
+	case "page":
+		index := n.page - 1
+		if n.page == 0 {
+			index = len(doc.pages) - 1
+		}
+		if index >= 0 && index < len(doc.pages) {
+			scope = append(scope, doc.pages[index])
+		}
+	case "section":
+		name := strings.ToLower(n.name)
+		for _, section := range doc.sections {
+			if strings.Contains(strings.ToLower(section.Heading), name) {
+				scope = append(scope, section.span)
+			}
+		}
+	}
+	return n.inner.eval(doc, intersectSpans(spans, scope))
+}
+
+// Token kinds of the expression lexer.
+const (
+	tokEOF = iota
+	tokString
+	tokNumber
+	tokIdent
+	tokLParen
+	tokRParen
+	tokCompare
+)
+
+type exprToken struct {
+	kind  int
+	text  string
+	value string
+	pos   int
+}
+
+// describe names a token in error messages
+func (t exprToken) describe() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return fmt.Sprintf("string %q", t.value)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+type exprParser struct {
+	source string
+	tokens []exprToken
+	next   int
+}
+
+// errorAt builds a RuleExpressionError at the position of tok
+func (p *exprParser) errorAt(tok exprToken, message string) *RuleExpressionError {
+	return p.errorAtPos(tok.pos, message)
+}
+
+func (p *exprParser) errorAtPos(pos int, message string) *RuleExpressionError {
+	line := 1 + strings.Count(p.source[:pos], "\n")
+	column := pos + 1
+	if idx := strings.LastIndex(p.source[:pos], "\n"); idx >= 0 {
+		column = pos - idx
+	}
+	return &RuleExpressionError{Message: message, Position: pos, Line: line, Column: column}
+}
+
+// tokenize splits the source into tokens
+func (p *exprParser) tokenize() error {
+	src := p.source
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			p.tokens = append(p.tokens, exprToken{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			p.tokens = append(p.tokens, exprToken{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			var value strings.Builder
+			i++
+			for {
+				if i >= len(src) {
+					return p.errorAtPos(start, "unterminated string")
+				}
+				if src[i] == '\\' && i+1 < len(src) {
+					value.WriteByte(src[i+1])
+					i += 2
+					continue
+				}
+				if src[i] == c {
+					i++
+					break
+				}
+				value.WriteByte(src[i])
+				i++
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokString, text: src[start:i], value: value.String(), pos: start})
+		case c == '<' || c == '>' || c == '=' || c == '!':
+			start := i
+			i++
+			if i < len(src) && src[i] == '=' {
+				i++
+			}
+			if src[start:i] == "!" {
+				return p.errorAtPos(start, "unexpected \"!\", did you mean \"!=\" or NOT?")
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokCompare, text: src[start:i], pos: start})
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(src) && src[i] >= '0' && src[i] <= '9' {
+				i++
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokNumber, text: src[start:i], pos: start})
+		case c == '_' || unicode.IsLetter(rune(c)):
+			start := i
+			for i < len(src) && (src[i] == '_' || unicode.IsLetter(rune(src[i])) || (src[i] >= '0' && src[i] <= '9')) {
+				i++
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokIdent, text: src[start:i], value: strings.ToLower(src[start:i]), pos: start})
+		default:
+			return p.errorAtPos(i, fmt.Sprintf("unexpected character %q", c))
+		}
+	}
+	p.tokens = append(p.tokens, exprToken{kind: tokEOF, pos: len(src)})
+	return nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.next]
+}
+
+func (p *exprParser) advance() exprToken {
+	tok := p.tokens[p.next]
+	if tok.kind != tokEOF {
+		p.next++
+	}
+	return tok
+}
+
+// isKeyword reports whether the next token is the given keyword
+func (p *exprParser) isKeyword(keyword string) bool {
+	tok := p.peek()
+	return tok.kind == tokIdent && tok.value == keyword
+}
+
+// expect consumes a token of the given kind or fails with a message naming what was wanted
+func (p *exprParser) expect(kind int, want string) (exprToken, error) {
+	tok := p.peek()
+	if tok.kind != kind {
+		return tok, p.errorAt(tok, fmt.Sprintf("expected %s, found %s", want, tok.describe()))
+	}
+	return p.advance(), nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("or") {
+		p.advance()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("and") {
+		p.advance()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.isKeyword("not") {
+		p.advance()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{inner}, nil
+	}
+
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("in") {
+		p.advance()
+		if node, err = p.parseScope(node); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == tokString:
+		p.advance()
+		pattern, err := p.compilePattern(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &patternNode{pattern: pattern}, nil
+	case tok.kind == tokLParen:
+		p.advance()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, "\")\""); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	case tok.kind == tokIdent && tok.value == "count":
+		return p.parseCount()
+	case tok.kind == tokIdent:
+		return nil, p.errorAt(tok, fmt.Sprintf("unexpected %s, patterns must be quoted", tok.describe()))
+	default:
+		return nil, p.errorAt(tok, fmt.Sprintf("expected a pattern, count() or \"(\", found %s", tok.describe()))
+	}
+}
+
+// compilePattern compiles the pattern held by a string token
+func (p *exprParser) compilePattern(tok exprToken) (*RulePattern, error) {
+	pattern, err := CompileRulePattern(tok.value)
+	if err != nil {
+		return nil, p.errorAt(tok, fmt.Sprintf("invalid pattern: %v", err))
+	}
+	return pattern, nil
+}
+
+func (p *exprParser) parseCount() (exprNode, error) {
+	p.advance()
+	if _, err := p.expect(tokLParen, "\"(\" after count"); err != nil {
+		return nil, err
+	}
+	tok, err := p.expect(tokString, "a quoted pattern")
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := p.compilePattern(tok)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokRParen, "\")\""); err != nil {
+		return nil, err
+	}
+	op, err := p.expect(tokCompare, "a comparison after count()")
+	if err != nil {
+		return nil, err
+	}
+	num, err := p.expect(tokNumber, "a number")
+	if err != nil {
+		return nil, err
+	}
+	value, convErr := strconv.Atoi(num.text)
+	if convErr != nil {
+		return nil, p.errorAt(num, "number is too large")
+	}
+	return &countNode{pattern: pattern, op: op.text, value: value}, nil
+}
+
+func (p *exprParser) parseScope(inner exprNode) (exprNode, error) {
+	tok := p.peek()
+	if tok.kind != tokIdent || (tok.value != "section" && tok.value != "page") {
+		return nil, p.errorAt(tok, fmt.Sprintf("expected section(...) or page(...) after IN, found %s", tok.describe()))
+	}
+	p.advance()
+	if _, err := p.expect(tokLParen, "\"(\""); err != nil {
+		return nil, err
+	}
+
+	node := &scopeNode{inner: inner, kind: tok.value}
+	arg := p.advance()
+	switch {
+	case node.kind == "section" && arg.kind == tokString:
+		if strings.TrimSpace(arg.value) == "" {
+			return nil, p.errorAt(arg, "section name is empty")
+		}
+		node.name = arg.value
+	case node.kind == "page" && arg.kind == tokNumber:
+		page, err := strconv.Atoi(arg.text)
+		if err != nil || page < 1 {
+			return nil, p.errorAt(arg, "page numbers start at 1")
+		}
+		node.page = page
+	case node.kind == "page" && arg.kind == tokIdent && arg.value == "first":
+		node.page = 1
+	case node.kind == "page" && arg.kind == tokIdent && arg.value == "last":
+		node.page = 0
+	case node.kind == "section":
+		return nil, p.errorAt(arg, fmt.Sprintf("expected a quoted section name, found %s", arg.describe()))
+	default:
+		return nil, p.errorAt(arg, fmt.Sprintf("expected a page number, first or last, found %s", arg.describe()))
+	}
+
+	if _, err := p.expect(tokRParen, "\")\""); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
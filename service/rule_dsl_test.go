@@ -0,0 +1,113 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dslContract = "MASTER SERVICES AGREEMENT\n" +
+	"1. Governing Law\n" +
+	"This Agreement is governed by the laws of India.\n" +
+	"2. Dispute Resolution\n" +
+	"Disputes shall be settled by arbitration in Mumbai, India.\n" +
+	PageSeparator +
+	"3. Obligations\n" +
+	"The Supplier shall deliver. The Supplier shall invoice. The Client shall pay.\n" +
+	"Signed by the parties\n"
+
+func evaluate(t *testing.T, source string) bool {
+	t.Helper()
+	expr, err := ParseRuleExpression(source)
+	require.NoError(t, err, source)
+	value, _ := expr.Evaluate(dslContract)
+	return value
+}
+
+func TestRuleExpression_Evaluate(t *testing.T) {
+	assert.True(t, evaluate(t, `"any: governing law" AND NOT ("arbitration" AND NOT "india")`))
+	assert.False(t, evaluate(t, `"any: governing law" and not "arbitration"`))
+	assert.True(t, evaluate(t, `"any: new york" OR "all: laws, india"`))
+
+	assert.True(t, evaluate(t, `"signed by" IN page(last)`))
+	assert.False(t, evaluate(t, `"signed by" IN page(first)`))
+	assert.False(t, evaluate(t, `"anything" IN page(7)`))
+
+	assert.True(t, evaluate(t, `"arbitration" IN section("dispute")`))
+	assert.False(t, evaluate(t, `"arbitration" IN section("governing law")`))
+	assert.True(t, evaluate(t, `count("any: shall") = 3 IN section("obligations")`))
+	assert.True(t, evaluate(t, `count("any: shall") >= 4`))
+	assert.False(t, evaluate(t, `count("any: shall") > 4`))
+
+	// Nested scopes only see text covered by both.
+	assert.False(t, evaluate(t, `("arbitration" IN section("dispute")) IN page(last)`))
+}
+
+func TestRuleExpression_MatchOffsets(t *testing.T) {
+	expr, err := ParseRuleExpression(`"signed by" IN page(last)`)
+	require.NoError(t, err)
+	value, matches := expr.Evaluate(dslContract)
+	require.True(t, value)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "Signed by", dslContract[matches[0].Start:matches[0].End])
+}
+
+func TestParseRuleExpression_Errors(t *testing.T) {
+	cases := []struct {
+		source   string
+		position int
+		line     int
+		column   int
+		message  string
+	}{
+		{``, 0, 1, 1, "expression is empty"},
+		{`"a" AND`, 7, 1, 8, "expected a pattern"},
+		{`"a" AND governing`, 8, 1, 9, "patterns must be quoted"},
+		{"\"a\" OR\n  (\"b\"", 13, 2, 7, `expected ")"`},
+		{`"a" IN chapter("x")`, 7, 1, 8, "expected section(...) or page(...)"},
+		{`"a" IN page(0)`, 12, 1, 13, "page numbers start at 1"},
+		{`count("a") 3`, 11, 1, 12, "expected a comparison"},
+		{`"regex:(" AND "b"`, 0, 1, 1, "invalid pattern"},
+		{`"unterminated`, 0, 1, 1, "unterminated string"},
+	}
+	for _, tc := range cases {
+		_, err := ParseRuleExpression(tc.source)
+		var exprErr *RuleExpressionError
+		require.ErrorAs(t, err, &exprErr, tc.source)
+		assert.Equal(t, tc.position, exprErr.Position, tc.source)
+		assert.Equal(t, tc.line, exprErr.Line, tc.source)
+		assert.Equal(t, tc.column, exprErr.Column, tc.source)
+		assert.Contains(t, exprErr.Message, tc.message, tc.source)
+	}
+}
+
+func TestEvaluateRule_Expression(t *testing.T) {
+	rule := models.ComplianceRule{
+		Name:       "Arbitration Seat",
+		Expression: `"arbitration" AND NOT "india"`,
+		Pattern:    "ignored when an expression is set",
+	}
+	eval, err := EvaluateRule(rule, dslContract)
+	require.NoError(t, err)
+	assert.Equal(t, "fail", eval.Status)
+
+	rule.Requirement = RequirementForbidden
+	eval, err = EvaluateRule(rule, dslContract)
+	require.NoError(t, err)
+	assert.Equal(t, "pass", eval.Status)
+}
+
+func TestValidateRuleSyntax(t *testing.T) {
+	s := &DocumentService{}
+	assert.Empty(t, s.ValidateRuleSyntax("any: a, b", `"a" OR "b"`))
+
+	problems := s.ValidateRuleSyntax("regex:(", `"a" OR`)
+	require.Len(t, problems, 2)
+	assert.Equal(t, "pattern", problems[0].Field)
+	assert.Nil(t, problems[0].Position)
+	assert.Equal(t, "expression", problems[1].Field)
+	require.NotNil(t, problems[1].Position)
+	assert.Equal(t, 6, *problems[1].Position)
+}
@@ -0,0 +1,456 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	model "github.com/Itish41/LegalEagle/models"
+)
+
+// Pattern kinds understood by the rule engine.
+const (
+	PatternRegex     = "regex"
+	PatternAny       = "any"
+	PatternAll       = "all"
+	PatternProximity = "proximity"
+)
+
+// Rule requirements. A required pattern must match for the document to pass; a forbidden pattern
+// must not match.
+const (
+	RequirementRequired  = "required"
+	RequirementForbidden = "forbidden"
+)
+
+// Rule evaluation modes selected by RULE_EVALUATION_MODE.
+const (
+	// EvaluationModeLLM asks the LLM to judge every rule.
+	EvaluationModeLLM = "llm"
+	// EvaluationModeLocal judges every rule with the rule engine and never calls the LLM.
+	EvaluationModeLocal = "local"
+	// EvaluationModeHybrid judges rules that have a pattern or expression locally and the rest with the LLM.
+	EvaluationModeHybrid = "hybrid"
+)
+
+// proximityPattern matches "X within N words of Y".
+var proximityPattern = regexp.MustCompile(`(?is)^(.+?)\s+within\s+(\d+)\s+words?\s+of\s+(.+)$`)
+
+// wordPattern splits text into words for proximity matching.
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}]+(?:['’][\p{L}\p{N}]+)*`)
+
+// RuleMatch is a span of the document text that satisfied a pattern. Start and End are byte offsets.
+type RuleMatch struct {
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// RulePattern is a compiled ComplianceRule.Pattern.
+//
+// Supported forms:
+//
+//	regex:<expression>          regular expression
+//	any:<kw1>, <kw2>, ...       at least one keyword or phrase occurs
+//	all:<kw1>, <kw2>, ...       every keyword or phrase occurs
+//	<X> within <N> words of <Y> the phrases occur at most N words apart (optionally "proximity:" prefixed)
+//
+// A pattern without a prefix is a case-insensitive regular expression, which is how patterns were
+// interpreted before the rule engine existed. Keywords match case-insensitively on word boundaries.
+type RulePattern struct {
+	Kind     string
+	Source   string
+	regex    *regexp.Regexp
+	keywords []string
+	near     [2][]string
+	distance int
+}
+
+// PatternResult is the outcome of matching a RulePattern against a text.
+type PatternResult struct {
+	Matched bool
+	Matches []RuleMatch
+	// Missing lists the keywords of an all: pattern that were not found.
+	Missing []string
+}
+
+// CompileRulePattern parses a rule pattern
+func CompileRulePattern(pattern string) (*RulePattern, error) {
+	source := strings.TrimSpace(pattern)
+	if source == "" {
+		return nil, fmt.Errorf("pattern is empty")
+	}
+	kind, body := splitPatternPrefix(source)
+
+	p := &RulePattern{Kind: kind, Source: source}
+	switch kind {
+	case PatternRegex:
+		re, err := regexp.Compile(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		p.regex = re
+	case PatternAny, PatternAll:
+		p.keywords = splitKeywords(body)
+		if len(p.keywords) == 0 {
+			return nil, fmt.Errorf("%s: pattern needs at least one keyword", kind)
+		}
+	case PatternProximity:
+		m := proximityPattern.FindStringSubmatch(body)
+		if m == nil {
+			return nil, fmt.Errorf("proximity pattern must have the form \"X within N words of Y\"")
+		}
+		distance, err := strconv.Atoi(m[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid proximity distance %q", m[2])
+		}
+		p.near = [2][]string{phraseWords(m[1]), phraseWords(m[3])}
+		if len(p.near[0]) == 0 || len(p.near[1]) == 0 {
+			return nil, fmt.Errorf("proximity pattern needs a word on both sides")
+		}
+		p.distance = distance
+	default:
+		// Unprefixed patterns keep their historical meaning of a case-insensitive regex.
+		re, err := regexp.Compile("(?i)" + body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		p.Kind = PatternRegex
+		p.regex = re
+	}
+	return p, nil
+}
+
+// splitPatternPrefix separates a "kind:" prefix from the pattern body
+func splitPatternPrefix(pattern string) (string, string) {
+	if idx := strings.Index(pattern, ":"); idx > 0 {
+		switch kind := strings.ToLower(strings.TrimSpace(pattern[:idx])); kind {
+		case PatternRegex, PatternAny, PatternAll, PatternProximity:
+			return kind, strings.TrimSpace(pattern[idx+1:])
+		}
+	}
+	if proximityPattern.MatchString(pattern) && !strings.ContainsAny(pattern, `\()[]{}|*+?^$`) {
+		return PatternProximity, pattern
+	}
+	return "", pattern
+}
+
+// splitKeywords splits a comma separated keyword list, dropping empty entries
+func splitKeywords(body string) []string {
+	var keywords []string
+	for _, kw := range strings.Split(body, ",") {
+		if kw = strings.TrimSpace(kw); kw != "" {
+			keywords = append(keywords, kw)
+		}
+	}
+	return keywords
+}
+
+// phraseWords lowercases a phrase and splits it into words
+func phraseWords(phrase string) []string {
+	return wordPattern.FindAllString(strings.ToLower(phrase), -1)
+}
+
+// Match evaluates the pattern against text
+func (p *RulePattern) Match(text string) PatternResult {
+	switch p.Kind {
+	case PatternAny:
+		var result PatternResult
+		for _, kw := range p.keywords {
+			result.Matches = append(result.Matches, findKeyword(text, kw)...)
+		}
+		result.Matched = len(result.Matches) > 0
+		return result
+	case PatternAll:
+		var result PatternResult
+		for _, kw := range p.keywords {
+			found := findKeyword(text, kw)
+			if len(found) == 0 {
+				result.Missing = append(result.Missing, kw)
+			}
+			result.Matches = append(result.Matches, found...)
+		}
+		result.Matched = len(result.Missing) == 0
+		return result
+	case PatternProximity:
+		matches := findNear(text, p.near[0], p.near[1], p.distance)
+		return PatternResult{Matched: len(matches) > 0, Matches: matches}
+	default:
+		var result PatternResult
+		for _, loc := range p.regex.FindAllStringIndex(text, -1) {
+			result.Matches = append(result.Matches, RuleMatch{Text: text[loc[0]:loc[1]], Start: loc[0], End: loc[1]})
+		}
+		result.Matched = len(result.Matches) > 0
+		return result
+	}
+}
+
+// findKeyword returns every case-insensitive occurrence of keyword that is not part of a longer word
+func findKeyword(text, keyword string) []RuleMatch {
+	re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(keyword))
+	first, _ := utf8.DecodeRuneInString(keyword)
+	last, _ := utf8.DecodeLastRuneInString(keyword)
+
+	var matches []RuleMatch
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		before, _ := utf8.DecodeLastRuneInString(text[:loc[0]])
+		after, _ := utf8.DecodeRuneInString(text[loc[1]:])
+		if (isWordRune(first) && isWordRune(before)) || (isWordRune(last) && isWordRune(after)) {
+			continue
+		}
+		matches = append(matches, RuleMatch{Text: text[loc[0]:loc[1]], Start: loc[0], End: loc[1]})
+	}
+	return matches
+}
+
+// isWordRune reports whether r is a letter or digit
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// findNear returns spans where phrase a and phrase b occur with at most distance words between them
+func findNear(text string, a, b []string, distance int) []RuleMatch {
+	locs := wordPattern.FindAllStringIndex(text, -1)
+	words := make([]string, len(locs))
+	for i, loc := range locs {
+		words[i] = strings.ToLower(text[loc[0]:loc[1]])
+	}
+
+	aStarts := phraseStarts(words, a)
+	bStarts := phraseStarts(words, b)
+
+	var matches []RuleMatch
+	for _, i := range aStarts {
+		for _, j := range bStarts {
+			firstStart, firstEnd := i, i+len(a)
+			secondStart, secondEnd := j, j+len(b)
+			if j < i {
+				firstStart, firstEnd, secondStart, secondEnd = secondStart, secondEnd, firstStart, firstEnd
+			}
+			if gap := secondStart - firstEnd; gap < 0 || gap > distance {
+				continue
+			}
+			start, end := locs[firstStart][0], locs[secondEnd-1][1]
+			matches = append(matches, RuleMatch{Text: text[start:end], Start: start, End: end})
+		}
+	}
+	return matches
+}
+
+// phraseStarts returns the word indexes at which phrase occurs in words
+func phraseStarts(words, phrase []string) []int {
+	var starts []int
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		match := true
+		for j, w := range phrase {
+			if words[i+j] != w {
+				match = false
+				break
+			}
+		}
+		if match {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+// RuleEvaluation is the verdict of the rule engine for one rule.
+type RuleEvaluation struct {
+	Status      string
+	Matches     []RuleMatch
+	Explanation string
+}
+
+// EvaluateRule judges text against a rule's expression or pattern and its requirement
+func EvaluateRule(rule model.ComplianceRule, text string) (RuleEvaluation, error) {
+	if strings.TrimSpace(rule.Expression) != "" {
+		return evaluateExpressionRule(rule, text)
+	}
+
+	pattern, err := CompileRulePattern(rule.Pattern)
+	if err != nil {
+		return RuleEvaluation{}, fmt.Errorf("rule %q: %w", rule.Name, err)
+	}
+	match := pattern.Match(text)
+
+	eval := RuleEvaluation{Status: "pass", Matches: match.Matches}
+	if rule.Requirement == RequirementForbidden {
+		if match.Matched {
+			eval.Status = "fail"
+			eval.Explanation = fmt.Sprintf("The document violates the '%s' rule: forbidden text %s.", rule.Name, describeMatches(match.Matches))
+		} else {
+			eval.Explanation = fmt.Sprintf("The document complies with the '%s' rule: no text matches forbidden pattern '%s'.", rule.Name, pattern.Source)
+		}
+		return eval, nil
+	}
+
+	if match.Matched {
+		eval.Explanation = fmt.Sprintf("The document complies with the '%s' rule: required text %s.", rule.Name, describeMatches(match.Matches))
+		return eval, nil
+	}
+	eval.Status = "fail"
+	if len(match.Missing) > 0 {
+		eval.Explanation = fmt.Sprintf("The document violates the '%s' rule: missing required terms %s.", rule.Name, quoteList(match.Missing))
+	} else {
+		eval.Explanation = fmt.Sprintf("The document violates the '%s' rule: does not meet the required pattern '%s'.", rule.Name, pattern.Source)
+	}
+	return eval, nil
+}
+
+// evaluateExpressionRule judges text against a rule expression. A required expression must hold and a
+// forbidden one must not.
+func evaluateExpressionRule(rule model.ComplianceRule, text string) (RuleEvaluation, error) {
+	expr, err := ParseRuleExpression(rule.Expression)
+	if err != nil {
+		return RuleEvaluation{}, fmt.Errorf("rule %q: %w", rule.Name, err)
+	}
+	holds, matches := expr.Evaluate(text)
+
+	eval := RuleEvaluation{Status: "pass", Matches: matches}
+	violated := holds == (rule.Requirement == RequirementForbidden)
+	evidence := ""
+	if holds && len(matches) > 0 {
+		evidence = ", " + describeMatches(matches)
+	}
+	switch {
+	case violated && holds:
+		eval.Status = "fail"
+		eval.Explanation = fmt.Sprintf("The document violates the '%s' rule: forbidden expression %s holds%s.", rule.Name, expr.Source, evidence)
+	case violated:
+		eval.Status = "fail"
+		eval.Explanation = fmt.Sprintf("The document violates the '%s' rule: required expression %s does not hold.", rule.Name, expr.Source)
+	case holds:
+		eval.Explanation = fmt.Sprintf("The document complies with the '%s' rule: required expression %s holds%s.", rule.Name, expr.Source, evidence)
+	default:
+		eval.Explanation = fmt.Sprintf("The document complies with the '%s' rule: forbidden expression %s does not hold.", rule.Name, expr.Source)
+	}
+	return eval, nil
+}
+
+// ruleIsLocal reports whether a rule has a pattern or expression the rule engine can evaluate
+func ruleIsLocal(rule model.ComplianceRule) bool {
+	return strings.TrimSpace(rule.Pattern) != "" || strings.TrimSpace(rule.Expression) != ""
+}
+
+// describeMatches summarises where a pattern matched
+func describeMatches(matches []RuleMatch) string {
+	first := matches[0]
+	desc := fmt.Sprintf("'%s' found at offset %d", first.Text, first.Start)
+	if len(matches) > 1 {
+		desc += fmt.Sprintf(" (%d matches)", len(matches))
+	}
+	return desc
+}
+
+// quoteList formats values as a comma separated list of quoted strings
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + v + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// RuleEvaluationModeFromEnv reads RULE_EVALUATION_MODE, defaulting to llm. Operators opt in to
+// hybrid or local evaluation once their rules have patterns the engine can judge.
+func RuleEvaluationModeFromEnv() string {
+	switch mode := strings.ToLower(strings.TrimSpace(os.Getenv("RULE_EVALUATION_MODE"))); mode {
+	case EvaluationModeLLM, EvaluationModeLocal, EvaluationModeHybrid:
+		return mode
+	case "":
+		return EvaluationModeLLM
+	default:
+		log.Printf("Warning: unknown RULE_EVALUATION_MODE %q, using %s", mode, EvaluationModeLLM)
+		return EvaluationModeLLM
+	}
+}
+
+// SetRuleEvaluationMode selects how rules are judged: llm, local or hybrid
+func (s *DocumentService) SetRuleEvaluationMode(mode string) {
+	s.evaluationMode = mode
+}
+
+// ruleEvaluationMode returns the configured evaluation mode, defaulting to llm
+func (s *DocumentService) ruleEvaluationMode() string {
+	if s.evaluationMode == "" {
+		return EvaluationModeLLM
+	}
+	return s.evaluationMode
+}
+
+// ruleResult builds the parsed_data entry recorded for one rule
+func ruleResult(rule model.ComplianceRule, status, explanation, evaluatedBy string) map[string]interface{} {
+	result := map[string]interface{}{
+		"rule_id":       rule.ID,
+		"rule_name":     rule.Name,
+		"severity":      rule.Severity,
+		"rule_revision": rule.Revision,
+		"status":        status,
+		"explanation":   explanation,
+		"evaluated_by":  evaluatedBy,
+	}
+	if rule.CurrentRevisionID != nil {
+		result["rule_revision_id"] = *rule.CurrentRevisionID
+	}
+	return result
+}
+
+// evaluateCompliance judges ocrText against every rule according to the evaluation mode and returns
+// one parsed_data entry per rule; fallback decides what happens when the LLM cannot judge the rules
+// it is asked about
+func (s *DocumentService) evaluateCompliance(ctx context.Context, ocrText string, rules []model.ComplianceRule, fallback llmFallback) ([]map[string]interface{}, error) {
+	mode := s.ruleEvaluationMode()
+
+	// Only the rules the engine cannot judge are sent to the LLM, and none in local mode.
+	var llmRules []model.ComplianceRule
+	for _, rule := range rules {
+		if mode == EvaluationModeLLM || (mode == EvaluationModeHybrid && !ruleIsLocal(rule)) {
+			llmRules = append(llmRules, rule)
+		}
+	}
+
+	var violatedRuleNames []string
+	if len(llmRules) > 0 {
+		var err error
+		violatedRuleNames, err = s.determineViolatedRules(ctx, ocrText, llmRules, fallback)
+		if err != nil {
+			log.Printf("ERROR determining violated rules: %v", err)
+			return nil, err
+		}
+		log.Printf("Violated Rules: %v", violatedRuleNames)
+	}
+
+	results := make([]map[string]interface{}, 0, len(rules))
+	for _, rule := range rules {
+		var result map[string]interface{}
+		if mode == EvaluationModeLLM || !ruleIsLocal(rule) {
+			switch {
+			case mode == EvaluationModeLocal:
+				result = ruleResult(rule, "skipped", fmt.Sprintf("The '%s' rule was not evaluated: it has no pattern or expression, so it can only be judged by the LLM, which local mode does not call.", rule.Name), "rule_engine")
+			case contains(violatedRuleNames, rule.Name):
+				result = ruleResult(rule, "fail", fmt.Sprintf("The document violates the '%s' rule: does not meet the required pattern '%s'.", rule.Name, rule.Pattern), "llm")
+			default:
+				result = ruleResult(rule, "pass", fmt.Sprintf("The document complies with the '%s' rule.", rule.Name), "llm")
+			}
+		} else {
+			eval, err := EvaluateRule(rule, ocrText)
+			if err != nil {
+				log.Printf("ERROR evaluating rule %s locally: %v", rule.Name, err)
+				result = ruleResult(rule, "error", err.Error(), "rule_engine")
+			} else {
+				result = ruleResult(rule, eval.Status, eval.Explanation, "rule_engine")
+				result["matches"] = eval.Matches
+			}
+		}
+		results = append(results, result)
+		log.Printf("Compliance result for %s: %+v", rule.Name, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileRulePattern(t *testing.T) {
+	cases := map[string]string{
+		"regex:Signed\\s+by":                    PatternRegex,
+		"signature.*date":                       PatternRegex,
+		"any: confidential, proprietary":        PatternAny,
+		"ALL:term,termination":                  PatternAll,
+		"indemnify within 5 words of party":     PatternProximity,
+		"proximity: fee within 3 words of late": PatternProximity,
+	}
+	for pattern, kind := range cases {
+		p, err := CompileRulePattern(pattern)
+		require.NoError(t, err, pattern)
+		assert.Equal(t, kind, p.Kind, pattern)
+	}
+
+	for _, pattern := range []string{"", "regex:(", "any: , ", "proximity: fee near late"} {
+		_, err := CompileRulePattern(pattern)
+		assert.Error(t, err, pattern)
+	}
+}
+
+func TestRulePattern_Match(t *testing.T) {
+	text := "This Agreement is CONFIDENTIAL. The Receiving Party shall indemnify the Disclosing Party."
+
+	p, _ := CompileRulePattern("any: confidential, secret")
+	result := p.Match(text)
+	require.True(t, result.Matched)
+	assert.Equal(t, []RuleMatch{{Text: "CONFIDENTIAL", Start: 18, End: 30}}, result.Matches)
+
+	// Keywords do not match inside longer words.
+	p, _ = CompileRulePattern("any: part")
+	assert.False(t, p.Match(text).Matched)
+
+	p, _ = CompileRulePattern("all: agreement, termination, party")
+	result = p.Match(text)
+	assert.False(t, result.Matched)
+	assert.Equal(t, []string{"termination"}, result.Missing)
+
+	p, _ = CompileRulePattern("indemnify within 2 words of disclosing party")
+	result = p.Match(text)
+	require.True(t, result.Matched)
+	assert.Equal(t, "indemnify the Disclosing Party", result.Matches[0].Text)
+	assert.Equal(t, "indemnify the Disclosing Party", text[result.Matches[0].Start:result.Matches[0].End])
+
+	p, _ = CompileRulePattern("receiving within 1 words of indemnify")
+	assert.False(t, p.Match(text).Matched)
+}
+
+func TestEvaluateRule_Requirement(t *testing.T) {
+	text := "Either party may terminate this agreement. Payment is due within 30 days."
+
+	required := models.ComplianceRule{Name: "Termination Clause", Pattern: "any: terminate, termination"}
+	eval, err := EvaluateRule(required, text)
+	require.NoError(t, err)
+	assert.Equal(t, "pass", eval.Status)
+	assert.Contains(t, eval.Explanation, "'terminate' found at offset 17")
+
+	forbidden := models.ComplianceRule{Name: "No Auto Renewal", Pattern: "any: automatically renew", Requirement: RequirementForbidden}
+	eval, err = EvaluateRule(forbidden, text)
+	require.NoError(t, err)
+	assert.Equal(t, "pass", eval.Status)
+
+	forbidden.Pattern = "regex:within \\d+ days"
+	eval, err = EvaluateRule(forbidden, text)
+	require.NoError(t, err)
+	assert.Equal(t, "fail", eval.Status)
+	assert.Equal(t, []RuleMatch{{Text: "within 30 days", Start: 58, End: 72}}, eval.Matches)
+}
+
+func TestEvaluateCompliance_LocalModeSkipsLLM(t *testing.T) {
+	stub := NewStubLLMClient(nil)
+	s := &DocumentService{llm: stub, evaluationMode: EvaluationModeLocal}
+
+	rules := []models.ComplianceRule{
+		{ID: "r1", Name: "Signature", Severity: "high", Pattern: "signed by"},
+		{ID: "r2", Name: "Governing Law", Severity: "low", Pattern: "all: governing law, state of"},
+		{ID: "r3", Name: "Free Text", Severity: "low"},
+	}
+	results, err := s.evaluateCompliance(context.Background(), "Signed by both parties.", rules, withLLMFallback)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, "pass", results[0]["status"])
+	assert.Equal(t, "fail", results[1]["status"])
+	assert.Equal(t, "skipped", results[2]["status"])
+	assert.Contains(t, results[2]["explanation"], "no pattern or expression")
+	assert.Equal(t, "rule_engine", results[2]["evaluated_by"])
+	assert.Empty(t, stub.Requests)
+}
+
+func TestRuleEvaluationModeFromEnv(t *testing.T) {
+	t.Setenv("RULE_EVALUATION_MODE", "")
+	assert.Equal(t, EvaluationModeLLM, RuleEvaluationModeFromEnv())
+	assert.Equal(t, EvaluationModeLLM, (&DocumentService{}).ruleEvaluationMode())
+
+	t.Setenv("RULE_EVALUATION_MODE", " Hybrid ")
+	assert.Equal(t, EvaluationModeHybrid, RuleEvaluationModeFromEnv())
+
+	t.Setenv("RULE_EVALUATION_MODE", "magic")
+	assert.Equal(t, EvaluationModeLLM, RuleEvaluationModeFromEnv())
+}
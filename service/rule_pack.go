@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"github.com/lib/pq"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// RulePackFormat identifies the rule pack bundle format. Bumped whenever fields change incompatibly.
+const RulePackFormat = "legaleagle.rulepack/v1"
+
+// ErrInvalidRulePack is returned when a rule pack cannot be parsed or contains invalid rules.
+var ErrInvalidRulePack = errors.New("invalid rule pack")
+
+// RulePack is a portable bundle of compliance rules.
+type RulePack struct {
+	Format      string         `json:"format" yaml:"format"`
+	Name        string         `json:"name,omitempty" yaml:"name,omitempty"`
+	Description string         `json:"description,omitempty" yaml:"description,omitempty"`
+	ExportedAt  *time.Time     `json:"exported_at,omitempty" yaml:"exported_at,omitempty"`
+	Rules       []RulePackRule `json:"rules" yaml:"rules"`
+}
+
+// RulePackRule is one rule of a rule pack. Version is the revision the rule had when exported.
+type RulePackRule struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Pattern     string   `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Expression  string   `json:"expression,omitempty" yaml:"expression,omitempty"`
+	Severity    string   `json:"severity" yaml:"severity"`
+	Requirement string   `json:"requirement,omitempty" yaml:"requirement,omitempty"`
+	Tags        []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Version     int      `json:"version,omitempty" yaml:"version,omitempty"`
+
+	Categories    []string `json:"categories,omitempty" yaml:"categories,omitempty"`
+	Jurisdictions []string `json:"jurisdictions,omitempty" yaml:"jurisdictions,omitempty"`
+	FileTypes     []string `json:"file_types,omitempty" yaml:"file_types,omitempty"`
+	DocumentTags  []string `json:"document_tags,omitempty" yaml:"document_tags,omitempty"`
+}
+
+// RuleFieldChange is the old and new value of a rule field changed by an import.
+type RuleFieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// RulePackUpdate describes an existing rule an import changes. Stale is set when the rule was
+// revised after the version the pack was exported from.
+type RulePackUpdate struct {
+	Name            string                     `json:"name"`
+	RuleID          string                     `json:"rule_id"`
+	CurrentRevision int                        `json:"current_revision"`
+	PackVersion     int                        `json:"pack_version,omitempty"`
+	Stale           bool                       `json:"stale,omitempty"`
+	Changes         map[string]RuleFieldChange `json:"changes"`
+}
+
+// RulePackImportResult lists what an import created, updated and left alone.
+type RulePackImportResult struct {
+	DryRun    bool             `json:"dry_run"`
+	Created   []string         `json:"created"`
+	Updated   []RulePackUpdate `json:"updated"`
+	Unchanged []string         `json:"unchanged"`
+}
+
+// ParseRulePack decodes a rule pack. Bodies starting with '{' are read as JSON, anything else as YAML.
+// Unknown fields are rejected so typos do not silently drop settings.
+func ParseRulePack(data []byte) (*RulePack, error) {
+	var pack RulePack
+	var err error
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&pack)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&pack)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRulePack, err)
+	}
+	if pack.Format != RulePackFormat {
+		return nil, fmt.Errorf("%w: unsupported format %q, expected %q", ErrInvalidRulePack, pack.Format, RulePackFormat)
+	}
+	return &pack, nil
+}
+
+// MarshalRulePack encodes a rule pack as "yaml" or "json"
+func MarshalRulePack(pack *RulePack, format string) ([]byte, error) {
+	if format == "json" {
+		return json.MarshalIndent(pack, "", "  ")
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(pack); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// rulePackRule converts a stored rule to its pack representation
+func rulePackRule(rule model.ComplianceRule) RulePackRule {
+	return RulePackRule{
+		Name:        rule.Name,
+		Description: rule.Description,
+		Pattern:     rule.Pattern,
+		Expression:  rule.Expression,
+		Severity:    rule.Severity,
+		Requirement: rule.Requirement,
+		Tags:        append([]string(nil), rule.Tags...),
+		Version:     rule.Revision,
+
+		Categories:    append([]string(nil), rule.Categories...),
+		Jurisdictions: append([]string(nil), rule.Jurisdictions...),
+		FileTypes:     append([]string(nil), rule.FileTypes...),
+		DocumentTags:  append([]string(nil), rule.DocumentTags...),
+	}
+}
+
+// applyPackRule copies the fields of a pack rule onto rule
+func applyPackRule(rule *model.ComplianceRule, packRule RulePackRule) {
+	rule.Name = packRule.Name
+	rule.Description = packRule.Description
+	rule.Pattern = packRule.Pattern
+	rule.Expression = packRule.Expression
+	rule.Severity = packRule.Severity
+	rule.Requirement = packRule.Requirement
+	rule.Tags = packRule.Tags
+	rule.Categories = packRule.Categories
+	rule.Jurisdictions = packRule.Jurisdictions
+	rule.FileTypes = packRule.FileTypes
+	rule.DocumentTags = packRule.DocumentTags
+}
+
+// ExportRulePack bundles the active rules, optionally only those carrying any of tags
+func (s *DocumentService) ExportRulePack(name string, tags []string) (*RulePack, error) {
+	query := s.db.Order("name")
+	if tags = normalizeTags(tags); len(tags) > 0 {
+		query = query.Where("tags && ?", pq.Array(tags))
+	}
+	var rules []model.ComplianceRule
+	if err := query.Find(&rules).Error; err != nil {
+		log.Printf("[ExportRulePack] Error fetching rules: %v", err)
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	pack := &RulePack{Format: RulePackFormat, Name: name, ExportedAt: &now, Rules: make([]RulePackRule, 0, len(rules))}
+	for _, rule := range rules {
+		pack.Rules = append(pack.Rules, rulePackRule(rule))
+	}
+	log.Printf("[ExportRulePack] Exported %d rules", len(pack.Rules))
+	return pack, nil
+}
+
+// ImportRulePack creates or updates the rules of pack, matching existing rules by name. With dryRun
+// set nothing is written and the result describes what would happen. Rules missing from the pack are
+// left alone. A real import applies all changes in one transaction.
+func (s *DocumentService) ImportRulePack(pack *RulePack, dryRun bool, actor string) (*RulePackImportResult, error) {
+	// Validate every rule up front so a bad pack is rejected as a whole.
+	seen := make(map[string]bool, len(pack.Rules))
+	var problems []string
+	for i := range pack.Rules {
+		candidate := model.ComplianceRule{}
+		applyPackRule(&candidate, pack.Rules[i])
+		if err := validateComplianceRule(&candidate); err != nil {
+			problems = append(problems, fmt.Sprintf("rule %d (%s): %v", i+1, pack.Rules[i].Name, err))
+			continue
+		}
+		if seen[candidate.Name] {
+			problems = append(problems, fmt.Sprintf("rule %d (%s): duplicate name in pack", i+1, candidate.Name))
+			continue
+		}
+		seen[candidate.Name] = true
+		// Keep the normalised values so diffs compare like with like.
+		version := pack.Rules[i].Version
+		pack.Rules[i] = rulePackRule(candidate)
+		pack.Rules[i].Version = version
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRulePack, strings.Join(problems, "; "))
+	}
+
+	result := &RulePackImportResult{DryRun: dryRun, Created: []string{}, Updated: []RulePackUpdate{}, Unchanged: []string{}}
+	apply := func(tx *gorm.DB) error {
+		for _, packRule := range pack.Rules {
+			var existing model.ComplianceRule
+			err := tx.Where("name = ?", packRule.Name).First(&existing).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				result.Created = append(result.Created, packRule.Name)
+				if dryRun {
+					continue
+				}
+				rule := model.ComplianceRule{}
+				applyPackRule(&rule, packRule)
+				if err := validateComplianceRule(&rule); err != nil {
+					return err
+				}
+				if err := createRule(tx, &rule, actor); err != nil {
+					return fmt.Errorf("failed to create rule %s: %w", packRule.Name, err)
+				}
+				continue
+			} else if err != nil {
+				return fmt.Errorf("failed to look up rule %s: %w", packRule.Name, err)
+			}
+
+			changes := diffPackRule(rulePackRule(existing), packRule)
+			if len(changes) == 0 {
+				result.Unchanged = append(result.Unchanged, packRule.Name)
+				continue
+			}
+			result.Updated = append(result.Updated, RulePackUpdate{
+				Name:            packRule.Name,
+				RuleID:          existing.ID,
+				CurrentRevision: existing.Revision,
+				PackVersion:     packRule.Version,
+				Stale:           packRule.Version > 0 && packRule.Version < existing.Revision,
+				Changes:         changes,
+			})
+			if dryRun {
+				continue
+			}
+			if _, err := updateRule(tx, existing.ID, actor, func(rule *model.ComplianceRule) {
+				applyPackRule(rule, packRule)
+			}); err != nil {
+				return fmt.Errorf("failed to update rule %s: %w", packRule.Name, err)
+			}
+		}
+		return nil
+	}
+
+	var err error
+	if dryRun {
+		err = apply(s.db)
+	} else {
+		err = s.db.Transaction(apply)
+	}
+	if err != nil {
+		log.Printf("[ImportRulePack] Error importing rule pack: %v", err)
+		return nil, err
+	}
+	log.Printf("[ImportRulePack] dry_run=%v created=%d updated=%d unchanged=%d", dryRun, len(result.Created), len(result.Updated), len(result.Unchanged))
+	return result, nil
+}
+
+// diffPackRule returns the fields that differ between an existing rule and its pack version
+func diffPackRule(existing, incoming RulePackRule) map[string]RuleFieldChange {
+	changes := make(map[string]RuleFieldChange)
+	compare := func(field string, from, to string) {
+		if from != to {
+			changes[field] = RuleFieldChange{From: from, To: to}
+		}
+	}
+	compare("description", existing.Description, incoming.Description)
+	compare("pattern", existing.Pattern, incoming.Pattern)
+	compare("expression", existing.Expression, incoming.Expression)
+	compare("severity", existing.Severity, incoming.Severity)
+	compare("requirement", existing.Requirement, incoming.Requirement)
+
+	compareList := func(field string, from, to []string) {
+		from = append([]string{}, from...)
+		to = append([]string{}, to...)
+		sort.Strings(from)
+		sort.Strings(to)
+		if strings.Join(from, ",") != strings.Join(to, ",") {
+			changes[field] = RuleFieldChange{From: from, To: to}
+		}
+	}
+	compareList("tags", existing.Tags, incoming.Tags)
+	compareList("categories", existing.Categories, incoming.Categories)
+	compareList("jurisdictions", existing.Jurisdictions, incoming.Jurisdictions)
+	compareList("file_types", existing.FileTypes, incoming.FileTypes)
+	compareList("document_tags", existing.DocumentTags, incoming.DocumentTags)
+	return changes
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRulePack_YAMLAndJSON(t *testing.T) {
+	yamlPack := `
+format: legaleagle.rulepack/v1
+name: Vendor contracts
+rules:
+  - name: Governing Law
+    pattern: "any: governing law"
+    severity: high
+    tags: [contracts, india]
+    version: 3
+`
+	pack, err := ParseRulePack([]byte(yamlPack))
+	require.NoError(t, err)
+	assert.Equal(t, "Vendor contracts", pack.Name)
+	require.Len(t, pack.Rules, 1)
+	assert.Equal(t, RulePackRule{Name: "Governing Law", Pattern: "any: governing law", Severity: "high", Tags: []string{"contracts", "india"}, Version: 3}, pack.Rules[0])
+
+	jsonPack := "{\n\t\"format\": \"legaleagle.rulepack/v1\",\n\t\"rules\": [{\"name\": \"Governing Law\", \"severity\": \"high\"}]\n}"
+	pack, err = ParseRulePack([]byte(jsonPack))
+	require.NoError(t, err)
+	require.Len(t, pack.Rules, 1)
+	assert.Equal(t, "Governing Law", pack.Rules[0].Name)
+}
+
+func TestParseRulePack_Rejects(t *testing.T) {
+	cases := map[string]string{
+		"wrong format":  "format: other/v1\nrules: []\n",
+		"unknown field": "format: legaleagle.rulepack/v1\nrules:\n  - name: A\n    severity: low\n    severty: high\n",
+		"broken json":   `{"format": "legaleagle.rulepack/v1", "rules": [`,
+	}
+	for name, body := range cases {
+		_, err := ParseRulePack([]byte(body))
+		assert.ErrorIs(t, err, ErrInvalidRulePack, name)
+	}
+}
+
+func TestMarshalRulePack_RoundTrip(t *testing.T) {
+	pack := &RulePack{Format: RulePackFormat, Name: "Pack", Rules: []RulePackRule{
+		{Name: "Signature", Expression: `"signed by" AND NOT "draft"`, Severity: "medium", Requirement: "required", Tags: []string{"nda"}, Version: 2},
+	}}
+	for _, format := range []string{"yaml", "json"} {
+		data, err := MarshalRulePack(pack, format)
+		require.NoError(t, err, format)
+		parsed, err := ParseRulePack(data)
+		require.NoError(t, err, format)
+		assert.Equal(t, pack.Rules, parsed.Rules, format)
+	}
+}
+
+func TestImportRulePack_ValidatesWholePack(t *testing.T) {
+	s := &DocumentService{}
+	pack := &RulePack{Format: RulePackFormat, Rules: []RulePackRule{
+		{Name: "Good", Severity: "low"},
+		{Name: "Bad severity", Severity: "critical"},
+		{Name: "Good", Severity: "high"},
+	}}
+
+	_, err := s.ImportRulePack(pack, true, "tester")
+	require.ErrorIs(t, err, ErrInvalidRulePack)
+	assert.Contains(t, err.Error(), "rule 2 (Bad severity)")
+	assert.Contains(t, err.Error(), "rule 3 (Good): duplicate name in pack")
+}
+
+func TestDiffPackRule(t *testing.T) {
+	existing := RulePackRule{Name: "A", Pattern: "signed", Severity: "low", Requirement: "required", Tags: []string{"b", "a"}}
+
+	same := existing
+	same.Tags = []string{"a", "b"}
+	assert.Empty(t, diffPackRule(existing, same))
+
+	changed := existing
+	changed.Severity = "high"
+	changed.Tags = []string{"a"}
+	changes := diffPackRule(existing, changed)
+	assert.Equal(t, RuleFieldChange{From: "low", To: "high"}, changes["severity"])
+	assert.Equal(t, RuleFieldChange{From: []string{"a", "b"}, To: []string{"a"}}, changes["tags"])
+	assert.Len(t, changes, 2)
+}
+
+func TestExportRulePack_TagsAreBoundAsArray(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	s := &DocumentService{db: db}
+
+	_, err := s.ExportRulePack("pack", []string{"India", `uk "north", east`})
+	require.NoError(t, err)
+
+	queries := fake.executed(`tags && \$1`)
+	require.Len(t, queries, 1)
+	value, err := queries[0].Args[0].(driver.Valuer).Value()
+	require.NoError(t, err)
+	assert.Equal(t, `{"india","uk \"north\", east"}`, value)
+}
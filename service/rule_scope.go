@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	model "github.com/Itish41/LegalEagle/models"
+)
+
+// DocumentScope is the document metadata that decides which rules apply to a document.
+//
+// A rule limited to categories, jurisdictions or file types is skipped only when the document's value
+// is known and not listed; an unknown value never excludes a rule, so an unclassified document is
+// still checked against everything. A rule limited to document tags needs at least one of them.
+type DocumentScope struct {
+	Category     string   `json:"category,omitempty"`
+	Jurisdiction string   `json:"jurisdiction,omitempty"`
+	FileType     string   `json:"file_type,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+}
+
+// normalize lowercases and trims every field in place
+func (scope *DocumentScope) normalize() {
+	scope.Category = strings.ToLower(strings.TrimSpace(scope.Category))
+	scope.Jurisdiction = strings.ToLower(strings.TrimSpace(scope.Jurisdiction))
+	scope.FileType = normalizeFileType(scope.FileType)
+	scope.Tags = normalizeTags(scope.Tags)
+}
+
+// documentScope returns the scope of a stored document. A classified category below the minimum
+// confidence is treated as unknown.
+func (s *DocumentService) documentScope(doc model.Document) DocumentScope {
+	scope := DocumentScope{
+		Category:     doc.Category,
+		Jurisdiction: doc.Jurisdiction,
+		FileType:     doc.FileType,
+		Tags:         doc.Tags,
+	}
+	if doc.CategorySource != CategorySourceUpload && doc.CategoryConfidence < s.classifierMinConfidence() {
+		scope.Category = ""
+	}
+	scope.normalize()
+	return scope
+}
+
+// fileTypeOf returns the normalised extension of filename
+func fileTypeOf(filename string) string {
+	return normalizeFileType(filepath.Ext(filename))
+}
+
+// normalizeFileType lowercases a file type and strips a leading dot, so ".PDF" becomes "pdf"
+func normalizeFileType(fileType string) string {
+	return strings.TrimLeft(strings.ToLower(strings.TrimSpace(fileType)), ".")
+}
+
+// normalizeFileTypes normalises file types like normalizeTags
+func normalizeFileTypes(fileTypes []string) []string {
+	normalized := make([]string, 0, len(fileTypes))
+	for _, fileType := range fileTypes {
+		normalized = append(normalized, normalizeFileType(fileType))
+	}
+	return normalizeTags(normalized)
+}
+
+// sameRuleScope reports whether two rules have identical scope lists
+func sameRuleScope(a, b *model.ComplianceRule) bool {
+	return strings.Join(a.Categories, ",") == strings.Join(b.Categories, ",") &&
+		strings.Join(a.Jurisdictions, ",") == strings.Join(b.Jurisdictions, ",") &&
+		strings.Join(a.FileTypes, ",") == strings.Join(b.FileTypes, ",") &&
+		strings.Join(a.DocumentTags, ",") == strings.Join(b.DocumentTags, ",")
+}
+
+// ruleScopeMismatch explains why rule does not apply to a document with scope, or returns "" if it does
+func ruleScopeMismatch(rule model.ComplianceRule, scope DocumentScope) string {
+	if len(rule.Categories) > 0 && scope.Category != "" && !contains(rule.Categories, scope.Category) {
+		return fmt.Sprintf("applies only to categories %s; document category is '%s'", quoteList(rule.Categories), scope.Category)
+	}
+	if len(rule.Jurisdictions) > 0 && scope.Jurisdiction != "" && !contains(rule.Jurisdictions, scope.Jurisdiction) {
+		return fmt.Sprintf("applies only to jurisdictions %s; document jurisdiction is '%s'", quoteList(rule.Jurisdictions), scope.Jurisdiction)
+	}
+	if len(rule.FileTypes) > 0 && scope.FileType != "" && !contains(rule.FileTypes, scope.FileType) {
+		return fmt.Sprintf("applies only to file types %s; document file type is '%s'", quoteList(rule.FileTypes), scope.FileType)
+	}
+	if len(rule.DocumentTags) > 0 {
+		for _, tag := range scope.Tags {
+			if contains(rule.DocumentTags, tag) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("applies only to documents tagged %s", quoteList(rule.DocumentTags))
+	}
+	return ""
+}
+
+// scopeRules splits rules into those that apply to a document with scope and "skipped" results
+// explaining why the others were not evaluated
+func scopeRules(rules []model.ComplianceRule, scope DocumentScope) ([]model.ComplianceRule, []map[string]interface{}) {
+	applicable := make([]model.ComplianceRule, 0, len(rules))
+	var skipped []map[string]interface{}
+	for _, rule := range rules {
+		if reason := ruleScopeMismatch(rule, scope); reason != "" {
+			skipped = append(skipped, ruleResult(rule, "skipped", fmt.Sprintf("The '%s' rule was not evaluated: it %s.", rule.Name, reason), "scope"))
+			continue
+		}
+		applicable = append(applicable, rule)
+	}
+	return applicable, skipped
+}
+
+// evaluateScopedCompliance evaluates the rules that apply to a document with scope and adds a
+// "skipped" entry for each rule outside it
+func (s *DocumentService) evaluateScopedCompliance(ctx context.Context, ocrText string, rules []model.ComplianceRule, scope DocumentScope, fallback llmFallback) ([]map[string]interface{}, error) {
+	applicable, skipped := scopeRules(rules, scope)
+	if len(skipped) > 0 {
+		log.Printf("Skipping %d of %d rules outside the document scope %+v", len(skipped), len(rules), scope)
+	}
+	results, err := s.evaluateCompliance(ctx, ocrText, applicable, fallback)
+	if err != nil {
+		return nil, err
+	}
+	return append(results, skipped...), nil
+}
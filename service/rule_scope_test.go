@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleScopeMismatch(t *testing.T) {
+	ndaOnly := models.ComplianceRule{Name: "Confidentiality", Categories: []string{"nda"}}
+	indianPDFs := models.ComplianceRule{Name: "Stamp Duty", Jurisdictions: []string{"india"}, FileTypes: []string{"pdf"}}
+	tagged := models.ComplianceRule{Name: "Vendor Terms", DocumentTags: []string{"vendor", "procurement"}}
+
+	cases := []struct {
+		rule    models.ComplianceRule
+		scope   DocumentScope
+		skipped bool
+	}{
+		{ndaOnly, DocumentScope{Category: "nda"}, false},
+		{ndaOnly, DocumentScope{Category: "invoice"}, true},
+		{ndaOnly, DocumentScope{}, false}, // unknown category does not exclude
+		{indianPDFs, DocumentScope{Jurisdiction: "india", FileType: "pdf"}, false},
+		{indianPDFs, DocumentScope{Jurisdiction: "uk", FileType: "pdf"}, true},
+		{indianPDFs, DocumentScope{Jurisdiction: "india", FileType: "docx"}, true},
+		{tagged, DocumentScope{Tags: []string{"procurement"}}, false},
+		{tagged, DocumentScope{}, true},
+		{models.ComplianceRule{Name: "Everywhere"}, DocumentScope{Category: "lease"}, false},
+	}
+	for _, c := range cases {
+		reason := ruleScopeMismatch(c.rule, c.scope)
+		assert.Equal(t, c.skipped, reason != "", "%s %+v: %s", c.rule.Name, c.scope, reason)
+	}
+	assert.Equal(t, "applies only to categories 'nda'; document category is 'invoice'",
+		ruleScopeMismatch(ndaOnly, DocumentScope{Category: "invoice"}))
+}
+
+func TestEvaluateScopedCompliance_SkipsOutOfScopeRules(t *testing.T) {
+	s := &DocumentService{evaluationMode: EvaluationModeLocal}
+	rules := []models.ComplianceRule{
+		{ID: "r1", Name: "Confidentiality", Severity: "high", Pattern: "any: confidential", Categories: []string{"nda"}},
+		{ID: "r2", Name: "Payment Terms", Severity: "medium", Pattern: "any: payment due", Categories: []string{"invoice"}},
+	}
+
+	results, err := s.evaluateScopedCompliance(context.Background(), "Payment due within 30 days.", rules, DocumentScope{Category: "invoice"}, withLLMFallback)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "Payment Terms", results[0]["rule_name"])
+	assert.Equal(t, "pass", results[0]["status"])
+	assert.Equal(t, "Confidentiality", results[1]["rule_name"])
+	assert.Equal(t, "skipped", results[1]["status"])
+	assert.Equal(t, "scope", results[1]["evaluated_by"])
+	assert.Contains(t, results[1]["explanation"], "applies only to categories 'nda'")
+
+	// Skipped rules never add to the risk score.
+	assert.Equal(t, 0.0, calculateRiskScore(results, rules))
+}
+
+func TestCalculateRiskScore_ErroredRulesCountAsFailing(t *testing.T) {
+	rules := []models.ComplianceRule{
+		{Name: "Signature", Severity: "high"},
+		{Name: "Governing Law", Severity: "medium"},
+		{Name: "Payment Terms", Severity: "low"},
+	}
+	results := []map[string]interface{}{
+		{"rule_name": "Signature", "status": "fail"},
+		{"rule_name": "Governing Law", "status": "error"},
+		{"rule_name": "Payment Terms", "status": "pass"},
+	}
+	assert.Equal(t, 5.0, calculateRiskScore(results, rules))
+
+	summary := map[string]interface{}{}
+	parsedData, err := json.Marshal(results)
+	require.NoError(t, err)
+	addComplianceSummary(summary, parsedData)
+	assert.Equal(t, []string{"Signature", "Governing Law"}, summary["failing_rules"], "the summary agrees with the score")
+}
+
+func TestValidateComplianceRule_NormalisesScope(t *testing.T) {
+	rule := &models.ComplianceRule{Name: "Scoped", Severity: "low",
+		Categories: []string{" NDA ", "nda"}, FileTypes: []string{".PDF", "docx"}, Jurisdictions: []string{"India"}}
+	require.NoError(t, validateComplianceRule(rule))
+	assert.Equal(t, []string{"nda"}, []string(rule.Categories))
+	assert.Equal(t, []string{"pdf", "docx"}, []string(rule.FileTypes))
+	assert.Equal(t, []string{"india"}, []string(rule.Jurisdictions))
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidRule is returned when a compliance rule fails validation.
+var ErrInvalidRule = errors.New("invalid compliance rule")
+
+// ErrRuleNameTaken is returned when another active rule already uses the requested name.
+var ErrRuleNameTaken = errors.New("a compliance rule with this name already exists")
+
+// validSeverities lists the severities understood by CalculateRiskScore.
+var validSeverities = map[string]bool{"low": true, "medium": true, "high": true}
+
+// RulePatch holds the fields of a partial rule update; nil fields are left unchanged.
+type RulePatch struct {
+	Name        *string   `json:"name"`
+	Description *string   `json:"description"`
+	Pattern     *string   `json:"pattern"`
+	Severity    *string   `json:"severity"`
+	Requirement *string   `json:"requirement"`
+	Expression  *string   `json:"expression"`
+	Tags        *[]string `json:"tags"`
+
+	Categories    *[]string `json:"categories"`
+	Jurisdictions *[]string `json:"jurisdictions"`
+	FileTypes     *[]string `json:"file_types"`
+	DocumentTags  *[]string `json:"document_tags"`
+}
+
+// validateComplianceRule normalises rule in place and checks its name, severity, requirement, pattern
+// and expression
+func validateComplianceRule(rule *model.ComplianceRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Severity = strings.ToLower(strings.TrimSpace(rule.Severity))
+	rule.Requirement = strings.ToLower(strings.TrimSpace(rule.Requirement))
+	if rule.Requirement == "" {
+		rule.Requirement = RequirementRequired
+	}
+
+	if rule.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	if !validSeverities[rule.Severity] {
+		return fmt.Errorf("%w: severity must be one of low, medium, high", ErrInvalidRule)
+	}
+	if rule.Requirement != RequirementRequired && rule.Requirement != RequirementForbidden {
+		return fmt.Errorf("%w: requirement must be required or forbidden", ErrInvalidRule)
+	}
+	// Rules without a pattern or expression are left to the LLM.
+	if strings.TrimSpace(rule.Pattern) != "" {
+		if _, err := CompileRulePattern(rule.Pattern); err != nil {
+			return fmt.Errorf("%w: pattern: %v", ErrInvalidRule, err)
+		}
+	}
+	rule.Tags = normalizeTags(rule.Tags)
+	rule.Categories = normalizeTags(rule.Categories)
+	rule.Jurisdictions = normalizeTags(rule.Jurisdictions)
+	rule.FileTypes = normalizeFileTypes(rule.FileTypes)
+	rule.DocumentTags = normalizeTags(rule.DocumentTags)
+	rule.Expression = strings.TrimSpace(rule.Expression)
+	if rule.Expression != "" {
+		if _, err := ParseRuleExpression(rule.Expression); err != nil {
+			return fmt.Errorf("%w: expression: %v", ErrInvalidRule, err)
+		}
+	}
+	return nil
+}
+
+// normalizeTags lowercases and trims tags and drops empty and duplicate entries
+func normalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			normalized = append(normalized, tag)
+		}
+	}
+	return removeDuplicates(normalized)
+}
+
+// ensureRuleNameAvailable fails if an active rule other than excludeID already uses name
+func ensureRuleNameAvailable(db *gorm.DB, name, excludeID string) error {
+	query := db.Model(&model.ComplianceRule{}).Where("name = ?", name)
+	if excludeID != "" {
+		query = query.Where("id <> ?", excludeID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRuleNameTaken
+	}
+	return nil
+}
+
+// recordRuleAudit writes an audit entry for a rule change using db, which may be a transaction
+func recordRuleAudit(db *gorm.DB, ruleID, action, actor string, before, after *model.ComplianceRule) error {
+	entry := model.RuleAuditLog{RuleID: ruleID, Action: action, Actor: actor}
+	if before != nil {
+		data, err := json.Marshal(before)
+		if err != nil {
+			return err
+		}
+		entry.Before = datatypes.JSON(data)
+	}
+	if after != nil {
+		data, err := json.Marshal(after)
+		if err != nil {
+			return err
+		}
+		entry.After = datatypes.JSON(data)
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		return fmt.Errorf("failed to record rule audit: %w", err)
+	}
+	return nil
+}
+
+// createRuleRevision snapshots the current fields of rule as its next revision and points the rule
+// at it. The caller saves the rule's CurrentRevisionID and Revision.
+func createRuleRevision(db *gorm.DB, rule *model.ComplianceRule, actor string) error {
+	revision := model.ComplianceRuleRevision{
+		RuleID:        rule.ID,
+		Revision:      rule.Revision + 1,
+		Name:          rule.Name,
+		Description:   rule.Description,
+		Pattern:       rule.Pattern,
+		Severity:      rule.Severity,
+		Requirement:   rule.Requirement,
+		Expression:    rule.Expression,
+		Tags:          append([]string{}, rule.Tags...),
+		Categories:    append([]string{}, rule.Categories...),
+		Jurisdictions: append([]string{}, rule.Jurisdictions...),
+		FileTypes:     append([]string{}, rule.FileTypes...),
+		DocumentTags:  append([]string{}, rule.DocumentTags...),
+		CreatedBy:     actor,
+	}
+	if err := db.Create(&revision).Error; err != nil {
+		return fmt.Errorf("failed to create rule revision: %w", err)
+	}
+	rule.CurrentRevisionID = &revision.ID
+	rule.Revision = revision.Revision
+	return nil
+}
+
+// ruleContentChanged reports whether two rules differ in any revisioned field
+func ruleContentChanged(a, b *model.ComplianceRule) bool {
+	return a.Name != b.Name || a.Description != b.Description || a.Pattern != b.Pattern || a.Severity != b.Severity ||
+		a.Requirement != b.Requirement || a.Expression != b.Expression ||
+		strings.Join(a.Tags, ",") != strings.Join(b.Tags, ",") || !sameRuleScope(a, b)
+}
+
+// GetComplianceRule retrieves a single active compliance rule
+func (s *DocumentService) GetComplianceRule(id string) (*model.ComplianceRule, error) {
+	var rule model.ComplianceRule
+	if err := s.db.First(&rule, "id = ?", id).Error; err != nil {
+		log.Printf("[GetComplianceRule] Error fetching rule %s: %v", id, err)
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// UpdateComplianceRule replaces the editable fields of a rule with those of update
+func (s *DocumentService) UpdateComplianceRule(id string, update model.ComplianceRule, actor string) (*model.ComplianceRule, error) {
+	return s.modifyComplianceRule(id, actor, func(rule *model.ComplianceRule) {
+		rule.Name = update.Name
+		rule.Description = update.Description
+		rule.Pattern = update.Pattern
+		rule.Severity = update.Severity
+		rule.Requirement = update.Requirement
+		rule.Expression = update.Expression
+		rule.Tags = update.Tags
+		rule.Categories = update.Categories
+		rule.Jurisdictions = update.Jurisdictions
+		rule.FileTypes = update.FileTypes
+		rule.DocumentTags = update.DocumentTags
+	})
+}
+
+// PatchComplianceRule changes only the fields set in patch
+func (s *DocumentService) PatchComplianceRule(id string, patch RulePatch, actor string) (*model.ComplianceRule, error) {
+	return s.modifyComplianceRule(id, actor, func(rule *model.ComplianceRule) {
+		if patch.Name != nil {
+			rule.Name = *patch.Name
+		}
+		if patch.Description != nil {
+			rule.Description = *patch.Description
+		}
+		if patch.Pattern != nil {
+			rule.Pattern = *patch.Pattern
+		}
+		if patch.Severity != nil {
+			rule.Severity = *patch.Severity
+		}
+		if patch.Requirement != nil {
+			rule.Requirement = *patch.Requirement
+		}
+		if patch.Expression != nil {
+			rule.Expression = *patch.Expression
+		}
+		if patch.Tags != nil {
+			rule.Tags = *patch.Tags
+		}
+		if patch.Categories != nil {
+			rule.Categories = *patch.Categories
+		}
+		if patch.Jurisdictions != nil {
+			rule.Jurisdictions = *patch.Jurisdictions
+		}
+		if patch.FileTypes != nil {
+			rule.FileTypes = *patch.FileTypes
+		}
+		if patch.DocumentTags != nil {
+			rule.DocumentTags = *patch.DocumentTags
+		}
+	})
+}
+
+// ruleContentColumns are the columns copied into every rule revision.
+var ruleContentColumns = []string{"name", "description", "pattern", "severity", "requirement", "expression", "tags",
+	"categories", "jurisdictions", "file_types", "document_tags"}
+
+// createRule stores a validated rule with its first revision and an audit entry using tx
+func createRule(tx *gorm.DB, rule *model.ComplianceRule, actor string) error {
+	if err := ensureRuleNameAvailable(tx, rule.Name, ""); err != nil {
+		return err
+	}
+	if err := tx.Create(rule).Error; err != nil {
+		return err
+	}
+	if err := createRuleRevision(tx, rule, actor); err != nil {
+		return err
+	}
+	if err := tx.Model(rule).Select("current_revision_id", "revision").Updates(rule).Error; err != nil {
+		return err
+	}
+	return recordRuleAudit(tx, rule.ID, model.RuleAuditCreate, actor, nil, rule)
+}
+
+// updateRule applies change to a rule, validates the result and saves it as a new revision with an
+// audit entry using tx. Changes that leave the rule identical are not recorded. The rule row stays
+// locked until tx ends, so concurrent updates cannot both write the same revision number.
+func updateRule(tx *gorm.DB, id, actor string, change func(rule *model.ComplianceRule)) (*model.ComplianceRule, error) {
+	var before model.ComplianceRule
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&before, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	updated := before
+	updated.Tags = append([]string(nil), before.Tags...)
+	change(&updated)
+	if err := validateComplianceRule(&updated); err != nil {
+		return nil, err
+	}
+	if !ruleContentChanged(&before, &updated) {
+		return &updated, nil
+	}
+	if updated.Name != before.Name {
+		if err := ensureRuleNameAvailable(tx, updated.Name, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := createRuleRevision(tx, &updated, actor); err != nil {
+		return nil, err
+	}
+
+	columns := append(append([]string{}, ruleContentColumns...), "current_revision_id", "revision", "updated_at")
+	if err := tx.Model(&updated).Select(columns).Updates(&updated).Error; err != nil {
+		return nil, fmt.Errorf("failed to update rule: %w", err)
+	}
+	if err := recordRuleAudit(tx, id, model.RuleAuditUpdate, actor, &before, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// modifyComplianceRule updates a rule in its own transaction
+func (s *DocumentService) modifyComplianceRule(id, actor string, change func(rule *model.ComplianceRule)) (*model.ComplianceRule, error) {
+	var updated *model.ComplianceRule
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		updated, err = updateRule(tx, id, actor, change)
+		return err
+	})
+	if err != nil {
+		log.Printf("[modifyComplianceRule] Error updating rule %s: %v", id, err)
+		return nil, err
+	}
+	log.Printf("Compliance rule %s updated by %s", updated.Name, actor)
+	return updated, nil
+}
+
+// DeleteComplianceRule soft-deletes a rule so it is no longer evaluated while existing results keep
+// referencing it
+func (s *DocumentService) DeleteComplianceRule(id, actor string) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var rule model.ComplianceRule
+		if err := tx.First(&rule, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&rule).Error; err != nil {
+			return fmt.Errorf("failed to delete rule: %w", err)
+		}
+		return recordRuleAudit(tx, id, model.RuleAuditDelete, actor, &rule, nil)
+	})
+	if err != nil {
+		log.Printf("[DeleteComplianceRule] Error deleting rule %s: %v", id, err)
+		return err
+	}
+	log.Printf("Compliance rule %s deleted by %s", id, actor)
+	return nil
+}
+
+// GetRuleAuditLog lists the recorded changes of a rule, newest first. Deleted rules keep their history.
+func (s *DocumentService) GetRuleAuditLog(id string) ([]model.RuleAuditLog, error) {
+	var count int64
+	if err := s.db.Unscoped().Model(&model.ComplianceRule{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var entries []model.RuleAuditLog
+	if err := s.db.Where("rule_id = ?", id).Order("created_at DESC").Find(&entries).Error; err != nil {
+		log.Printf("[GetRuleAuditLog] Error fetching audit log for rule %s: %v", id, err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetRuleRevisions lists every revision of a rule, newest first. Deleted rules keep their revisions.
+func (s *DocumentService) GetRuleRevisions(id string) ([]model.ComplianceRuleRevision, error) {
+	var count int64
+	if err := s.db.Unscoped().Model(&model.ComplianceRule{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var revisions []model.ComplianceRuleRevision
+	if err := s.db.Where("rule_id = ?", id).Order("revision DESC").Find(&revisions).Error; err != nil {
+		log.Printf("[GetRuleRevisions] Error fetching revisions for rule %s: %v", id, err)
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// RuleSyntaxError is a problem found by ValidateRuleSyntax. Field is "pattern" or "expression";
+// positions are only reported for expressions.
+type RuleSyntaxError struct {
+	Field    string `json:"field"`
+	Message  string `json:"message"`
+	Position *int   `json:"position,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// ValidateRuleSyntax checks a rule pattern and expression without saving anything
+func (s *DocumentService) ValidateRuleSyntax(pattern, expression string) []RuleSyntaxError {
+	problems := []RuleSyntaxError{}
+	if strings.TrimSpace(pattern) != "" {
+		if _, err := CompileRulePattern(pattern); err != nil {
+			problems = append(problems, RuleSyntaxError{Field: "pattern", Message: err.Error()})
+		}
+	}
+	if strings.TrimSpace(expression) != "" {
+		if _, err := ParseRuleExpression(expression); err != nil {
+			problem := RuleSyntaxError{Field: "expression", Message: err.Error()}
+			var exprErr *RuleExpressionError
+			if errors.As(err, &exprErr) {
+				problem.Message = exprErr.Message
+				problem.Position = &exprErr.Position
+				problem.Line = exprErr.Line
+				problem.Column = exprErr.Column
+			}
+			problems = append(problems, problem)
+		}
+	}
+	return problems
+}
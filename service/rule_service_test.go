@@ -0,0 +1,132 @@
+package services
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestValidateComplianceRule(t *testing.T) {
+	rule := &models.ComplianceRule{Name: "  Signature Check ", Severity: "High", Pattern: `(?i)signed by`}
+	require.NoError(t, validateComplianceRule(rule))
+	assert.Equal(t, "Signature Check", rule.Name)
+	assert.Equal(t, "high", rule.Severity)
+
+	invalid := []models.ComplianceRule{
+		{Name: "", Severity: "low"},
+		{Name: "Missing severity"},
+		{Name: "Unknown severity", Severity: "critical"},
+		{Name: "Broken pattern", Severity: "low", Pattern: "signed (by"},
+	}
+	for _, r := range invalid {
+		r := r
+		assert.ErrorIs(t, validateComplianceRule(&r), ErrInvalidRule, r.Name)
+	}
+}
+
+var fakeRuleColumns = []string{"id", "name", "description", "pattern", "severity", "requirement", "revision"}
+
+// onRule answers lookups of a rule at revision 2
+func onRule(fake *fakeDB) {
+	fake.on(`FROM "compliance_rules" WHERE id = \$1`, fakeRuleColumns,
+		[]driver.Value{"rule-1", "Signature", "Must be signed", "signed by", "high", "required", int64(2)})
+}
+
+func TestPatchComplianceRuleCreatesRevision(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	onRule(fake)
+	fake.on(`INSERT INTO "compliance_rule_revisions"`, []string{"id"}, []driver.Value{"rev-3"})
+	s := &DocumentService{db: db}
+
+	severity := "Medium"
+	updated, err := s.PatchComplianceRule("rule-1", RulePatch{Severity: &severity}, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "medium", updated.Severity)
+	assert.Equal(t, 3, updated.Revision)
+	require.NotNil(t, updated.CurrentRevisionID)
+	assert.Equal(t, "rev-3", *updated.CurrentRevisionID)
+
+	lookup := fake.executed(`FROM "compliance_rules" WHERE id = \$1`)
+	require.Len(t, lookup, 1)
+	assert.Contains(t, lookup[0].SQL, "FOR UPDATE", "the rule is locked until the revision is written")
+
+	revisions := fake.executed(`INSERT INTO "compliance_rule_revisions"`)
+	require.Len(t, revisions, 1)
+	assert.Contains(t, revisions[0].Args, "rule-1")
+	assert.Contains(t, revisions[0].Args, 3)
+	assert.Contains(t, revisions[0].Args, "medium")
+	assert.Contains(t, revisions[0].Args, "alice")
+	assert.Len(t, fake.executed(`^UPDATE "compliance_rules"`), 1)
+	assert.Len(t, fake.executed(`INSERT INTO "rule_audit_logs"`), 1)
+}
+
+func TestPatchComplianceRuleWithoutChange(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	onRule(fake)
+	s := &DocumentService{db: db}
+
+	severity, name := "HIGH", " Signature "
+	updated, err := s.PatchComplianceRule("rule-1", RulePatch{Severity: &severity, Name: &name}, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Revision)
+	assert.Empty(t, fake.executed(`^INSERT`), "an edit that changes nothing is not recorded")
+	assert.Empty(t, fake.executed(`^UPDATE`))
+}
+
+func TestGetRuleRevisions(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	fake.on(`SELECT count\(\*\) FROM "compliance_rules"`, []string{"count"}, []driver.Value{int64(1)})
+	fake.on(`FROM "compliance_rule_revisions" WHERE rule_id = \$1 ORDER BY revision DESC`,
+		[]string{"id", "rule_id", "revision", "severity"},
+		[]driver.Value{"rev-2", "rule-1", int64(2), "high"},
+		[]driver.Value{"rev-1", "rule-1", int64(1), "low"})
+	s := &DocumentService{db: db}
+
+	revisions, err := s.GetRuleRevisions("rule-1")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 2, revisions[0].Revision)
+	assert.Equal(t, "high", revisions[0].Severity)
+	assert.Equal(t, 1, revisions[1].Revision)
+
+	counts := fake.executed(`SELECT count\(\*\) FROM "compliance_rules"`)
+	require.Len(t, counts, 1)
+	assert.NotContains(t, counts[0].SQL, "deleted_at", "deleted rules keep their revisions")
+}
+
+func TestGetRuleRevisionsOfUnknownRule(t *testing.T) {
+	db, _ := newFakeGormDB(t)
+	s := &DocumentService{db: db}
+
+	_, err := s.GetRuleRevisions("missing")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestAddComplianceRuleIgnoresServerManagedFields(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	fake.on(`^INSERT INTO "compliance_rules"`, []string{"id"}, []driver.Value{"rule-new"})
+	fake.on(`INSERT INTO "compliance_rule_revisions"`, []string{"id"}, []driver.Value{"rev-1"})
+	s := &DocumentService{db: db}
+
+	rule := &models.ComplianceRule{
+		ID:        "rule-chosen",
+		Name:      "Signature",
+		Pattern:   "signed by",
+		Severity:  "high",
+		Revision:  7,
+		DeletedAt: gorm.DeletedAt{Valid: true},
+	}
+	require.NoError(t, s.AddComplianceRule(rule, "alice"))
+	assert.Equal(t, "rule-new", rule.ID)
+	assert.Equal(t, 1, rule.Revision)
+	assert.False(t, rule.DeletedAt.Valid)
+
+	inserts := fake.executed(`^INSERT INTO "compliance_rules"`)
+	require.Len(t, inserts, 1)
+	assert.NotContains(t, inserts[0].Args, "rule-chosen")
+	assert.NotContains(t, inserts[0].Args, 7)
+}
@@ -0,0 +1,568 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// defaultElasticsearchURL is the Elastic Cloud deployment used when ELASTICSEARCH_URL is not set.
+const defaultElasticsearchURL = "https://9599cea5e64e4db2b21dbee49e7ca79e.asia-south1.gcp.elastic-cloud.com:443"
+
+// ElasticsearchConfig configures the Elasticsearch search backend.
+type ElasticsearchConfig struct {
+	// Addresses are the URLs of the cluster nodes.
+	Addresses []string
+	// APIKey, or Username and Password, authenticate requests. Both may be empty for an open cluster.
+	APIKey   string
+	Username string
+	Password string
+	// Index is the index holding the documents.
+	Index string
+}
+
+// ElasticsearchConfigFromEnv reads the Elasticsearch configuration from the environment.
+// ELASTICSEARCH_URL may list several comma-separated node URLs.
+func ElasticsearchConfigFromEnv() ElasticsearchConfig {
+	config := ElasticsearchConfig{
+		APIKey:   os.Getenv("ELASTICSEARCH_API_KEY"),
+		Username: os.Getenv("ELASTICSEARCH_USERNAME"),
+		Password: os.Getenv("ELASTICSEARCH_PASSWORD"),
+		Index:    strings.TrimSpace(os.Getenv("ELASTICSEARCH_INDEX")),
+	}
+	for _, address := range strings.Split(os.Getenv("ELASTICSEARCH_URL"), ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			config.Addresses = append(config.Addresses, address)
+		}
+	}
+	if len(config.Addresses) == 0 {
+		config.Addresses = []string{defaultElasticsearchURL}
+	}
+	if config.Index == "" {
+		config.Index = "documents"
+	}
+	return config
+}
+
+// ElasticsearchIndex keeps one entry per document in an Elasticsearch index, keyed by document ID.
+type ElasticsearchIndex struct {
+	client *elasticsearch.Client
+	index  string
+}
+
+// NewElasticsearchIndex creates a client for the configured cluster
+func NewElasticsearchIndex(config ElasticsearchConfig) (*ElasticsearchIndex, error) {
+	client, err := elasticsearch.NewClient(elasticsearch.Config{
+		Addresses: config.Addresses,
+		APIKey:    config.APIKey,
+		Username:  config.Username,
+		Password:  config.Password,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Elasticsearch client: %w", err)
+	}
+	return &ElasticsearchIndex{client: client, index: config.Index}, nil
+}
+
+func (e *ElasticsearchIndex) Name() string { return "elasticsearch" }
+
+// Setup installs the index template generated from the elastic tags of model.Document and makes the
+// alias point at the index of the current mapping version. Entries of an older version, or of an index
+// created before the alias existed, are copied over first; older versioned indices are kept for
+// rollback.
+func (e *ElasticsearchIndex) Setup(ctx context.Context) error {
+	spec := documentsIndexSpec(e.index)
+	template, err := spec.template()
+	if err != nil {
+		return fmt.Errorf("failed to generate index template: %w", err)
+	}
+	body, err := json.Marshal(template)
+	if err != nil {
+		return fmt.Errorf("failed to marshal index template: %w", err)
+	}
+	res, err := e.client.Indices.PutIndexTemplate(spec.Alias, bytes.NewReader(body), e.client.Indices.PutIndexTemplate.WithContext(ctx))
+	if err := elasticResult(res, err, "index template update"); err != nil {
+		return err
+	}
+
+	target := spec.versionedIndex()
+	exists, err := e.indexExists(ctx, target)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		res, err := e.client.Indices.Create(target, e.client.Indices.Create.WithContext(ctx))
+		if err := elasticResult(res, err, "index creation"); err != nil {
+			return err
+		}
+		log.Printf("Created Elasticsearch index %s", target)
+	}
+
+	aliased, err := e.aliasedIndices(ctx, spec.Alias)
+	if err != nil {
+		return err
+	}
+	if len(aliased) == 1 && aliased[0] == target {
+		return nil
+	}
+	// Without the alias, an index named like it predates versioned indices and is replaced by it.
+	legacy := false
+	if len(aliased) == 0 {
+		if legacy, err = e.indexExists(ctx, spec.Alias); err != nil {
+			return err
+		}
+		if legacy {
+			aliased = []string{spec.Alias}
+		}
+	}
+
+	actions := []interface{}{}
+	for _, index := range aliased {
+		if index == target {
+			continue
+		}
+		if err := e.copyEntries(ctx, index, target); err != nil {
+			return err
+		}
+		if legacy {
+			actions = append(actions, map[string]interface{}{"remove_index": map[string]interface{}{"index": index}})
+		} else {
+			actions = append(actions, map[string]interface{}{"remove": map[string]interface{}{"index": index, "alias": spec.Alias}})
+		}
+	}
+	actions = append(actions, map[string]interface{}{"add": map[string]interface{}{"index": target, "alias": spec.Alias}})
+	body, err = json.Marshal(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return fmt.Errorf("failed to marshal alias update: %w", err)
+	}
+	res, err = e.client.Indices.UpdateAliases(bytes.NewReader(body), e.client.Indices.UpdateAliases.WithContext(ctx))
+	if err := elasticResult(res, err, "alias update"); err != nil {
+		return err
+	}
+	log.Printf("Elasticsearch alias %s now points at %s (was %v)", spec.Alias, target, aliased)
+	return nil
+}
+
+// indexExists reports whether an index or alias exists
+func (e *ElasticsearchIndex) indexExists(ctx context.Context, name string) (bool, error) {
+	res, err := e.client.Indices.Exists([]string{name}, e.client.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return false, fmt.Errorf("elasticsearch index check failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, fmt.Errorf("elasticsearch index check failed: %s", res.String())
+	}
+	return true, nil
+}
+
+// aliasedIndices returns the indices an alias points at, none if the alias does not exist
+func (e *ElasticsearchIndex) aliasedIndices(ctx context.Context, alias string) ([]string, error) {
+	res, err := e.client.Indices.GetAlias(e.client.Indices.GetAlias.WithName(alias), e.client.Indices.GetAlias.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("elasticsearch alias lookup failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("elasticsearch alias lookup failed: %s", res.String())
+	}
+	var indices map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&indices); err != nil {
+		return nil, fmt.Errorf("failed to decode alias lookup: %w", err)
+	}
+	names := make([]string, 0, len(indices))
+	for name := range indices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// copyEntriesScript upgrades entries written by earlier mapping versions while they are copied.
+const copyEntriesScript = `if (ctx._source.id == null && ctx._source.document_id != null) { ctx._source.id = ctx._source.remove('document_id') }
+if (ctx._source.failing_rule_keys == null && ctx._source.failing_rules != null) {
+  def keys = new ArrayList();
+  for (def name : ctx._source.failing_rules) { keys.add(name.toLowerCase()) }
+  ctx._source.failing_rule_keys = keys;
+}`
+
+// copyEntries copies every entry of one index into another, renaming the document_id field of entries
+// written before the mapping was generated. Entries written before failing_rule_keys existed get the
+// lower-cased names of their failing rules; the rule IDs are added when the entry is next indexed.
+func (e *ElasticsearchIndex) copyEntries(ctx context.Context, from, to string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"conflicts": "proceed",
+		"source":    map[string]interface{}{"index": from},
+		"dest":      map[string]interface{}{"index": to},
+		"script": map[string]interface{}{
+			"lang":   "painless",
+			"source": copyEntriesScript,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal reindex request: %w", err)
+	}
+	res, err := e.client.Reindex(
+		bytes.NewReader(body),
+		e.client.Reindex.WithWaitForCompletion(true),
+		e.client.Reindex.WithRefresh(true),
+		e.client.Reindex.WithContext(ctx),
+	)
+	if err := elasticResult(res, err, "reindex from "+from); err != nil {
+		return err
+	}
+	log.Printf("Copied Elasticsearch index %s into %s", from, to)
+	return nil
+}
+
+// elasticResult closes res and turns a failed request or error response into an error
+func elasticResult(res *esapi.Response, err error, operation string) error {
+	if err != nil {
+		return fmt.Errorf("elasticsearch %s request failed: %w", operation, err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("elasticsearch %s failed: %s", operation, res.String())
+	}
+	return nil
+}
+
+// Index writes the documents with the bulk API, one entry per document keyed by its ID
+func (e *ElasticsearchIndex) Index(ctx context.Context, docs []model.Document) ([]IndexFailure, error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	body, err := bulkIndexBody(docs, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	res, err := e.client.Bulk(bytes.NewReader(body), e.client.Bulk.WithIndex(e.index), e.client.Bulk.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("elasticsearch bulk request failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("elasticsearch bulk indexing failed: %s", res.String())
+	}
+	return parseBulkResponse(res.Body)
+}
+
+// Delete removes the entry keyed by key and any entry of the document with that ID
+func (e *ElasticsearchIndex) Delete(ctx context.Context, key string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []interface{}{
+					map[string]interface{}{"ids": map[string]interface{}{"values": []string{key}}},
+					map[string]interface{}{"term": map[string]interface{}{"id": key}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal delete query: %w", err)
+	}
+	res, err := e.client.DeleteByQuery(
+		[]string{e.index},
+		bytes.NewReader(body),
+		e.client.DeleteByQuery.WithConflicts("proceed"),
+		e.client.DeleteByQuery.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("elasticsearch delete request failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != 404 {
+		return fmt.Errorf("elasticsearch delete failed: %s", res.String())
+	}
+	log.Printf("Deleted index entries of %s from Elasticsearch", key)
+	return nil
+}
+
+// Entries scrolls through the whole index, reading only the updated_at of each entry
+func (e *ElasticsearchIndex) Entries(ctx context.Context) (map[string]time.Time, error) {
+	res, err := e.client.Search(
+		e.client.Search.WithContext(ctx),
+		e.client.Search.WithIndex(e.index),
+		e.client.Search.WithScroll(time.Minute),
+		e.client.Search.WithSize(1000),
+		e.client.Search.WithSort("_doc"),
+		e.client.Search.WithSource("updated_at"),
+	)
+	entries := map[string]time.Time{}
+	scrollID := ""
+	for {
+		if err != nil {
+			return nil, fmt.Errorf("elasticsearch scroll request failed: %w", err)
+		}
+		var page struct {
+			ScrollID string `json:"_scroll_id"`
+			Hits     struct {
+				Hits []struct {
+					ID     string `json:"_id"`
+					Source struct {
+						UpdatedAt string `json:"updated_at"`
+					} `json:"_source"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		if res.IsError() {
+			message := res.String()
+			res.Body.Close()
+			return nil, fmt.Errorf("elasticsearch scroll failed: %s", message)
+		}
+		err = json.NewDecoder(res.Body).Decode(&page)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode scroll response: %w", err)
+		}
+		scrollID = page.ScrollID
+		if len(page.Hits.Hits) == 0 {
+			break
+		}
+		for _, hit := range page.Hits.Hits {
+			updatedAt, _ := time.Parse(time.RFC3339Nano, hit.Source.UpdatedAt)
+			entries[hit.ID] = updatedAt
+		}
+		res, err = e.client.Scroll(
+			e.client.Scroll.WithContext(ctx),
+			e.client.Scroll.WithScrollID(scrollID),
+			e.client.Scroll.WithScroll(time.Minute),
+		)
+	}
+	if scrollID != "" {
+		if res, err := e.client.ClearScroll(e.client.ClearScroll.WithScrollID(scrollID)); err == nil {
+			res.Body.Close()
+		}
+	}
+	return entries, nil
+}
+
+// bulkIndexBody returns the NDJSON bulk request that indexes docs, each keyed by its ID with the
+// stored file it was read from
+func bulkIndexBody(docs []model.Document, now time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	for _, doc := range docs {
+		source := documentSource(doc)
+		source["file_id"] = storageKeyFromURL(doc.OriginalURL)
+		source["file_url"] = doc.OriginalURL
+		source["timestamp"] = now.UTC()
+
+		action, err := json.Marshal(map[string]interface{}{"index": map[string]interface{}{"_id": doc.ID}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal bulk action: %w", err)
+		}
+		line, err := json.Marshal(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal document %s for indexing: %w", doc.ID, err)
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(line)
+		body.WriteByte('\n')
+	}
+	return body.Bytes(), nil
+}
+
+// parseBulkResponse returns the items of a bulk response that failed
+func parseBulkResponse(r io.Reader) ([]IndexFailure, error) {
+	var response struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string `json:"_id"`
+			Status int    `json:"status"`
+			Error  *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(r).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode bulk response: %w", err)
+	}
+	if !response.Errors {
+		return nil, nil
+	}
+	var failures []IndexFailure
+	for _, item := range response.Items {
+		for _, result := range item {
+			if result.Error != nil {
+				failures = append(failures, IndexFailure{DocumentID: result.ID, Reason: result.Error.Type + ": " + result.Error.Reason})
+			}
+		}
+	}
+	return failures, nil
+}
+
+// Search runs req as an Elasticsearch query with highlighting and facet aggregations
+func (e *ElasticsearchIndex) Search(ctx context.Context, req SearchRequest) (*SearchResults, error) {
+	body, err := json.Marshal(buildSearchQuery(req))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal search query: %w", err)
+	}
+	res, err := e.client.Search(
+		e.client.Search.WithContext(ctx),
+		e.client.Search.WithIndex(e.index),
+		e.client.Search.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("search request failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("elasticsearch search failed: %s", res.String())
+	}
+	return parseSearchResponse(res.Body)
+}
+
+// buildSearchQuery returns the Elasticsearch request body for a normalised req
+func buildSearchQuery(req SearchRequest) map[string]interface{} {
+	var filters []interface{}
+	term := func(field, value string) {
+		filters = append(filters, map[string]interface{}{"term": map[string]interface{}{field: value}})
+	}
+	if category := strings.ToLower(strings.TrimSpace(req.Filter.Category)); category != "" {
+		term("category", category)
+	}
+	if fileType := normalizeFileType(req.Filter.FileType); fileType != "" {
+		term("file_type", fileType)
+	}
+	if rule := strings.ToLower(strings.TrimSpace(req.Filter.FailingRule)); rule != "" {
+		term("failing_rule_keys", rule)
+	}
+	if req.Filter.MinRisk != nil || req.Filter.MaxRisk != nil {
+		bounds := map[string]interface{}{}
+		if req.Filter.MinRisk != nil {
+			bounds["gte"] = *req.Filter.MinRisk
+		}
+		if req.Filter.MaxRisk != nil {
+			bounds["lte"] = *req.Filter.MaxRisk
+		}
+		filters = append(filters, map[string]interface{}{"range": map[string]interface{}{"risk_score": bounds}})
+	}
+	if req.Filter.CreatedFrom != nil || req.Filter.CreatedTo != nil {
+		bounds := map[string]interface{}{}
+		if req.Filter.CreatedFrom != nil {
+			bounds["gte"] = req.Filter.CreatedFrom.UTC().Format(time.RFC3339Nano)
+		}
+		if req.Filter.CreatedTo != nil {
+			bounds["lte"] = req.Filter.CreatedTo.UTC().Format(time.RFC3339Nano)
+		}
+		filters = append(filters, map[string]interface{}{"range": map[string]interface{}{"created_at": bounds}})
+	}
+
+	match := map[string]interface{}{
+		"multi_match": map[string]interface{}{
+			"query":  req.Query,
+			"fields": []string{"title^2", "search_content", "file_id"},
+		},
+	}
+	body := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{"must": match, "filter": filters},
+		},
+		"from":             (req.Page - 1) * req.Size,
+		"size":             req.Size,
+		"track_total_hits": true,
+		"_source":          map[string]interface{}{"excludes": []string{"ocr_text", "search_content", "parsed_data"}},
+		"highlight": map[string]interface{}{
+			// The query matches search_content; the snippets come from the text alone.
+			"require_field_match": false,
+			"fields": map[string]interface{}{
+				"ocr_text": map[string]interface{}{"fragment_size": 150, "number_of_fragments": 3},
+				"title":    map[string]interface{}{"number_of_fragments": 0},
+			},
+		},
+	}
+	if req.Sort != "relevance" {
+		body["sort"] = []interface{}{
+			map[string]interface{}{req.Sort: map[string]interface{}{"order": req.Order}},
+			"_score",
+		}
+	}
+
+	aggs := map[string]interface{}{}
+	for facet, field := range searchFacetFields {
+		aggs[facet] = map[string]interface{}{"terms": map[string]interface{}{"field": field, "size": 20}}
+	}
+	body["aggs"] = aggs
+	return body
+}
+
+// parseSearchResponse reads the hits, total and facets from an Elasticsearch search response
+func parseSearchResponse(r io.Reader) (*SearchResults, error) {
+	var response struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				ID        string              `json:"_id"`
+				Score     *float64            `json:"_score"`
+				Source    json.RawMessage     `json:"_source"`
+				Highlight map[string][]string `json:"highlight"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations map[string]struct {
+			Buckets []struct {
+				Key      string `json:"key"`
+				DocCount int64  `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"aggregations"`
+	}
+	if err := json.NewDecoder(r).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode search response: %w", err)
+	}
+
+	results := &SearchResults{
+		Hits:   make([]SearchHit, 0, len(response.Hits.Hits)),
+		Total:  response.Hits.Total.Value,
+		Facets: make(map[string][]FacetCount, len(searchFacetFields)),
+	}
+	for _, raw := range response.Hits.Hits {
+		var hit SearchHit
+		var source struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(raw.Source, &hit); err != nil {
+			log.Printf("[SearchDocuments] Skipping hit %s with invalid source: %v", raw.ID, err)
+			continue
+		}
+		if err := json.Unmarshal(raw.Source, &source); err == nil {
+			hit.DocumentID = source.ID
+		}
+		if hit.DocumentID == "" {
+			hit.DocumentID = raw.ID
+		}
+		if raw.Score != nil {
+			hit.Score = *raw.Score
+		}
+		hit.Highlights = raw.Highlight
+		results.Hits = append(results.Hits, hit)
+	}
+	for facet := range searchFacetFields {
+		counts := []FacetCount{}
+		for _, bucket := range response.Aggregations[facet].Buckets {
+			counts = append(counts, FacetCount{Value: bucket.Key, Count: bucket.DocCount})
+		}
+		results.Facets[facet] = counts
+	}
+	return results, nil
+}
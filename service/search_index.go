@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"gorm.io/gorm"
+)
+
+// searchSetupTimeout bounds the index setup at startup, which may copy an older index.
+const searchSetupTimeout = 5 * time.Minute
+
+// SearchIndex stores the searchable text and metadata of documents and answers search requests.
+type SearchIndex interface {
+	// Name identifies the backend in logs and error messages.
+	Name() string
+	// Setup creates or migrates the storage of the index. It runs once at startup.
+	Setup(ctx context.Context) error
+	// Index adds or replaces the entries of stored documents, keyed by document ID. Documents that
+	// could not be indexed are returned as failures; err is set only when the request failed as a whole.
+	Index(ctx context.Context, docs []model.Document) ([]IndexFailure, error)
+	// Delete removes the entry with the given key, or every entry of the document with that ID.
+	Delete(ctx context.Context, key string) error
+	// Entries returns the key of every entry with the updated_at of the document it was built from.
+	Entries(ctx context.Context) (map[string]time.Time, error)
+	// Search runs a request already checked by normalizeSearchRequest. Page and Size of the results
+	// are filled in by the caller.
+	Search(ctx context.Context, req SearchRequest) (*SearchResults, error)
+}
+
+// IndexFailure is a document that could not be indexed.
+type IndexFailure struct {
+	DocumentID string `json:"document_id"`
+	Reason     string `json:"reason"`
+}
+
+// NewSearchIndexFromEnv selects the search backend configured by SEARCH_BACKEND.
+// Supported values are "elasticsearch" and "postgres". When unset, Elasticsearch is used if
+// ELASTICSEARCH_URL or ELASTICSEARCH_API_KEY is set and Postgres otherwise.
+func NewSearchIndexFromEnv(db *gorm.DB) (SearchIndex, error) {
+	backend := strings.ToLower(strings.TrimSpace(os.Getenv("SEARCH_BACKEND")))
+	if backend == "" {
+		backend = "postgres"
+		if os.Getenv("ELASTICSEARCH_URL") != "" || os.Getenv("ELASTICSEARCH_API_KEY") != "" {
+			backend = "elasticsearch"
+		}
+	}
+	switch backend {
+	case "elasticsearch", "elastic":
+		return NewElasticsearchIndex(ElasticsearchConfigFromEnv())
+	case "postgres", "postgresql":
+		return NewPostgresSearchIndex(db), nil
+	default:
+		return nil, fmt.Errorf("unknown SEARCH_BACKEND %q", backend)
+	}
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSearchIndexFromEnv(t *testing.T) {
+	t.Setenv("SEARCH_BACKEND", "")
+	t.Setenv("ELASTICSEARCH_URL", "")
+	t.Setenv("ELASTICSEARCH_API_KEY", "")
+	search, err := NewSearchIndexFromEnv(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "postgres", search.Name())
+
+	t.Setenv("ELASTICSEARCH_URL", "http://localhost:9200, http://localhost:9201")
+	t.Setenv("ELASTICSEARCH_INDEX", "contracts")
+	config := ElasticsearchConfigFromEnv()
+	assert.Equal(t, []string{"http://localhost:9200", "http://localhost:9201"}, config.Addresses)
+	assert.Equal(t, "contracts", config.Index)
+	search, err = NewSearchIndexFromEnv(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "elasticsearch", search.Name())
+
+	t.Setenv("SEARCH_BACKEND", "Postgres")
+	search, err = NewSearchIndexFromEnv(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "postgres", search.Name())
+
+	t.Setenv("SEARCH_BACKEND", "solr")
+	_, err = NewSearchIndexFromEnv(nil)
+	assert.Error(t, err)
+}
+
+func TestPostgresHighlights(t *testing.T) {
+	highlights := postgresHighlights("Master <em>Lease</em>",
+		"the <em>lease</em> begins"+headlineDelimiter+"no match here"+headlineDelimiter+"renew the <em>lease</em>")
+	assert.Equal(t, []string{"Master <em>Lease</em>"}, highlights["title"])
+	assert.Equal(t, []string{"the <em>lease</em> begins", "renew the <em>lease</em>"}, highlights["ocr_text"])
+
+	// A trigram-only match leaves nothing marked.
+	assert.Nil(t, postgresHighlights("Master Agreement", "The start of the text"))
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"gorm.io/gorm/schema"
+)
+
+// documentsIndexVersion is the version of the documents index mapping. Bump it when the elastic tags
+// of model.Document or documentIndexFields change; the next startup creates the new index, copies the
+// entries over and moves the alias.
+const documentsIndexVersion = 2
+
+// documentIndexFields are the mappings of the fields indexed alongside a document's own: the stored
+// file an entry was built from and the compliance summary used for filters and facets.
+var documentIndexFields = map[string]interface{}{
+	"file_id":            map[string]interface{}{"type": "keyword"},
+	"file_url":           map[string]interface{}{"type": "keyword"},
+	"compliance_status":  map[string]interface{}{"type": "keyword"},
+	"failing_rules":      map[string]interface{}{"type": "keyword"},
+	"failing_rule_keys":  map[string]interface{}{"type": "keyword"},
+	"failing_severities": map[string]interface{}{"type": "keyword"},
+	"timestamp":          map[string]interface{}{"type": "date"},
+}
+
+// elasticIndexSpec describes an index whose mapping is generated from the elastic tags of a model.
+type elasticIndexSpec struct {
+	// Alias is the name searches and writes use. It points at the index of the current Version.
+	Alias   string
+	Version int
+	// Model is a value of the struct whose tagged fields are indexed.
+	Model interface{}
+	// Fields maps fields indexed alongside the model's own to their mappings.
+	Fields map[string]interface{}
+}
+
+// documentsIndexSpec returns the spec of the documents index behind alias
+func documentsIndexSpec(alias string) elasticIndexSpec {
+	return elasticIndexSpec{Alias: alias, Version: documentsIndexVersion, Model: model.Document{}, Fields: documentIndexFields}
+}
+
+// versionedIndex returns the name of the index holding the spec's version
+func (spec elasticIndexSpec) versionedIndex() string {
+	return fmt.Sprintf("%s_v%d", spec.Alias, spec.Version)
+}
+
+// template returns the index template applied to every version of the spec's index. Fields that are
+// not mapped are kept in _source but not indexed.
+func (spec elasticIndexSpec) template() (map[string]interface{}, error) {
+	properties, err := elasticProperties(spec.Model)
+	if err != nil {
+		return nil, err
+	}
+	for field, mapping := range spec.Fields {
+		properties[field] = mapping
+	}
+	return map[string]interface{}{
+		"index_patterns": []string{spec.Alias + "_v*"},
+		"version":        spec.Version,
+		"template": map[string]interface{}{
+			"mappings": map[string]interface{}{
+				"dynamic":    false,
+				"properties": properties,
+			},
+		},
+	}, nil
+}
+
+// elasticProperties reads the elastic tags of a model struct into field mappings keyed by column
+// name. A tag is a comma-separated list of key:value options; "type" is required and the others, such
+// as "analyzer", are copied into the mapping.
+func elasticProperties(value interface{}) (map[string]interface{}, error) {
+	t := reflect.TypeOf(value)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot map %s: not a struct", t)
+	}
+
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("elastic")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		mapping := map[string]interface{}{}
+		for _, option := range strings.Split(tag, ",") {
+			key, optionValue, ok := strings.Cut(option, ":")
+			key, optionValue = strings.TrimSpace(key), strings.TrimSpace(optionValue)
+			if !ok || key == "" || optionValue == "" {
+				return nil, fmt.Errorf("invalid elastic tag %q on %s.%s", tag, t.Name(), field.Name)
+			}
+			mapping[key] = optionValue
+		}
+		if _, ok := mapping["type"]; !ok {
+			return nil, fmt.Errorf("elastic tag %q on %s.%s has no type", tag, t.Name(), field.Name)
+		}
+		properties[elasticFieldName(field)] = mapping
+	}
+	return properties, nil
+}
+
+// elasticSource returns the values of the elastic-tagged fields of a model struct, keyed like
+// elasticProperties
+func elasticSource(value interface{}) map[string]interface{} {
+	v := reflect.Indirect(reflect.ValueOf(value))
+	source := map[string]interface{}{}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if tag := field.Tag.Get("elastic"); tag == "" || tag == "-" {
+			continue
+		}
+		source[elasticFieldName(field)] = v.Field(i).Interface()
+	}
+	return source
+}
+
+// elasticFieldName names a model field in the index like its database column
+func elasticFieldName(field reflect.StructField) string {
+	return schema.NamingStrategy{}.ColumnName("", field.Name)
+}
+
+// documentSource returns the indexed fields of a stored document: its own tagged fields with the
+// compliance summary of its results
+func documentSource(doc model.Document) map[string]interface{} {
+	doc.PopulateSearchContent()
+	source := elasticSource(doc)
+	for field, value := range searchFields(doc) {
+		source[field] = value
+	}
+	return source
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElasticProperties(t *testing.T) {
+	properties, err := elasticProperties(model.Document{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"type": "keyword"}, properties["id"])
+	assert.Equal(t, map[string]interface{}{"type": "text", "analyzer": "standard"}, properties["title"])
+	assert.Equal(t, map[string]interface{}{"type": "float"}, properties["risk_score"])
+	assert.Equal(t, map[string]interface{}{"type": "flattened"}, properties["parsed_data"])
+	assert.Contains(t, properties, "search_content")
+	assert.Contains(t, properties, "ocr_text")
+
+	for _, tagged := range []interface{}{model.ComplianceRule{}, &model.DocumentRuleResult{}} {
+		properties, err := elasticProperties(tagged)
+		require.NoError(t, err)
+		assert.Contains(t, properties, "created_at")
+	}
+
+	_, err = elasticProperties(struct {
+		Name string `elastic:"analyzer:standard"`
+	}{})
+	assert.Error(t, err)
+	_, err = elasticProperties("not a struct")
+	assert.Error(t, err)
+}
+
+func TestDocumentsIndexTemplate(t *testing.T) {
+	spec := documentsIndexSpec("documents")
+	assert.Equal(t, "documents_v2", spec.versionedIndex())
+
+	template, err := spec.template()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"documents_v*"}, template["index_patterns"])
+	mappings := template["template"].(map[string]interface{})["mappings"].(map[string]interface{})
+	assert.Equal(t, false, mappings["dynamic"])
+	properties := mappings["properties"].(map[string]interface{})
+	for field := range documentIndexFields {
+		assert.Contains(t, properties, field)
+	}
+
+	// Every indexed field is mapped.
+	source := documentSource(model.Document{ID: "d1", Title: "Lease", OcrText: "text"})
+	source["file_id"], source["file_url"], source["timestamp"] = "f1", "url", time.Now()
+	for field := range source {
+		assert.Contains(t, properties, field)
+	}
+}
+
+func TestDocumentSource(t *testing.T) {
+	doc := model.Document{
+		ID:         "d1",
+		Title:      "Lease",
+		FileType:   ".PDF",
+		OcrText:    "The tenant shall pay rent.",
+		RiskScore:  0.4,
+		ParsedData: []byte(`[{"rule_name":"Rent","status":"fail","severity":"High"}]`),
+	}
+	source := documentSource(doc)
+	assert.Equal(t, "d1", source["id"])
+	assert.Equal(t, "Lease The tenant shall pay rent.", source["search_content"])
+	assert.Equal(t, 0.4, source["risk_score"])
+	assert.Equal(t, "pdf", source["file_type"])
+	assert.Equal(t, []string{"Rent"}, source["failing_rules"])
+	assert.NotContains(t, source, "document_id")
+}
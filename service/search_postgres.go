@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"gorm.io/gorm"
+)
+
+// searchQuerySQL parses a search in the syntax of web search engines: quoted phrases, "or" and a
+// leading "-" to exclude a word.
+const searchQuerySQL = "websearch_to_tsquery('english', ?)"
+
+// searchedTextLength is the number of leading characters of the OCR text that are indexed by
+// migration 021. Substring matches use the same expression as its trigram index.
+const searchedTextLength = 100000
+
+// headlineDelimiter separates the fragments of a text highlight.
+const headlineDelimiter = " ... "
+
+// postgresFacetExpressions maps each facet other than severity to the column expression it counts.
+var postgresFacetExpressions = map[string]string{
+	"file_type": "lower(file_type)",
+	"category":  "category",
+}
+
+// PostgresSearchIndex searches the documents table directly, using the search_vector column for
+// ranked full-text matches and pg_trgm for typo-tolerant titles and substrings of the text. The
+// table is the index, so Index and Delete have nothing to do.
+type PostgresSearchIndex struct {
+	db *gorm.DB
+}
+
+// NewPostgresSearchIndex creates a search backend over the documents table
+func NewPostgresSearchIndex(db *gorm.DB) *PostgresSearchIndex {
+	return &PostgresSearchIndex{db: db}
+}
+
+func (p *PostgresSearchIndex) Name() string { return "postgres" }
+
+// Setup has nothing to do; the search_vector column and its indexes come with the migrations.
+func (p *PostgresSearchIndex) Setup(ctx context.Context) error { return nil }
+
+func (p *PostgresSearchIndex) Index(ctx context.Context, docs []model.Document) ([]IndexFailure, error) {
+	return nil, nil
+}
+
+func (p *PostgresSearchIndex) Delete(ctx context.Context, key string) error { return nil }
+
+// Entries returns the documents themselves, which are always in step with the index
+func (p *PostgresSearchIndex) Entries(ctx context.Context) (map[string]time.Time, error) {
+	var rows []documentVersion
+	if err := p.db.WithContext(ctx).Model(&model.Document{}).Select("id, updated_at").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+	entries := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		entries[row.ID] = row.UpdatedAt
+	}
+	return entries, nil
+}
+
+// matching returns a query over the documents matching req
+func (p *PostgresSearchIndex) matching(ctx context.Context, req SearchRequest) (*gorm.DB, error) {
+	query := p.db.WithContext(ctx).Model(&model.Document{}).
+		Where(fmt.Sprintf("(documents.search_vector @@ %s OR documents.title %% ? OR left(documents.ocr_text, %d) ILIKE ?)", searchQuerySQL, searchedTextLength),
+			req.Query, req.Query, "%"+escapeLike(req.Query)+"%")
+	return filterDocuments(query, req.Filter.documentFilter())
+}
+
+// Search ranks the matching documents by full-text rank plus title similarity, and highlights the
+// matched words with ts_headline
+func (p *PostgresSearchIndex) Search(ctx context.Context, req SearchRequest) (*SearchResults, error) {
+	query, err := p.matching(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	results := &SearchResults{Hits: []SearchHit{}, Facets: make(map[string][]FacetCount, len(searchFacetFields))}
+	if err := query.Count(&results.Total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count search matches: %w", err)
+	}
+
+	order := "score DESC, created_at DESC, id"
+	if req.Sort != "relevance" {
+		order = fmt.Sprintf("%s %s, score DESC, id", documentSortExpressions[req.Sort], req.Order)
+	}
+	var rows []struct {
+		ID             string
+		Score          float64
+		TitleHighlight string
+		TextHighlight  string
+	}
+	query, _ = p.matching(ctx, req)
+	err = query.Select(
+		"documents.id, "+
+			"ts_rank_cd(documents.search_vector, "+searchQuerySQL+") + similarity(COALESCE(documents.title, ''), ?) AS score, "+
+			"ts_headline('english', COALESCE(documents.title, ''), "+searchQuerySQL+", 'HighlightAll=true, StartSel=<em>, StopSel=</em>') AS title_highlight, "+
+			"ts_headline('english', COALESCE(documents.ocr_text, ''), "+searchQuerySQL+", 'MaxFragments=3, MaxWords=30, MinWords=10, StartSel=<em>, StopSel=</em>, FragmentDelimiter=\""+headlineDelimiter+"\"') AS text_highlight",
+		req.Query, req.Query, req.Query, req.Query).
+		Order(order).
+		Offset((req.Page - 1) * req.Size).
+		Limit(req.Size).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to search documents: %w", err)
+	}
+
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	var documents []model.Document
+	if len(ids) > 0 {
+		if err := p.db.WithContext(ctx).Omit("ocr_text").Where("id IN ?", ids).Find(&documents).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch matching documents: %w", err)
+		}
+	}
+	byID := make(map[string]model.Document, len(documents))
+	for _, doc := range documents {
+		byID[doc.ID] = doc
+	}
+	for _, row := range rows {
+		doc, ok := byID[row.ID]
+		if !ok {
+			continue
+		}
+		hit := postgresSearchHit(doc)
+		hit.Score = row.Score
+		hit.Highlights = postgresHighlights(row.TitleHighlight, row.TextHighlight)
+		results.Hits = append(results.Hits, hit)
+	}
+
+	for facet := range searchFacetFields {
+		counts, err := p.facetCounts(ctx, req, facet)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count %s facet: %w", facet, err)
+		}
+		results.Facets[facet] = counts
+	}
+	return results, nil
+}
+
+// facetCounts counts the matching documents by the values of a facet, most common first
+func (p *PostgresSearchIndex) facetCounts(ctx context.Context, req SearchRequest, facet string) ([]FacetCount, error) {
+	query, err := p.matching(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	expression, ok := postgresFacetExpressions[facet]
+	if facet == "severity" {
+		// Severities of the failing rule results, like failing_severities in Elasticsearch
+		expression, ok = "lower(r->>'severity')", true
+		query = query.Joins("CROSS JOIN LATERAL " + parsedResultsSQL).Where(failingResultSQL)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown facet %q", facet)
+	}
+
+	counts := []FacetCount{}
+	err = query.Select(expression + " AS value, COUNT(DISTINCT documents.id) AS count").
+		Where("COALESCE(" + expression + ", '') <> ''").
+		Group(expression).
+		Order("count DESC, value").
+		Limit(20).
+		Scan(&counts).Error
+	return counts, err
+}
+
+// postgresSearchHit returns the search hit fields of a stored document
+func postgresSearchHit(doc model.Document) SearchHit {
+	fields := searchFields(doc)
+	status, _ := fields["compliance_status"].(string)
+	failingRules, _ := fields["failing_rules"].([]string)
+	return SearchHit{
+		DocumentID:       doc.ID,
+		FileID:           storageKeyFromURL(doc.OriginalURL),
+		FileURL:          doc.OriginalURL,
+		Title:            doc.Title,
+		FileType:         normalizeFileType(doc.FileType),
+		Category:         doc.Category,
+		RiskScore:        doc.RiskScore,
+		ComplianceStatus: status,
+		FailingRules:     failingRules,
+		CreatedAt:        doc.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// postgresHighlights returns the ts_headline output that marks a match, split into fragments like
+// Elasticsearch highlights. ts_headline returns the start of the text when nothing in it matched.
+func postgresHighlights(title, text string) map[string][]string {
+	highlights := map[string][]string{}
+	if strings.Contains(title, "<em>") {
+		highlights["title"] = []string{title}
+	}
+	var fragments []string
+	for _, fragment := range strings.Split(text, headlineDelimiter) {
+		if fragment = strings.TrimSpace(fragment); strings.Contains(fragment, "<em>") {
+			fragments = append(fragments, fragment)
+		}
+	}
+	if len(fragments) > 0 {
+		highlights["ocr_text"] = fragments
+	}
+	if len(highlights) == 0 {
+		return nil
+	}
+	return highlights
+}
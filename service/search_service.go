@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+)
+
+// ErrInvalidSearch is returned when a search request has an invalid sort, page or filter.
+var ErrInvalidSearch = errors.New("invalid search request")
+
+// Search page sizes. Elasticsearch does not page past maxSearchWindow hits.
+const (
+	DefaultSearchPageSize = 10
+	MaxSearchPageSize     = 100
+	maxSearchWindow       = 10000
+)
+
+// searchFacetFields maps each facet returned with search results to the indexed field it counts.
+var searchFacetFields = map[string]string{
+	"severity":  "failing_severities",
+	"file_type": "file_type",
+	"category":  "category",
+}
+
+// SearchFilter narrows search results. Zero values match everything.
+type SearchFilter struct {
+	MinRisk     *float64
+	MaxRisk     *float64
+	FileType    string
+	Category    string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// FailingRule keeps only documents failing the rule with this ID or name, ignoring case.
+	FailingRule string
+}
+
+// documentFilter returns the filter of the stored documents matching f
+func (f SearchFilter) documentFilter() DocumentFilter {
+	return DocumentFilter{
+		Category:    f.Category,
+		FileType:    f.FileType,
+		MinRisk:     f.MinRisk,
+		MaxRisk:     f.MaxRisk,
+		FailingRule: f.FailingRule,
+		CreatedFrom: f.CreatedFrom,
+		CreatedTo:   f.CreatedTo,
+	}
+}
+
+// SearchRequest is a full-text search over indexed documents.
+type SearchRequest struct {
+	Query  string
+	Filter SearchFilter
+	// Sort is "relevance" (default), "created_at" or "risk_score".
+	Sort string
+	// Order is "asc" or "desc" (default).
+	Order string
+	// Page is one-based; Size defaults to DefaultSearchPageSize and is at most MaxSearchPageSize.
+	Page int
+	Size int
+}
+
+// SearchHit is a matching document with its relevance score and highlighted snippets.
+type SearchHit struct {
+	DocumentID       string              `json:"document_id,omitempty"`
+	FileID           string              `json:"file_id,omitempty"`
+	FileURL          string              `json:"file_url,omitempty"`
+	Title            string              `json:"title,omitempty"`
+	FileType         string              `json:"file_type,omitempty"`
+	Category         string              `json:"category,omitempty"`
+	RiskScore        float64             `json:"risk_score"`
+	ComplianceStatus string              `json:"compliance_status,omitempty"`
+	FailingRules     []string            `json:"failing_rules,omitempty"`
+	CreatedAt        string              `json:"created_at,omitempty"`
+	Score            float64             `json:"score"`
+	Highlights       map[string][]string `json:"highlights,omitempty"`
+}
+
+// FacetCount is the number of matching documents with a facet value.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// SearchResults is one page of search hits with the facet counts of all matches.
+type SearchResults struct {
+	Hits   []SearchHit             `json:"hits"`
+	Total  int64                   `json:"total"`
+	Page   int                     `json:"page"`
+	Size   int                     `json:"size"`
+	Facets map[string][]FacetCount `json:"facets"`
+}
+
+// normalizeSearchRequest validates req and fills in the defaults
+func normalizeSearchRequest(req *SearchRequest) error {
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" {
+		return fmt.Errorf("%w: query is required", ErrInvalidSearch)
+	}
+	req.Sort = strings.ToLower(strings.TrimSpace(req.Sort))
+	if req.Sort == "" {
+		req.Sort = "relevance"
+	}
+	if req.Sort != "relevance" && req.Sort != "created_at" && req.Sort != "risk_score" {
+		return fmt.Errorf("%w: cannot sort by %q; expected relevance, created_at or risk_score", ErrInvalidSearch, req.Sort)
+	}
+	req.Order = strings.ToLower(strings.TrimSpace(req.Order))
+	if req.Order == "" {
+		req.Order = "desc"
+	}
+	if req.Order != "asc" && req.Order != "desc" {
+		return fmt.Errorf("%w: order must be 'asc' or 'desc'", ErrInvalidSearch)
+	}
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.Size <= 0 {
+		req.Size = DefaultSearchPageSize
+	}
+	if req.Size > MaxSearchPageSize {
+		req.Size = MaxSearchPageSize
+	}
+	if req.Page*req.Size > maxSearchWindow {
+		return fmt.Errorf("%w: cannot page past the first %d results", ErrInvalidSearch, maxSearchWindow)
+	}
+	if req.Filter.MinRisk != nil && req.Filter.MaxRisk != nil && *req.Filter.MinRisk > *req.Filter.MaxRisk {
+		return fmt.Errorf("%w: min_risk must not exceed max_risk", ErrInvalidSearch)
+	}
+	return nil
+}
+
+// SearchDocuments runs a full-text search over the stored documents with filters, sorting and
+// paging, and returns highlighted hits with relevance scores and facet counts of all matches
+func (s *DocumentService) SearchDocuments(ctx context.Context, req SearchRequest) (*SearchResults, error) {
+	if s.search == nil {
+		return nil, fmt.Errorf("search index is not configured")
+	}
+	if err := normalizeSearchRequest(&req); err != nil {
+		return nil, err
+	}
+
+	results, err := s.search.Search(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	results.Page, results.Size = req.Page, req.Size
+	log.Printf("[SearchDocuments] %q matched %d documents in %s", req.Query, results.Total, s.search.Name())
+	return results, nil
+}
+
+// searchFields returns the fields derived from a document for filters and facets: its normalised file
+// type, compliance status, and the failing rules with their severities. failing_rule_keys holds the
+// lower-cased IDs and names of the failing rules for the failing rule filter.
+func searchFields(doc model.Document) map[string]interface{} {
+	failingRules, ruleKeys, severities := []string{}, []string{}, []string{}
+	status := ""
+	var results []map[string]interface{}
+	if len(doc.ParsedData) > 0 && json.Unmarshal(doc.ParsedData, &results) == nil {
+		status = "pass"
+		for _, result := range results {
+			if resultStatus, _ := result["status"].(string); !failingStatus(resultStatus) {
+				continue
+			}
+			status = "fail"
+			name, _ := result["rule_name"].(string)
+			if name == "" {
+				name, _ = result["rule"].(string)
+			}
+			failingRules = append(failingRules, name)
+			if id, _ := result["rule_id"].(string); id != "" {
+				ruleKeys = append(ruleKeys, strings.ToLower(id))
+			}
+			if name != "" {
+				ruleKeys = append(ruleKeys, strings.ToLower(name))
+			}
+			if severity, _ := result["severity"].(string); severity != "" {
+				severities = append(severities, strings.ToLower(severity))
+			}
+		}
+	}
+	return map[string]interface{}{
+		"file_type":          normalizeFileType(doc.FileType),
+		"compliance_status":  status,
+		"failing_rules":      failingRules,
+		"failing_rule_keys":  removeDuplicates(ruleKeys),
+		"failing_severities": removeDuplicates(severities),
+	}
+}
+
+// indexDocument adds the text and metadata of a stored document to the search index
+func (s *DocumentService) indexDocument(ctx context.Context, doc model.Document) error {
+	if s.search == nil {
+		log.Println("Search index not configured. Skipping indexing.")
+		return nil
+	}
+	failures, err := s.search.Index(ctx, []model.Document{doc})
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to index document %s: %s", doc.ID, failures[0].Reason)
+	}
+	return nil
+}
+
+// updateIndexedDocument rewrites the index entry of a stored document after its results or
+// metadata change. Failures are logged; a reindex repairs the entry.
+func (s *DocumentService) updateIndexedDocument(ctx context.Context, doc model.Document) {
+	if err := s.indexDocument(ctx, doc); err != nil {
+		log.Printf("[updateIndexedDocument] Error updating index entry of %s: %v", doc.ID, err)
+	}
+}
+
+// deleteIndexedDocument removes the index entry of a stored document
+func (s *DocumentService) deleteIndexedDocument(ctx context.Context, docID string) error {
+	if s.search == nil {
+		return nil
+	}
+	return s.search.Delete(ctx, docID)
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeSearchRequest(t *testing.T) {
+	req := SearchRequest{Query: "  indemnity ", Size: 500}
+	require.NoError(t, normalizeSearchRequest(&req))
+	assert.Equal(t, "indemnity", req.Query)
+	assert.Equal(t, "relevance", req.Sort)
+	assert.Equal(t, "desc", req.Order)
+	assert.Equal(t, 1, req.Page)
+	assert.Equal(t, MaxSearchPageSize, req.Size)
+
+	minRisk, maxRisk := 0.8, 0.2
+	for _, invalid := range []SearchRequest{
+		{Query: " "},
+		{Query: "lease", Sort: "title"},
+		{Query: "lease", Order: "sideways"},
+		{Query: "lease", Page: 200, Size: 100},
+		{Query: "lease", Filter: SearchFilter{MinRisk: &minRisk, MaxRisk: &maxRisk}},
+	} {
+		assert.ErrorIs(t, normalizeSearchRequest(&invalid), ErrInvalidSearch)
+	}
+}
+
+func TestBuildSearchQuery(t *testing.T) {
+	minRisk := 0.5
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	req := SearchRequest{
+		Query: "termination",
+		Sort:  "risk_score",
+		Page:  3,
+		Filter: SearchFilter{
+			MinRisk:     &minRisk,
+			FileType:    ".PDF",
+			Category:    "Lease",
+			CreatedFrom: &from,
+			FailingRule: "Governing Law",
+		},
+	}
+	require.NoError(t, normalizeSearchRequest(&req))
+	body := buildSearchQuery(req)
+
+	assert.Equal(t, 20, body["from"])
+	assert.Equal(t, DefaultSearchPageSize, body["size"])
+
+	filters := body["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]interface{})
+	assert.Contains(t, filters, map[string]interface{}{"term": map[string]interface{}{"category": "lease"}})
+	assert.Contains(t, filters, map[string]interface{}{"term": map[string]interface{}{"file_type": "pdf"}})
+	assert.Contains(t, filters, map[string]interface{}{"term": map[string]interface{}{"failing_rule_keys": "governing law"}})
+	assert.Contains(t, filters, map[string]interface{}{"range": map[string]interface{}{"risk_score": map[string]interface{}{"gte": 0.5}}})
+	assert.Contains(t, filters, map[string]interface{}{"range": map[string]interface{}{"created_at": map[string]interface{}{"gte": "2025-01-01T00:00:00Z"}}})
+
+	sort := body["sort"].([]interface{})
+	assert.Equal(t, "desc", sort[0].(map[string]interface{})["risk_score"].(map[string]interface{})["order"])
+	assert.Len(t, body["aggs"], len(searchFacetFields))
+
+	// Relevance order is Elasticsearch's default and needs no sort clause.
+	req = SearchRequest{Query: "termination"}
+	require.NoError(t, normalizeSearchRequest(&req))
+	assert.NotContains(t, buildSearchQuery(req), "sort")
+}
+
+func TestParseSearchResponse(t *testing.T) {
+	response := `{
+		"hits": {
+			"total": {"value": 42},
+			"hits": [
+				{"_id": "d1", "_score": 2.5, "_source": {"id": "d1", "file_id": "f1", "title": "Lease", "risk_score": 0.7, "failing_rules": ["Governing Law"]},
+				 "highlight": {"ocr_text": ["the <em>termination</em> clause"]}},
+				{"_id": "d2", "_score": null, "_source": {"file_id": "f2"}}
+			]
+		},
+		"aggregations": {
+			"severity": {"buckets": [{"key": "high", "doc_count": 5}]},
+			"category": {"buckets": []}
+		}
+	}`
+	results, err := parseSearchResponse(strings.NewReader(response))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(42), results.Total)
+	require.Len(t, results.Hits, 2)
+	assert.Equal(t, "d1", results.Hits[0].DocumentID)
+	assert.Equal(t, "f1", results.Hits[0].FileID)
+	assert.Equal(t, 2.5, results.Hits[0].Score)
+	assert.Equal(t, []string{"the <em>termination</em> clause"}, results.Hits[0].Highlights["ocr_text"])
+	assert.Equal(t, "d2", results.Hits[1].DocumentID)
+	assert.Equal(t, "f2", results.Hits[1].FileID)
+
+	assert.Equal(t, []FacetCount{{Value: "high", Count: 5}}, results.Facets["severity"])
+	assert.Empty(t, results.Facets["category"])
+	assert.NotNil(t, results.Facets["file_type"])
+}
+
+func TestSearchFields(t *testing.T) {
+	doc := model.Document{
+		ID:         "d1",
+		FileType:   "PDF",
+		ParsedData: []byte(`[{"rule_name":"Signature","status":"pass"},{"rule_id":"R-1","rule":"Governing Law","status":"fail","severity":"High"},{"rule_name":"NDA","status":"skipped"}]`),
+	}
+	fields := searchFields(doc)
+	assert.Equal(t, "fail", fields["compliance_status"])
+	assert.Equal(t, []string{"Governing Law"}, fields["failing_rules"])
+	assert.Equal(t, []string{"r-1", "governing law"}, fields["failing_rule_keys"])
+	assert.Equal(t, []string{"high"}, fields["failing_severities"])
+	assert.Equal(t, "pdf", fields["file_type"])
+}
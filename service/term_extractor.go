@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// datePattern matches the date formats found in contracts: "2024-01-15", "15 January 2024",
+// "January 15, 2024", "15th day of January, 2024" and "15/01/2024".
+const datePattern = `(?:\d{4}-\d{2}-\d{2}|\d{1,2}(?:st|nd|rd|th)?\s+(?:day\s+of\s+)?[a-z]{3,9}\.?,?\s+\d{4}|[a-z]{3,9}\.?\s+\d{1,2}(?:st|nd|rd|th)?,?\s+\d{4}|\d{1,2}[/.-]\d{1,2}[/.-]\d{4})`
+
+// numberPattern matches a count written as digits, optionally after its spelling: "thirty (30)".
+const numberPattern = `(?:[a-z]+(?:-[a-z]+)?\s+\()?(\d+)\)?`
+
+// termPattern is a regular expression for a term type. Group 1 holds the value; for durations
+// group 2 holds the unit.
+type termPattern struct {
+	termType   string
+	re         *regexp.Regexp
+	confidence float64
+}
+
+// effectiveDatePatterns are tried in order; the first match is the effective date.
+var effectiveDatePatterns = []termPattern{
+	{model.TermEffectiveDate, regexp.MustCompile(`(?i)\beffective\s+date\b[^.\n]{0,40}?(` + datePattern + `)`), 0.9},
+	{model.TermEffectiveDate, regexp.MustCompile(`(?i)\beffective\s+(?:as\s+of|from|on)\s+(` + datePattern + `)`), 0.9},
+	{model.TermEffectiveDate, regexp.MustCompile(`(?i)\b(?:dated|made\s+(?:on|this)|entered\s+into\s+on)\s+(?:this\s+)?(` + datePattern + `)`), 0.7},
+}
+
+// governingLawPatterns are tried in order; the first match is the governing law.
+var governingLawPatterns = []termPattern{
+	{model.TermGoverningLaw, regexp.MustCompile(`(?i)\bgoverned\s+by[^.\n]{0,60}?\blaws?\s+of\s+(?:the\s+)?([a-z]+(?:\s+[a-z]+){0,3}?)(?:[,.;:()\n]|\s+(?:and|without|applicable|as)\b|$)`), 0.9},
+	{model.TermGoverningLaw, regexp.MustCompile(`(?i)\bgoverning\s+law\s*[:\-–]\s*(?:the\s+)?(?:laws?\s+of\s+)?(?:the\s+)?([a-z]+(?:\s+[a-z]+){0,3}?)(?:[,.;:()\n]|$)`), 0.85},
+	{model.TermGoverningLaw, regexp.MustCompile(`(?i)\blaws\s+of\s+(?:the\s+)?([a-z]+(?:\s+[a-z]+){0,3}?)\s+(?:shall|will)\s+govern`), 0.8},
+}
+
+var (
+	durationPattern = regexp.MustCompile(`(?i)\b(?:term|period)\s+of\s+(?:this\s+agreement\s+(?:shall\s+be|is)\s+)?` + numberPattern + `\s+(year|month|week|day)s?\b`)
+	noticePatterns  = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)` + numberPattern + `\s+(day|week|month)s?['’]?\s+(?:prior\s+|advance\s+)?(?:written\s+)?notice\b`),
+		regexp.MustCompile(`(?i)\bnotice\s+period\s+of\s+` + numberPattern + `\s+(day|week|month)s?\b`),
+	}
+	renewalPattern       = regexp.MustCompile(`(?i)\b(?:automatically\s+renew\w*|auto-renew\w*|renew\w*\s+automatically)`)
+	renewalPeriodPattern = regexp.MustCompile(`(?i)^[^.\n]{0,60}?` + numberPattern + `[\s-]+(year|month)s?\b`)
+	partiesPattern       = regexp.MustCompile(`(?i)\bbetween\s+(.+?)\s+and\s+(.+?)(?:[;\n]|\.(?:\s|$)|$)`)
+	amountPrefixPattern  = regexp.MustCompile(`(?i)(₹|\brs\.?|\binr\b|\busd\b|us\$|\$|€|\beur\b|£|\bgbp\b)\s?(\d{1,3}(?:,\d{2,3})+(?:\.\d+)?|\d+(?:\.\d+)?)(?:\s*(lakhs?|crores?|million|thousand)\b)?`)
+	amountSuffixPattern  = regexp.MustCompile(`(?i)\b(\d{1,3}(?:,\d{2,3})+(?:\.\d+)?|\d+(?:\.\d+)?)\s*(inr|usd|eur|gbp|rupees|dollars|euros|pounds)\b`)
+	ordinalSuffix        = regexp.MustCompile(`(?i)(\d)(?:st|nd|rd|th)\b`)
+)
+
+// currencyCodes maps currency symbols and words to ISO 4217 codes.
+var currencyCodes = map[string]string{
+	"₹": "INR", "rs": "INR", "rs.": "INR", "inr": "INR", "rupees": "INR",
+	"$": "USD", "us$": "USD", "usd": "USD", "dollars": "USD",
+	"€": "EUR", "eur": "EUR", "euros": "EUR",
+	"£": "GBP", "gbp": "GBP", "pounds": "GBP",
+}
+
+// amountMultipliers maps written magnitudes to their value.
+var amountMultipliers = map[string]float64{
+	"thousand": 1e3, "lakh": 1e5, "lakhs": 1e5, "million": 1e6, "crore": 1e7, "crores": 1e7,
+}
+
+// dateLayouts are tried in order by parseTermDate. Numeric dates are read day first.
+var dateLayouts = []string{"2006-01-02", "2 January 2006", "January 2 2006", "2 Jan 2006", "Jan 2 2006", "2/1/2006", "2-1-2006", "2 1 2006"}
+
+// ExtractTerms finds the parties, effective date, term, renewal, notice periods, monetary amounts and
+// governing law in text. Offsets locate SourceText in text; DocumentID is left for the caller to set.
+func ExtractTerms(text string) []model.DocumentTerm {
+	var terms []model.DocumentTerm
+	add := func(termType, value, source string, start, end int, confidence float64) *model.DocumentTerm {
+		terms = append(terms, model.DocumentTerm{
+			TermType:    termType,
+			Value:       value,
+			SourceText:  source,
+			StartOffset: start,
+			EndOffset:   end,
+			Confidence:  confidence,
+		})
+		return &terms[len(terms)-1]
+	}
+
+	terms = append(terms, extractParties(text)...)
+
+	for _, pattern := range effectiveDatePatterns {
+		loc := pattern.re.FindStringSubmatchIndex(text)
+		if loc == nil {
+			continue
+		}
+		date, numeric, ok := parseTermDate(text[loc[2]:loc[3]])
+		if !ok {
+			continue
+		}
+		confidence := pattern.confidence
+		if numeric {
+			// 03/04/2024 could be March or April.
+			confidence -= 0.2
+		}
+		term := add(model.TermEffectiveDate, date.Format("2006-01-02"), text[loc[0]:loc[1]], loc[0], loc[1], confidence)
+		term.DateValue = &date
+		break
+	}
+
+	for _, loc := range durationPattern.FindAllStringSubmatchIndex(text, -1) {
+		// "notice period of 30 days" is a notice period, not the term.
+		if !precededBy(text, loc[0], "notice") {
+			addDuration(add, model.TermDuration, text, loc, 0.8)
+			break
+		}
+	}
+
+	if loc := renewalPattern.FindStringIndex(text); loc != nil {
+		term := add(model.TermRenewal, "automatic", text[loc[0]:loc[1]], loc[0], loc[1], 0.6)
+		if period := renewalPeriodPattern.FindStringSubmatchIndex(text[loc[1]:]); period != nil {
+			n, _ := strconv.ParseFloat(text[loc[1]+period[2]:loc[1]+period[3]], 64)
+			unit := strings.ToLower(text[loc[1]+period[4]:loc[1]+period[5]]) + "s"
+			term.Value = fmt.Sprintf("automatic, %s %s", formatNumber(n), unit)
+			term.NumberValue = &n
+			term.Unit = unit
+			term.SourceText = text[loc[0] : loc[1]+period[1]]
+			term.EndOffset = loc[1] + period[1]
+			term.Confidence = 0.75
+		}
+	}
+
+	for _, re := range noticePatterns {
+		for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+			addDuration(add, model.TermNoticePeriod, text, loc, 0.85)
+		}
+	}
+
+	for _, loc := range amountPrefixPattern.FindAllStringSubmatchIndex(text, -1) {
+		magnitude := ""
+		if loc[6] >= 0 {
+			magnitude = text[loc[6]:loc[7]]
+		}
+		addAmount(add, text, loc, text[loc[2]:loc[3]], text[loc[4]:loc[5]], magnitude)
+	}
+	for _, loc := range amountSuffixPattern.FindAllStringSubmatchIndex(text, -1) {
+		addAmount(add, text, loc, text[loc[4]:loc[5]], text[loc[2]:loc[3]], "")
+	}
+
+	for _, pattern := range governingLawPatterns {
+		loc := pattern.re.FindStringSubmatchIndex(text)
+		if loc == nil {
+			continue
+		}
+		place := strings.TrimSpace(text[loc[2]:loc[3]])
+		if place == "" {
+			continue
+		}
+		end := loc[2] + len(place)
+		add(model.TermGoverningLaw, titleCase(place), text[loc[0]:end], loc[0], end, pattern.confidence)
+		break
+	}
+
+	return dedupeTerms(terms)
+}
+
+// extractParties reads the two parties of a "between X and Y" recital near the start of text
+func extractParties(text string) []model.DocumentTerm {
+	head := text
+	if len(head) > 2000 {
+		head = head[:2000]
+	}
+	loc := partiesPattern.FindStringSubmatchIndex(head)
+	if loc == nil {
+		return nil
+	}
+	var parties []model.DocumentTerm
+	for _, group := range [][2]int{{loc[2], loc[3]}, {loc[4], loc[5]}} {
+		start, end := group[0], group[1]
+		// Descriptions follow the name: `Acme Ltd, a company ...` or `Acme Ltd (the "Supplier")`.
+		if cut := strings.IndexAny(text[start:end], ",("); cut >= 0 {
+			end = start + cut
+		}
+		name := strings.TrimSpace(text[start:end])
+		end = start + strings.Index(text[start:end], name) + len(name)
+		start = end - len(name)
+		if len(name) < 2 || len(name) > 100 || !strings.ContainsAny(strings.ToLower(name), "abcdefghijklmnopqrstuvwxyz") {
+			return nil
+		}
+		parties = append(parties, model.DocumentTerm{
+			TermType:    model.TermParty,
+			Value:       name,
+			SourceText:  name,
+			StartOffset: start,
+			EndOffset:   end,
+			Confidence:  0.7,
+		})
+	}
+	return parties
+}
+
+// addDuration records a duration match whose groups 1 and 2 hold the count and unit
+func addDuration(add func(termType, value, source string, start, end int, confidence float64) *model.DocumentTerm, termType, text string, loc []int, confidence float64) {
+	n, err := strconv.ParseFloat(text[loc[2]:loc[3]], 64)
+	if err != nil {
+		return
+	}
+	unit := strings.ToLower(text[loc[4]:loc[5]]) + "s"
+	term := add(termType, fmt.Sprintf("%s %s", formatNumber(n), unit), text[loc[0]:loc[1]], loc[0], loc[1], confidence)
+	term.NumberValue = &n
+	term.Unit = unit
+}
+
+// addAmount records a monetary amount in the currency named by symbol
+func addAmount(add func(termType, value, source string, start, end int, confidence float64) *model.DocumentTerm, text string, loc []int, symbol, digits, magnitude string) {
+	code, ok := currencyCodes[strings.ToLower(symbol)]
+	if !ok {
+		return
+	}
+	n, err := strconv.ParseFloat(strings.ReplaceAll(digits, ",", ""), 64)
+	if err != nil {
+		return
+	}
+	if multiplier, ok := amountMultipliers[strings.ToLower(magnitude)]; ok {
+		n *= multiplier
+	}
+	term := add(model.TermAmount, fmt.Sprintf("%s %s", code, formatNumber(n)), text[loc[0]:loc[1]], loc[0], loc[1], 0.9)
+	term.NumberValue = &n
+	term.Unit = code
+}
+
+// parseTermDate parses a date in one of dateLayouts. numeric reports an all-digit day/month date,
+// whose order is ambiguous.
+func parseTermDate(value string) (date time.Time, numeric bool, ok bool) {
+	value = ordinalSuffix.ReplaceAllString(value, "$1")
+	value = strings.NewReplacer(",", " ", ".", " ").Replace(value)
+	value = strings.Join(strings.Fields(strings.Replace(strings.ToLower(value), "day of ", "", 1)), " ")
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, !strings.Contains(layout, "Jan") && layout != "2006-01-02", true
+		}
+	}
+	return time.Time{}, false, false
+}
+
+// precededBy reports whether word appears in the few characters before offset
+func precededBy(text string, offset int, word string) bool {
+	start := offset - 12
+	if start < 0 {
+		start = 0
+	}
+	return strings.Contains(strings.ToLower(text[start:offset]), word)
+}
+
+// formatNumber prints n without trailing zeros
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// titleCase capitalises the first letter of every word except joining words, as in "State of New York"
+func titleCase(value string) string {
+	words := strings.Fields(strings.ToLower(value))
+	for i, word := range words {
+		if i > 0 && (word == "of" || word == "and" || word == "the") {
+			continue
+		}
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// dedupeTerms drops terms of the same type starting at the same offset and orders terms by offset
+func dedupeTerms(terms []model.DocumentTerm) []model.DocumentTerm {
+	seen := make(map[string]bool, len(terms))
+	unique := terms[:0]
+	for _, term := range terms {
+		key := fmt.Sprintf("%s:%d", term.TermType, term.StartOffset)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, term)
+	}
+	sort.SliceStable(unique, func(i, j int) bool { return unique[i].StartOffset < unique[j].StartOffset })
+	return unique
+}
+
+// stageTerms extracts key terms from the OCR text; they are saved with the document
+func (s *DocumentService) stageTerms(ctx context.Context, state *pipelineState) error {
+	state.terms = ExtractTerms(state.ocrText)
+	log.Printf("Extracted %d key terms", len(state.terms))
+	return nil
+}
+
+// saveTerms stores terms for docID using tx. Terms already stored at the same offsets are kept.
+func saveTerms(tx *gorm.DB, docID string, terms []model.DocumentTerm) error {
+	if len(terms) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for i := range terms {
+		terms[i].DocumentID = docID
+		terms[i].CreatedAt = now
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&terms).Error
+}
+
+// getDocumentTerms lists the key terms of doc in text order. Documents processed before term
+// extraction existed have their terms extracted and stored on first request.
+func (s *DocumentService) getDocumentTerms(doc model.Document) ([]model.DocumentTerm, error) {
+	terms := []model.DocumentTerm{}
+	if err := s.db.Where("document_id = ?", doc.ID).Order("start_offset").Find(&terms).Error; err != nil {
+		return nil, err
+	}
+	if len(terms) > 0 || doc.OcrText == "" {
+		return terms, nil
+	}
+
+	terms = ExtractTerms(doc.OcrText)
+	if err := saveTerms(s.db, doc.ID, terms); err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
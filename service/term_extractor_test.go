@@ -0,0 +1,105 @@
+package services
+
+import (
+	"testing"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// termsOfType returns the extracted terms of termType
+func termsOfType(terms []model.DocumentTerm, termType string) []model.DocumentTerm {
+	var matched []model.DocumentTerm
+	for _, term := range terms {
+		if term.TermType == termType {
+			matched = append(matched, term)
+		}
+	}
+	return matched
+}
+
+func TestExtractTerms(t *testing.T) {
+	text := "MASTER SERVICES AGREEMENT\n" +
+		"This Agreement is entered into between Acme Technologies Pvt Ltd, a company incorporated in India, " +
+		"and Beta Corp (the \"Client\").\n" +
+		"The Effective Date of this Agreement is 15th January, 2024.\n" +
+		"1. Term\nThe term of this Agreement shall be two (2) years and shall automatically renew for successive one (1) year periods.\n" +
+		"2. Fees\nThe Client shall pay Rs. 5,00,000 per month and a setup fee of USD 1,200.50.\n" +
+		"3. Termination\nEither party may terminate with thirty (30) days' prior written notice.\n" +
+		"4. Governing Law\nThis Agreement shall be governed by the laws of the State of New York, without regard to conflicts.\n"
+
+	terms := ExtractTerms(text)
+	for _, term := range terms {
+		assert.Equal(t, term.SourceText, text[term.StartOffset:term.EndOffset], term.TermType)
+	}
+
+	parties := termsOfType(terms, model.TermParty)
+	require.Len(t, parties, 2)
+	assert.Equal(t, "Acme Technologies Pvt Ltd", parties[0].Value)
+	assert.Equal(t, "Beta Corp", parties[1].Value)
+	assert.Equal(t, "Beta Corp", text[parties[1].StartOffset:parties[1].EndOffset])
+
+	dates := termsOfType(terms, model.TermEffectiveDate)
+	require.Len(t, dates, 1)
+	assert.Equal(t, "2024-01-15", dates[0].Value)
+	require.NotNil(t, dates[0].DateValue)
+	assert.Equal(t, 2024, dates[0].DateValue.Year())
+	assert.Equal(t, 0.9, dates[0].Confidence)
+
+	durations := termsOfType(terms, model.TermDuration)
+	require.Len(t, durations, 1)
+	assert.Equal(t, "2 years", durations[0].Value)
+	assert.Equal(t, "years", durations[0].Unit)
+
+	renewals := termsOfType(terms, model.TermRenewal)
+	require.Len(t, renewals, 1)
+	assert.Equal(t, "automatic, 1 years", renewals[0].Value)
+
+	notices := termsOfType(terms, model.TermNoticePeriod)
+	require.Len(t, notices, 1)
+	assert.Equal(t, "30 days", notices[0].Value)
+
+	amounts := termsOfType(terms, model.TermAmount)
+	require.Len(t, amounts, 2)
+	assert.Equal(t, "INR 500000", amounts[0].Value)
+	assert.Equal(t, "INR", amounts[0].Unit)
+	assert.Equal(t, "USD 1200.5", amounts[1].Value)
+	require.NotNil(t, amounts[1].NumberValue)
+	assert.Equal(t, 1200.5, *amounts[1].NumberValue)
+
+	laws := termsOfType(terms, model.TermGoverningLaw)
+	require.Len(t, laws, 1)
+	assert.Equal(t, "State of New York", laws[0].Value)
+}
+
+func TestExtractTermsEdgeCases(t *testing.T) {
+	t.Run("notice period is not the term", func(t *testing.T) {
+		terms := ExtractTerms("The notice period of 60 days applies. The term of 3 years starts today.")
+		durations := termsOfType(terms, model.TermDuration)
+		require.Len(t, durations, 1)
+		assert.Equal(t, "3 years", durations[0].Value)
+		notices := termsOfType(terms, model.TermNoticePeriod)
+		require.Len(t, notices, 1)
+		assert.Equal(t, "60 days", notices[0].Value)
+	})
+
+	t.Run("numeric dates are less certain", func(t *testing.T) {
+		dates := termsOfType(ExtractTerms("Effective Date: 03/04/2024."), model.TermEffectiveDate)
+		require.Len(t, dates, 1)
+		assert.Equal(t, "2024-04-03", dates[0].Value)
+		assert.InDelta(t, 0.7, dates[0].Confidence, 0.001)
+	})
+
+	t.Run("magnitudes and suffixed currencies", func(t *testing.T) {
+		amounts := termsOfType(ExtractTerms("A penalty of ₹2 crore or 500 dollars."), model.TermAmount)
+		require.Len(t, amounts, 2)
+		assert.Equal(t, "INR 20000000", amounts[0].Value)
+		assert.Equal(t, "USD 500", amounts[1].Value)
+	})
+
+	t.Run("no terms", func(t *testing.T) {
+		assert.Empty(t, ExtractTerms("Meeting notes from Tuesday."))
+		assert.Empty(t, ExtractTerms(""))
+	})
+}
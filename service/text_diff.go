@@ -0,0 +1,171 @@
+package services
+
+import "strings"
+
+// Line diff operations.
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// maxDiffEdits bounds the edit distance explored by diffLines. Inputs that differ more than this
+// are reported as a full replacement of the differing region.
+const maxDiffEdits = 2000
+
+// DiffLine is a single line of a line-based diff. OldLine and NewLine are 1-based line numbers in
+// the old and new text; they are zero when the line does not exist on that side.
+type DiffLine struct {
+	Op      string `json:"op"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+	Text    string `json:"text"`
+}
+
+// splitLines splits text into lines, normalising Windows line endings
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffText returns the line diff between two texts
+func diffText(oldText, newText string) []DiffLine {
+	return diffLines(splitLines(oldText), splitLines(newText))
+}
+
+// diffLines computes a shortest edit script between a and b using Myers' algorithm
+func diffLines(a, b []string) []DiffLine {
+	// Common prefix and suffix are handled directly; they dominate for revised drafts.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var result []DiffLine
+	for i := 0; i < prefix; i++ {
+		result = append(result, DiffLine{Op: DiffEqual, OldLine: i + 1, NewLine: i + 1, Text: a[i]})
+	}
+
+	middle := myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	for _, line := range middle {
+		if line.OldLine > 0 {
+			line.OldLine += prefix
+		}
+		if line.NewLine > 0 {
+			line.NewLine += prefix
+		}
+		result = append(result, line)
+	}
+
+	for i := 0; i < suffix; i++ {
+		oldIdx := len(a) - suffix + i
+		newIdx := len(b) - suffix + i
+		result = append(result, DiffLine{Op: DiffEqual, OldLine: oldIdx + 1, NewLine: newIdx + 1, Text: a[oldIdx]})
+	}
+	return result
+}
+
+// myersDiff runs the greedy Myers algorithm and backtracks through the recorded frontiers
+func myersDiff(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	limit := n + m
+	if limit > maxDiffEdits {
+		limit = maxDiffEdits
+	}
+
+	// v[k+offset] holds the furthest x reached on diagonal k. trace[d] keeps the frontier at the
+	// start of round d, restricted to the diagonals -d..d that round can read.
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	found := -1
+
+	for d := 0; d <= limit && found < 0; d++ {
+		snapshot := make([]int, 2*d+3)
+		copy(snapshot, v[offset-d-1:offset+d+2])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = d
+				break
+			}
+		}
+	}
+
+	if found < 0 {
+		return replaceAll(a, b)
+	}
+
+	var reversed []DiffLine
+	x, y := n, m
+	for d := found; d >= 0; d-- {
+		frontier := trace[d]
+		at := func(k int) int { return frontier[k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, DiffLine{Op: DiffEqual, OldLine: x, NewLine: y, Text: a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, DiffLine{Op: DiffInsert, NewLine: y, Text: b[y-1]})
+			} else {
+				reversed = append(reversed, DiffLine{Op: DiffDelete, OldLine: x, Text: a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	result := make([]DiffLine, len(reversed))
+	for i, line := range reversed {
+		result[len(reversed)-1-i] = line
+	}
+	return result
+}
+
+// replaceAll reports every line of a as deleted and every line of b as inserted
+func replaceAll(a, b []string) []DiffLine {
+	result := make([]DiffLine, 0, len(a)+len(b))
+	for i, line := range a {
+		result = append(result, DiffLine{Op: DiffDelete, OldLine: i + 1, Text: line})
+	}
+	for i, line := range b {
+		result = append(result, DiffLine{Op: DiffInsert, NewLine: i + 1, Text: line})
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// applyDiff rebuilds both sides of a diff so the edit script can be checked against its inputs.
+func applyDiff(lines []DiffLine) (string, string) {
+	var oldLines, newLines []string
+	for _, line := range lines {
+		if line.Op != DiffInsert {
+			oldLines = append(oldLines, line.Text)
+		}
+		if line.Op != DiffDelete {
+			newLines = append(newLines, line.Text)
+		}
+	}
+	return strings.Join(oldLines, "\n"), strings.Join(newLines, "\n")
+}
+
+func TestDiffText_ChangedClause(t *testing.T) {
+	oldText := "Parties\nTerm: 12 months\nGoverning law: NY\nSignatures"
+	newText := "Parties\nTerm: 24 months\nGoverning law: NY\nConfidentiality applies\nSignatures"
+
+	lines := diffText(oldText, newText)
+	var changes []DiffLine
+	for _, line := range lines {
+		if line.Op != DiffEqual {
+			changes = append(changes, line)
+		}
+	}
+	assert.Equal(t, []DiffLine{
+		{Op: DiffDelete, OldLine: 2, Text: "Term: 12 months"},
+		{Op: DiffInsert, NewLine: 2, Text: "Term: 24 months"},
+		{Op: DiffInsert, NewLine: 4, Text: "Confidentiality applies"},
+	}, changes)
+
+	gotOld, gotNew := applyDiff(lines)
+	assert.Equal(t, oldText, gotOld)
+	assert.Equal(t, newText, gotNew)
+}
+
+func TestDiffText_EdgeCases(t *testing.T) {
+	assert.Empty(t, diffText("", ""))
+	assert.Equal(t, []DiffLine{{Op: DiffInsert, NewLine: 1, Text: "a"}}, diffText("", "a"))
+	assert.Equal(t, []DiffLine{{Op: DiffDelete, OldLine: 1, Text: "a"}}, diffText("a\r\n", ""))
+
+	for i, tc := range [][2]string{
+		{"a\nb\nc\na\nb\nb\na", "c\nb\na\nb\na\nc"},
+		{"x\ny", "y\nx"},
+		{"1\n2\n3\n4\n5", "5\n4\n3\n2\n1"},
+	} {
+		gotOld, gotNew := applyDiff(diffText(tc[0], tc[1]))
+		assert.Equal(t, tc[0], gotOld, fmt.Sprintf("case %d", i))
+		assert.Equal(t, tc[1], gotNew, fmt.Sprintf("case %d", i))
+	}
+}
+
+func TestCompareRuleResults(t *testing.T) {
+	from := []byte(`[{"rule_name":"Signature","status":"pass"},{"rule_name":"Termination","status":"fail"}]`)
+	to := []byte(`[{"rule_name":"Signature","status":"fail","severity":"High"},{"rule_name":"Termination","status":"pass"},{"rule_name":"Arbitration","status":"fail"}]`)
+
+	failing, passing, removed := compareRuleResults(from, to)
+	require.Len(t, failing, 2)
+	assert.Equal(t, "Arbitration", failing[0].RuleName)
+	assert.Equal(t, "absent", failing[0].FromStatus)
+	assert.Equal(t, RuleStatusChange{RuleName: "Signature", Severity: "High", FromStatus: "pass", ToStatus: "fail"}, failing[1])
+	assert.Equal(t, []RuleStatusChange{{RuleName: "Termination", FromStatus: "fail", ToStatus: "pass"}}, passing)
+	assert.Empty(t, removed)
+}
+
+func TestCompareRuleResults_OnlyPassIsPassing(t *testing.T) {
+	from := []byte(`[{"rule_id":"r1","rule_name":"Signature","status":"fail"},{"rule_id":"r2","rule_name":"Termination","status":"fail"}]`)
+	to := []byte(`[{"rule_id":"r1","rule_name":"Signature","status":"skipped"},{"rule_id":"r2","rule_name":"Termination","status":"error"}]`)
+
+	failing, passing, removed := compareRuleResults(from, to)
+	assert.Empty(t, failing)
+	assert.Empty(t, passing, "skipped and errored rules are not passing")
+	assert.Empty(t, removed)
+}
+
+func TestCompareRuleResults_MatchesByRuleID(t *testing.T) {
+	from := []byte(`[{"rule_id":"r1","rule_name":"Signature","status":"fail"},{"rule_name":"Governing Law","status":"fail"}]`)
+	to := []byte(`[{"rule_id":"r1","rule_name":"Signature Block","status":"pass"},{"rule_id":"r2","rule_name":"Governing Law","status":"fail"}]`)
+
+	failing, passing, removed := compareRuleResults(from, to)
+	assert.Empty(t, failing, "a legacy entry without rule ID is matched by name")
+	assert.Equal(t, []RuleStatusChange{{RuleID: "r1", RuleName: "Signature Block", FromStatus: "fail", ToStatus: "pass"}}, passing)
+	assert.Empty(t, removed, "a renamed rule is not reported as removed")
+}
+
+func TestCompareRuleResults_RemovedRules(t *testing.T) {
+	from := []byte(`[{"rule_id":"r1","rule_name":"Signature","status":"fail","severity":"high"},{"rule_id":"r2","rule_name":"Termination","status":"pass"}]`)
+	to := []byte(`[{"rule_id":"r2","rule_name":"Termination","status":"pass"}]`)
+
+	failing, passing, removed := compareRuleResults(from, to)
+	assert.Empty(t, failing)
+	assert.Empty(t, passing)
+	assert.Equal(t, []RuleStatusChange{{RuleID: "r1", RuleName: "Signature", Severity: "high", FromStatus: "fail", ToStatus: "absent"}}, removed)
+}
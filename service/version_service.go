@@ -0,0 +1,333 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	model "github.com/Itish41/LegalEagle/models"
+	"gorm.io/gorm"
+)
+
+// ErrVersionNotFound is returned when a requested version number does not exist in a lineage.
+var ErrVersionNotFound = errors.New("version not found")
+
+// ErrNoPreviousVersion is returned when a diff against the previous version is requested for the
+// first version of a document.
+var ErrNoPreviousVersion = errors.New("there is no previous version to compare with")
+
+// VersionInfo describes one version of a document lineage.
+type VersionInfo struct {
+	VersionNumber    int     `json:"version"`
+	DocumentID       string  `json:"document_id"`
+	ParentDocumentID *string `json:"parent_document_id,omitempty"`
+	Title            string  `json:"title"`
+	RiskScore        float64 `json:"risk_score"`
+	CreatedAt        string  `json:"created_at"`
+}
+
+// RuleStatusChange describes a rule whose outcome differs between two versions. A rule that was not
+// evaluated for one of the versions has the status "absent" there.
+type RuleStatusChange struct {
+	RuleID     string `json:"rule_id,omitempty"`
+	RuleName   string `json:"rule_name"`
+	Severity   string `json:"severity,omitempty"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+}
+
+// DocumentDiff is the difference between two versions of a document.
+type DocumentDiff struct {
+	FromVersion    int                `json:"from_version"`
+	ToVersion      int                `json:"to_version"`
+	FromDocumentID string             `json:"from_document_id"`
+	ToDocumentID   string             `json:"to_document_id"`
+	LinesAdded     int                `json:"lines_added"`
+	LinesRemoved   int                `json:"lines_removed"`
+	Changes        []DiffLine         `json:"changes"`
+	NewlyFailing   []RuleStatusChange `json:"newly_failing"`
+	NewlyPassing   []RuleStatusChange `json:"newly_passing"`
+	RemovedRules   []RuleStatusChange `json:"removed_rules"`
+	RiskScoreDelta float64            `json:"risk_score_delta"`
+}
+
+// linkVersion records docID as the next version in the lineage of parentID. It must run inside
+// the transaction that creates the document.
+func (s *DocumentService) linkVersion(tx *gorm.DB, parentID, docID string) error {
+	// Version numbers are allocated under an advisory lock on the lineage's root. Row locks are not
+	// enough: two first revisions of a document have no lineage rows to lock yet. The parent is
+	// re-read after locking because deleting the root re-roots its lineage.
+	rootID := parentID
+	var parent model.DocumentVersion
+	found := false
+	for {
+		if err := lockLineage(tx, rootID); err != nil {
+			return fmt.Errorf("failed to lock version lineage: %w", err)
+		}
+		err := tx.Where("document_id = ?", parentID).First(&parent).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if rootID == parentID {
+				break
+			}
+			rootID = parentID
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load parent version: %w", err)
+		}
+		found = true
+		if parent.RootDocumentID == rootID {
+			break
+		}
+		found = false
+		rootID = parent.RootDocumentID
+	}
+	if !found {
+		// The parent has never been revised before; it becomes version 1 of its own lineage.
+		parent = model.DocumentVersion{RootDocumentID: parentID, DocumentID: parentID, VersionNumber: 1}
+		if err := tx.Create(&parent).Error; err != nil {
+			return fmt.Errorf("failed to create root version: %w", err)
+		}
+	}
+
+	var latest int
+	if err := tx.Model(&model.DocumentVersion{}).
+		Where("root_document_id = ?", rootID).
+		Select("COALESCE(MAX(version_number), 0)").Scan(&latest).Error; err != nil {
+		return fmt.Errorf("failed to read latest version number: %w", err)
+	}
+	next := latest + 1
+
+	version := model.DocumentVersion{
+		RootDocumentID:   rootID,
+		DocumentID:       docID,
+		ParentDocumentID: &parentID,
+		VersionNumber:    next,
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		return fmt.Errorf("failed to create document version: %w", err)
+	}
+	log.Printf("[linkVersion] Document %s recorded as version %d of %s", docID, next, rootID)
+	return nil
+}
+
+// lockLineage takes a lock on the lineage rooted at rootID that is held until the transaction ends
+func lockLineage(tx *gorm.DB, rootID string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", rootID).Error
+}
+
+// documentExists reports whether a document with the given ID exists
+func (s *DocumentService) documentExists(docID string) (bool, error) {
+	var count int64
+	if err := s.db.Model(&model.Document{}).Where("id = ?", docID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetDocumentVersions lists every version in the lineage of a document, oldest first
+func (s *DocumentService) GetDocumentVersions(docID string) ([]VersionInfo, error) {
+	var doc model.Document
+	if err := s.db.Select("id", "title", "risk_score", "created_at").First(&doc, "id = ?", docID).Error; err != nil {
+		log.Printf("[GetDocumentVersions] Error fetching document %s: %v", docID, err)
+		return nil, err
+	}
+
+	var current model.DocumentVersion
+	err := s.db.Where("document_id = ?", docID).First(&current).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// A document that was never revised is the only version of itself.
+		return []VersionInfo{{
+			VersionNumber: 1,
+			DocumentID:    doc.ID,
+			Title:         doc.Title,
+			RiskScore:     doc.RiskScore,
+			CreatedAt:     doc.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		}}, nil
+	} else if err != nil {
+		log.Printf("[GetDocumentVersions] Error fetching version of %s: %v", docID, err)
+		return nil, err
+	}
+
+	var versions []model.DocumentVersion
+	if err := s.db.Where("root_document_id = ?", current.RootDocumentID).Order("version_number").Find(&versions).Error; err != nil {
+		log.Printf("[GetDocumentVersions] Error fetching lineage %s: %v", current.RootDocumentID, err)
+		return nil, err
+	}
+
+	ids := make([]string, len(versions))
+	for i, v := range versions {
+		ids[i] = v.DocumentID
+	}
+	var docs []model.Document
+	if err := s.db.Select("id", "title", "risk_score", "created_at").Where("id IN ?", ids).Find(&docs).Error; err != nil {
+		log.Printf("[GetDocumentVersions] Error fetching lineage documents: %v", err)
+		return nil, err
+	}
+	docsByID := make(map[string]model.Document, len(docs))
+	for _, d := range docs {
+		docsByID[d.ID] = d
+	}
+
+	result := make([]VersionInfo, 0, len(versions))
+	for _, v := range versions {
+		d := docsByID[v.DocumentID]
+		result = append(result, VersionInfo{
+			VersionNumber:    v.VersionNumber,
+			DocumentID:       v.DocumentID,
+			ParentDocumentID: v.ParentDocumentID,
+			Title:            d.Title,
+			RiskScore:        d.RiskScore,
+			CreatedAt:        d.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		})
+	}
+	return result, nil
+}
+
+// DiffDocumentVersions compares two versions in the lineage of docID. A zero toVersion selects the
+// latest version and a zero fromVersion selects the version before toVersion; ErrNoPreviousVersion
+// is returned when toVersion is the first version.
+func (s *DocumentService) DiffDocumentVersions(docID string, fromVersion, toVersion int) (*DocumentDiff, error) {
+	versions, err := s.GetDocumentVersions(docID)
+	if err != nil {
+		return nil, err
+	}
+
+	if toVersion == 0 {
+		toVersion = versions[len(versions)-1].VersionNumber
+	}
+	if fromVersion == 0 {
+		if toVersion == versions[0].VersionNumber {
+			return nil, fmt.Errorf("%w: version %d is the first version of the document", ErrNoPreviousVersion, toVersion)
+		}
+		fromVersion = toVersion - 1
+	}
+
+	var fromID, toID string
+	for _, v := range versions {
+		if v.VersionNumber == fromVersion {
+			fromID = v.DocumentID
+		}
+		if v.VersionNumber == toVersion {
+			toID = v.DocumentID
+		}
+	}
+	if fromID == "" || toID == "" {
+		return nil, fmt.Errorf("%w: from=%d to=%d", ErrVersionNotFound, fromVersion, toVersion)
+	}
+
+	var fromDoc, toDoc model.Document
+	if err := s.db.First(&fromDoc, "id = ?", fromID).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.First(&toDoc, "id = ?", toID).Error; err != nil {
+		return nil, err
+	}
+
+	diff := &DocumentDiff{
+		FromVersion:    fromVersion,
+		ToVersion:      toVersion,
+		FromDocumentID: fromID,
+		ToDocumentID:   toID,
+		Changes:        []DiffLine{},
+		RiskScoreDelta: toDoc.RiskScore - fromDoc.RiskScore,
+	}
+	for _, line := range diffText(fromDoc.OcrText, toDoc.OcrText) {
+		switch line.Op {
+		case DiffInsert:
+			diff.LinesAdded++
+		case DiffDelete:
+			diff.LinesRemoved++
+		default:
+			continue
+		}
+		diff.Changes = append(diff.Changes, line)
+	}
+	diff.NewlyFailing, diff.NewlyPassing, diff.RemovedRules = compareRuleResults(fromDoc.ParsedData, toDoc.ParsedData)
+	return diff, nil
+}
+
+// compareRuleResults compares the per-rule results stored in two ParsedData values and returns the
+// rules that started failing, the rules that went from failing to passing and the rules that were
+// evaluated only in fromData. Results are matched by rule ID, so renamed rules are compared with their
+// earlier results; entries written before rule IDs were recorded are matched by name.
+func compareRuleResults(fromData, toData []byte) ([]RuleStatusChange, []RuleStatusChange, []RuleStatusChange) {
+	fromResults := parseRuleResults(fromData)
+	toResults := parseRuleResults(toData)
+
+	fromByID := make(map[string]int)
+	fromByName := make(map[string]int)
+	for i, entry := range fromResults {
+		if id := entry.id(); id != "" {
+			fromByID[id] = i
+		}
+		if name := entry.name(); name != "" {
+			fromByName[name] = i
+		}
+	}
+
+	newlyFailing := []RuleStatusChange{}
+	newlyPassing := []RuleStatusChange{}
+	matched := make(map[int]bool, len(fromResults))
+	for _, to := range toResults {
+		i, existed := fromByID[to.id()]
+		if !existed || to.id() == "" {
+			// Fall back to the name when either side has no rule ID.
+			i, existed = fromByName[to.name()]
+			existed = existed && (to.id() == "" || fromResults[i].id() == "")
+		}
+		fromStatus := "absent"
+		if existed && !matched[i] {
+			matched[i] = true
+			fromStatus = fromResults[i].status()
+		}
+
+		change := to.change(fromStatus, to.status())
+		switch {
+		case change.ToStatus == "fail" && fromStatus != "fail":
+			newlyFailing = append(newlyFailing, change)
+		case change.ToStatus == "pass" && fromStatus == "fail":
+			newlyPassing = append(newlyPassing, change)
+		}
+	}
+
+	removed := []RuleStatusChange{}
+	for i, from := range fromResults {
+		if !matched[i] && (from.id() != "" || from.name() != "") {
+			removed = append(removed, from.change(from.status(), "absent"))
+		}
+	}
+
+	for _, changes := range [][]RuleStatusChange{newlyFailing, newlyPassing, removed} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].RuleName < changes[j].RuleName })
+	}
+	return newlyFailing, newlyPassing, removed
+}
+
+// ruleResultEntry is one rule result stored in ParsedData.
+type ruleResultEntry map[string]interface{}
+
+func (e ruleResultEntry) id() string     { id, _ := e["rule_id"].(string); return id }
+func (e ruleResultEntry) name() string   { name, _ := e["rule_name"].(string); return name }
+func (e ruleResultEntry) status() string { status, _ := e["status"].(string); return status }
+
+// change describes the entry's rule moving from one status to another
+func (e ruleResultEntry) change(fromStatus, toStatus string) RuleStatusChange {
+	severity, _ := e["severity"].(string)
+	return RuleStatusChange{RuleID: e.id(), RuleName: e.name(), Severity: severity, FromStatus: fromStatus, ToStatus: toStatus}
+}
+
+// parseRuleResults returns the rule results stored in ParsedData
+func parseRuleResults(data []byte) []ruleResultEntry {
+	if len(data) == 0 {
+		return nil
+	}
+	var entries []ruleResultEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Printf("[parseRuleResults] Error unmarshaling parsed_data: %v", err)
+		return nil
+	}
+	return entries
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffDocumentVersions_FirstVersion(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	fake.on(`FROM "documents" WHERE id = \$1`, []string{"id", "title", "risk_score", "created_at"},
+		[]driver.Value{"doc-1", "Supply agreement", 3.0, time.Now()})
+	s := &DocumentService{db: db}
+
+	_, err := s.DiffDocumentVersions("doc-1", 0, 0)
+	assert.ErrorIs(t, err, ErrNoPreviousVersion)
+
+	_, err = s.DiffDocumentVersions("doc-1", 1, 2)
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+}
+
+func TestDiffDocumentVersions_PreviousVersion(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	fake.on(`FROM "document_versions" WHERE document_id = \$1`, []string{"id", "root_document_id", "document_id", "version_number"},
+		[]driver.Value{"ver-2", "doc-1", "doc-2", int64(2)})
+	fake.on(`FROM "document_versions" WHERE root_document_id = \$1`, []string{"id", "root_document_id", "document_id", "version_number"},
+		[]driver.Value{"ver-1", "doc-1", "doc-1", int64(1)},
+		[]driver.Value{"ver-2", "doc-1", "doc-2", int64(2)})
+	fake.on(`FROM "documents" WHERE id IN`, []string{"id", "title"},
+		[]driver.Value{"doc-1", "Supply agreement"}, []driver.Value{"doc-2", "Supply agreement"})
+	columns := []string{"id", "ocr_text", "parsed_data", "risk_score"}
+	fake.on(`^SELECT "id","title","risk_score","created_at" FROM "documents"`, columns,
+		[]driver.Value{"doc-2", "", nil, 0.0}).onlyOnce()
+	fake.on(`^SELECT \* FROM "documents" WHERE id = \$1`, columns,
+		[]driver.Value{"doc-1", "Payment within 30 days.", []byte(`[{"rule_id":"r1","rule_name":"Signature","status":"fail"}]`), 3.0}).onlyOnce()
+	fake.on(`^SELECT \* FROM "documents" WHERE id = \$1`, columns,
+		[]driver.Value{"doc-2", "Payment within 30 days.\nSigned by both parties.", []byte(`[{"rule_id":"r1","rule_name":"Signature","status":"pass"}]`), 0.0}).onlyOnce()
+	s := &DocumentService{db: db}
+
+	diff, err := s.DiffDocumentVersions("doc-2", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, diff.FromVersion)
+	assert.Equal(t, 2, diff.ToVersion)
+	assert.Equal(t, 1, diff.LinesAdded)
+	assert.Equal(t, -3.0, diff.RiskScoreDelta)
+	assert.Equal(t, []RuleStatusChange{{RuleID: "r1", RuleName: "Signature", FromStatus: "fail", ToStatus: "pass"}}, diff.NewlyPassing)
+	assert.Empty(t, diff.NewlyFailing)
+	assert.Empty(t, diff.RemovedRules)
+}
+
+var fakeVersionColumns = []string{"id", "root_document_id", "document_id", "version_number"}
+
+func TestLinkVersion_FirstRevisionLocksLineage(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	fake.on(`COALESCE\(MAX\(version_number\), 0\)`, []string{"max"}, []driver.Value{int64(1)})
+	s := &DocumentService{db: db}
+
+	require.NoError(t, s.linkVersion(db, "doc-1", "doc-2"))
+
+	statements := fake.executed(`pg_advisory_xact_lock|document_versions`)
+	require.NotEmpty(t, statements)
+	assert.Contains(t, statements[0].SQL, "pg_advisory_xact_lock", "the lineage is locked before it is read")
+	assert.Equal(t, []interface{}{"doc-1"}, statements[0].Args)
+
+	inserts := fake.executed(`^INSERT INTO "document_versions"`)
+	require.Len(t, inserts, 2)
+	assert.Contains(t, inserts[0].Args, 1, "the parent becomes version 1")
+	assert.Contains(t, inserts[1].Args, 2)
+}
+
+func TestLinkVersion_RelocksReRootedLineage(t *testing.T) {
+	db, fake := newFakeGormDB(t)
+	fake.on(`FROM "document_versions" WHERE document_id = \$1`, fakeVersionColumns,
+		[]driver.Value{"ver-3", "doc-2", "doc-3", int64(3)})
+	fake.on(`COALESCE\(MAX\(version_number\), 0\)`, []string{"max"}, []driver.Value{int64(3)})
+	s := &DocumentService{db: db}
+
+	require.NoError(t, s.linkVersion(db, "doc-3", "doc-4"))
+
+	locks := fake.executed(`pg_advisory_xact_lock`)
+	require.Len(t, locks, 2)
+	assert.Equal(t, []interface{}{"doc-3"}, locks[0].Args)
+	assert.Equal(t, []interface{}{"doc-2"}, locks[1].Args, "the lineage root of the parent is locked")
+
+	inserts := fake.executed(`^INSERT INTO "document_versions"`)
+	require.Len(t, inserts, 1)
+	assert.Contains(t, inserts[0].Args, "doc-2")
+	assert.Contains(t, inserts[0].Args, 4)
+}